@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/validator"
+)
+
+// Функция newAuditEvent() создает событие журнала аудита с IP-адресом и User-Agent
+// клиента из запроса.
+func newAuditEvent(r *http.Request, action, actor string, details map[string]string) *data.AuditEvent {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return &data.AuditEvent{
+		Action:    action,
+		Actor:     actor,
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+		Details:   details,
+	}
+}
+
+// Метод recordAuditEvent() записывает событие в журнал аудита (см. newAuditEvent()).
+// Запись выполняется в фоне, чтобы не задерживать ответ, а ошибка записи только
+// попадает в лог.
+func (app *application) recordAuditEvent(r *http.Request, action, actor string, details map[string]string) {
+	event := newAuditEvent(r, action, actor, details)
+
+	// Контекст запроса отменяется сразу после отправки ответа, поэтому фоновая
+	// запись использует контекст без отмены (значения контекста сохраняются).
+	ctx := context.WithoutCancel(r.Context())
+	logger := app.contextGetLogger(r)
+
+	app.background(func() {
+		err := app.models.AuditEvents.Insert(ctx, event)
+		if err != nil {
+			logger.PrintError(err, map[string]string{
+				"audit_action": action,
+			})
+		}
+	})
+}
+
+func (app *application) listAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.AuditEventFilter
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Action = app.readString(qs, "action", "")
+	input.Actor = app.readString(qs, "actor", "")
+	input.IP = app.readString(qs, "ip", "")
+	input.Since = app.readTime(qs, "since", v)
+	input.Until = app.readTime(qs, "until", v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"id", "created_at", "-id", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	events, metadata, err := app.models.AuditEvents.GetAll(r.Context(), input.AuditEventFilter, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"audit_events": events, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/data"
+)
+
+func TestListAuditEvents(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	header := http.Header{}
+	(&http.Request{Header: header}).SetBasicAuth(testAdminUsername, "wrong")
+	code, _, _ := ts.do(t, http.MethodGet, "/v1/admin/audit-events", "", header)
+	assert.Equal(t, code, http.StatusUnauthorized)
+	// События пишутся в фоне: дожидаемся первого, чтобы порядок был определен.
+	app.wg.Wait()
+	code, _, _ = ts.do(t, http.MethodPost, "/v1/movies/1/publish", "", adminHeader())
+	assert.Equal(t, code, http.StatusNotFound)
+	app.wg.Wait()
+
+	code, _, body := ts.do(t, http.MethodGet, "/v1/admin/audit-events?action=admin.login_failed", "", adminHeader())
+	assert.Equal(t, code, http.StatusOK)
+	resp := decodeJSON[struct {
+		Events   []data.AuditEvent `json:"audit_events"`
+		Metadata data.Metadata     `json:"metadata"`
+	}](t, body)
+	assert.Equal(t, len(resp.Events), 1)
+	assert.Equal(t, resp.Events[0].Actor, testAdminUsername)
+	assert.Equal(t, resp.Metadata.TotalRecords, 1)
+
+	code, _, body = ts.do(t, http.MethodGet, "/v1/admin/audit-events?sort=id", "", adminHeader())
+	assert.Equal(t, code, http.StatusOK)
+	resp = decodeJSON[struct {
+		Events   []data.AuditEvent `json:"audit_events"`
+		Metadata data.Metadata     `json:"metadata"`
+	}](t, body)
+	assert.Equal(t, len(resp.Events), 2)
+	assert.Equal(t, resp.Events[0].Action, data.AuditAdminLoginFailed)
+	assert.Equal(t, resp.Events[1].Action, data.AuditAdminLogin)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/validator"
+)
+
+// Количество фильмов, удаляемых в одной транзакции при удалении по фильтру.
+const deleteBatchSize = 500
+
+// Обработчик deleteMoviesHandler() удаляет все фильмы, подходящие под условия из
+// параметров запроса (title, genres, imdb_id, tmdb_id, status, year_max). По умолчанию
+// ничего не удаляется: ответ только сообщает, сколько фильмов подходит под условия.
+// Удаление выполняется с параметром confirm=true пакетами по deleteBatchSize фильмов,
+// каждый в отдельной транзакции вместе с записью в журнале аудита, чтобы не держать
+// блокировки на всех строках сразу. Если удаление прервется, уже удаленные пакеты
+// останутся удаленными, и запрос можно повторить.
+func (app *application) deleteMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var filter data.MovieFilter
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filter.Title = app.readString(qs, "title", "")
+	filter.Genres = app.readCSV(qs, "genres", []string{})
+	filter.IMDbID = app.readString(qs, "imdb_id", "")
+	filter.TMDBID = int64(app.readInt(qs, "tmdb_id", 0, v))
+	filter.Status = app.readString(qs, "status", "")
+	filter.YearMax = int32(app.readInt(qs, "year_max", 0, v))
+	confirm := app.readBool(qs, "confirm", false, v)
+
+	v.Check(filter.Status == "" || validator.PermittedValue(filter.Status, data.MovieStatuses...), "status", "must be one of draft, published or archived")
+	v.Check(filter.YearMax >= 0, "year_max", "must not be negative")
+	// Удаление всего каталога одним запросом почти наверняка ошибка.
+	v.Check(filter.Title != "" || len(filter.Genres) > 0 || filter.IMDbID != "" || filter.TMDBID != 0 || filter.Status != "" || filter.YearMax != 0,
+		"filter", "must contain at least one condition")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	if !confirm {
+		_, metadata, err := app.models.Movies.GetAll(r.Context(), filter, data.Filters{Page: 1, PageSize: 1, Sort: "id", SortSafelist: []string{"id"}})
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"dry_run": true, "matched": metadata.TotalRecords}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	deleted, err := app.deleteMoviesInBatches(r, filter)
+	if err != nil {
+		app.contextGetLogger(r).PrintInfo("bulk movie delete interrupted", map[string]string{
+			"deleted": strconv.Itoa(deleted),
+		})
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"dry_run": false, "deleted": deleted}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Метод deleteMoviesInBatches() удаляет подходящие под filter фильмы пакетами и
+// возвращает количество удаленных фильмов (в том числе при ошибке). Пакеты
+// выбираются по возрастанию id с курсором после последнего удаленного фильма,
+// поэтому каждый следующий запрос не просматривает уже обработанные строки.
+func (app *application) deleteMoviesInBatches(r *http.Request, filter data.MovieFilter) (int, error) {
+	ctx := r.Context()
+	filters := data.Filters{
+		Page:         1,
+		PageSize:     deleteBatchSize,
+		Sort:         "id",
+		SortSafelist: []string{"id"},
+		Cursor:       &data.Cursor{Sort: "id", Value: "0"},
+	}
+
+	deleted := 0
+	for {
+		var (
+			batch []*data.Movie
+			ids   []string
+		)
+		err := app.models.WithTx(ctx, func(models data.Models) error {
+			var err error
+			batch, _, err = models.Movies.GetAll(ctx, filter, filters)
+			if err != nil || len(batch) == 0 {
+				return err
+			}
+
+			ids, err = deleteMovies(ctx, models, batch)
+			if err != nil {
+				return err
+			}
+
+			return models.AuditEvents.Insert(ctx, newAuditEvent(r, data.AuditMoviesDeleted, app.adminActor(r), map[string]string{
+				"filter":    r.URL.Query().Encode(),
+				"movie_ids": strings.Join(ids, ","),
+			}))
+		})
+		if err != nil {
+			return deleted, err
+		}
+
+		deleted += len(ids)
+		if len(batch) < deleteBatchSize {
+			return deleted, nil
+		}
+
+		last := batch[len(batch)-1].ID
+		filters.Cursor = &data.Cursor{Sort: "id", Value: strconv.FormatInt(last, 10), ID: last}
+	}
+}
+
+// Функция deleteMovies() удаляет фильмы и возвращает их идентификаторы для журнала
+// аудита. Фильмы, которые уже успели удалить другим запросом, пропускаются.
+func deleteMovies(ctx context.Context, models data.Models, movies []*data.Movie) ([]string, error) {
+	ids := make([]string, 0, len(movies))
+	for _, movie := range movies {
+		err := models.Movies.Delete(ctx, movie.ID)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			continue
+		case err != nil:
+			return nil, err
+		}
+		ids = append(ids, strconv.FormatInt(movie.ID, 10))
+	}
+	return ids, nil
+}
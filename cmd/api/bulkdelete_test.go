@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/data"
+)
+
+func TestDeleteMoviesByFilter(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	for _, movie := range []string{
+		`{"title":"Stagecoach","year":1939,"runtime":"96 mins","genres":["western"]}`,
+		`{"title":"Red River","year":1948,"runtime":"133 mins","genres":["western"]}`,
+		`{"title":"Unforgiven","year":1992,"runtime":"130 mins","genres":["western"]}`,
+		`{"title":"Casablanca","year":1942,"runtime":"102 mins","genres":["drama"]}`,
+	} {
+		code, _, _ := ts.do(t, http.MethodPost, "/v1/movies", movie, adminHeader())
+		assert.Equal(t, code, http.StatusCreated)
+	}
+
+	code, _, _ := ts.do(t, http.MethodDelete, "/v1/movies?genres=western&year_max=1950", "", nil)
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	code, _, body := ts.do(t, http.MethodDelete, "/v1/movies", "", adminHeader())
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, `"filter": "must contain at least one condition"`)
+
+	// Без confirm=true фильмы только подсчитываются.
+	code, _, body = ts.do(t, http.MethodDelete, "/v1/movies?genres=western&year_max=1950", "", adminHeader())
+	assert.Equal(t, code, http.StatusOK)
+	resp := decodeJSON[struct {
+		DryRun  bool `json:"dry_run"`
+		Matched int
+	}](t, body)
+	assert.Equal(t, resp.DryRun, true)
+	assert.Equal(t, resp.Matched, 2)
+
+	_, _, body = ts.get(t, "/v1/movies")
+	assert.Equal(t, decodeJSON[struct{ Metadata data.Metadata }](t, body).Metadata.TotalRecords, 4)
+
+	code, _, body = ts.do(t, http.MethodDelete, "/v1/movies?genres=western&year_max=1950&confirm=true", "", adminHeader())
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, decodeJSON[struct{ Deleted int }](t, body).Deleted, 2)
+
+	_, _, body = ts.get(t, "/v1/movies?sort=title")
+	remaining := decodeJSON[struct{ Movies []data.Movie }](t, body)
+	assert.Equal(t, len(remaining.Movies), 2)
+	assert.Equal(t, remaining.Movies[0].Title, "Casablanca")
+	assert.Equal(t, remaining.Movies[1].Title, "Unforgiven")
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/jsonlog"
+)
+
+// Определяем собственный тип contextKey с базовым типом string, чтобы ключи
+// нашего приложения не пересекались с ключами других пакетов в контексте запроса.
+type contextKey string
+
+const (
+	requestIDContextKey  = contextKey("requestID")
+	loggerContextKey     = contextKey("logger")
+	signatureContextKey  = contextKey("signatureKeyID")
+	apiVersionContextKey = contextKey("apiVersion")
+	adminAuthContextKey  = contextKey("adminAuth")
+)
+
+// Метод contextSetRequestID() возвращает копию запроса с идентификатором запроса
+// в контексте.
+func (app *application) contextSetRequestID(r *http.Request, requestID string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, requestID)
+	return r.WithContext(ctx)
+}
+
+// Метод contextGetRequestID() возвращает идентификатор текущего запроса или пустую
+// строку, если он не был установлен.
+func (app *application) contextGetRequestID(r *http.Request) string {
+	requestID, _ := r.Context().Value(requestIDContextKey).(string)
+	return requestID
+}
+
+// Метод contextSetLogger() возвращает копию запроса с логгером запроса в контексте.
+func (app *application) contextSetLogger(r *http.Request, logger *jsonlog.Logger) *http.Request {
+	ctx := context.WithValue(r.Context(), loggerContextKey, logger)
+	return r.WithContext(ctx)
+}
+
+// Метод contextGetLogger() возвращает логгер текущего запроса, который включает
+// корреляционные поля во все записи. Если логгер запроса не установлен (например,
+// вне цепочки middleware), возвращается общий логгер приложения.
+func (app *application) contextGetLogger(r *http.Request) *jsonlog.Logger {
+	logger, ok := r.Context().Value(loggerContextKey).(*jsonlog.Logger)
+	if !ok {
+		return app.logger
+	}
+	return logger
+}
+
+// Метод contextSetSignatureKeyID() возвращает копию запроса с идентификатором ключа,
+// которым подписан запрос.
+func (app *application) contextSetSignatureKeyID(r *http.Request, keyID string) *http.Request {
+	ctx := context.WithValue(r.Context(), signatureContextKey, keyID)
+	return r.WithContext(ctx)
+}
+
+// Метод contextGetSignatureKeyID() возвращает идентификатор ключа, которым подписан
+// запрос, или пустую строку, если запрос не подписан (см. verifySignature()).
+func (app *application) contextGetSignatureKeyID(r *http.Request) string {
+	keyID, _ := r.Context().Value(signatureContextKey).(string)
+	return keyID
+}
+
+// Метод contextSetAPIVersion() возвращает копию запроса с версией API, к которой
+// он обращается.
+func (app *application) contextSetAPIVersion(r *http.Request, version string) *http.Request {
+	ctx := context.WithValue(r.Context(), apiVersionContextKey, version)
+	return r.WithContext(ctx)
+}
+
+// Метод contextGetAPIVersion() возвращает версию API текущего запроса (например,
+// "v1") или пустую строку, если запрос не относится к версионированному API.
+func (app *application) contextGetAPIVersion(r *http.Request) string {
+	version, _ := r.Context().Value(apiVersionContextKey).(string)
+	return version
+}
+
+// Результат проверки учетных данных администратора, сохраненный в контексте запроса.
+type adminAuth struct {
+	result    adminAuthResult
+	remaining time.Duration
+}
+
+// Метод contextSetAdminAuth() возвращает копию запроса с результатом проверки
+// учетных данных администратора.
+func (app *application) contextSetAdminAuth(r *http.Request, result adminAuthResult, remaining time.Duration) *http.Request {
+	ctx := context.WithValue(r.Context(), adminAuthContextKey, adminAuth{result: result, remaining: remaining})
+	return r.WithContext(ctx)
+}
+
+// Метод contextGetAdminAuth() возвращает результат проверки учетных данных
+// администратора (см. authenticate()). Если проверка не выполнялась, клиент
+// считается не передавшим учетные данные.
+func (app *application) contextGetAdminAuth(r *http.Request) (adminAuthResult, time.Duration) {
+	auth, ok := r.Context().Value(adminAuthContextKey).(adminAuth)
+	if !ok {
+		return adminAuthMissing, 0
+	}
+	return auth.result, auth.remaining
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/jsonlog"
+)
+
+// Функция warnPoolConfig() предупреждает о настройках пула соединений, которые
+// не имеют смысла: database/sql молча уменьшает число свободных соединений до
+// максимального числа открытых, а время простоя больше времени жизни соединения
+// ни на что не влияет. Для SQLite пул всегда состоит из одного соединения.
+func warnPoolConfig(cfg config, logger *jsonlog.Logger) {
+	if cfg.db.driver == "sqlite" {
+		return
+	}
+
+	if cfg.db.maxOpenConns > 0 && cfg.db.maxIdleConns > cfg.db.maxOpenConns {
+		logger.PrintWarn("db-max-idle-conns exceeds db-max-open-conns, extra idle connections will never be kept", map[string]string{
+			"max_idle_conns": strconv.Itoa(cfg.db.maxIdleConns),
+			"max_open_conns": strconv.Itoa(cfg.db.maxOpenConns),
+		})
+	}
+
+	maxIdleTime, err := time.ParseDuration(cfg.db.maxIdleTime)
+	if err == nil && cfg.db.connMaxLifetime > 0 && maxIdleTime > cfg.db.connMaxLifetime {
+		logger.PrintWarn("db-max-idle-time exceeds db-conn-max-lifetime and has no effect", map[string]string{
+			"max_idle_time":     maxIdleTime.String(),
+			"conn_max_lifetime": cfg.db.connMaxLifetime.String(),
+		})
+	}
+}
+
+// Тип poolProbe периодически проверяет статистику пулов соединений и логирует
+// переход пула в насыщенное состояние и выход из него. Пул считается насыщенным,
+// если заняты все соединения или с прошлой проверки запросы ждали соединения.
+type poolProbe struct {
+	logger    *jsonlog.Logger
+	last      map[string]sql.DBStats
+	saturated map[string]bool
+}
+
+func newPoolProbe(logger *jsonlog.Logger) *poolProbe {
+	return &poolProbe{
+		logger:    logger,
+		last:      make(map[string]sql.DBStats),
+		saturated: make(map[string]bool),
+	}
+}
+
+// Метод check() сравнивает статистику stats с предыдущей проверкой. Метод
+// вызывается из одной задачи планировщика, поэтому блокировка не нужна.
+func (p *poolProbe) check(stats map[string]sql.DBStats) {
+	for pool, s := range stats {
+		last := p.last[pool]
+		p.last[pool] = s
+
+		waits := s.WaitCount - last.WaitCount
+		saturated := waits > 0 || (s.MaxOpenConnections > 0 && s.InUse >= s.MaxOpenConnections)
+
+		switch {
+		case saturated && !p.saturated[pool]:
+			p.logger.PrintWarn("database pool saturated", map[string]string{
+				"pool":          pool,
+				"in_use":        strconv.Itoa(s.InUse),
+				"max_open":      strconv.Itoa(s.MaxOpenConnections),
+				"waits":         strconv.FormatInt(waits, 10),
+				"wait_duration": (s.WaitDuration - last.WaitDuration).String(),
+			})
+		case !saturated && p.saturated[pool]:
+			p.logger.PrintInfo("database pool recovered", map[string]string{
+				"pool":   pool,
+				"in_use": strconv.Itoa(s.InUse),
+			})
+		}
+		p.saturated[pool] = saturated
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/jsonlog"
+)
+
+func TestPoolProbe(t *testing.T) {
+	var buf bytes.Buffer
+	probe := newPoolProbe(jsonlog.New(&buf, jsonlog.LevelInfo))
+
+	probe.check(map[string]sql.DBStats{"primary": {MaxOpenConnections: 2, InUse: 1}})
+	assert.Equal(t, buf.String(), "")
+
+	// Все соединения заняты: предупреждение пишется только при переходе
+	// в насыщенное состояние.
+	probe.check(map[string]sql.DBStats{"primary": {MaxOpenConnections: 2, InUse: 2}})
+	probe.check(map[string]sql.DBStats{"primary": {MaxOpenConnections: 2, InUse: 2}})
+	assert.Equal(t, strings.Count(buf.String(), `"message":"database pool saturated"`), 1)
+
+	probe.check(map[string]sql.DBStats{"primary": {MaxOpenConnections: 2, InUse: 0}})
+	assert.StringContains(t, buf.String(), `"message":"database pool recovered"`)
+
+	// Ожидание соединения с прошлой проверки тоже означает насыщение.
+	buf.Reset()
+	probe.check(map[string]sql.DBStats{"primary": {MaxOpenConnections: 2, WaitCount: 3, WaitDuration: time.Second}})
+	assert.StringContains(t, buf.String(), `"waits":"3"`)
+	assert.StringContains(t, buf.String(), `"wait_duration":"1s"`)
+}
+
+func TestWarnPoolConfig(t *testing.T) {
+	var buf bytes.Buffer
+	logger := jsonlog.New(&buf, jsonlog.LevelInfo)
+
+	var cfg config
+	cfg.db.driver = "pq"
+	cfg.db.maxOpenConns = 10
+	cfg.db.maxIdleConns = 10
+	cfg.db.maxIdleTime = "15m"
+	warnPoolConfig(cfg, logger)
+	assert.Equal(t, buf.String(), "")
+
+	cfg.db.maxIdleConns = 25
+	cfg.db.connMaxLifetime = 5 * time.Minute
+	warnPoolConfig(cfg, logger)
+	assert.StringContains(t, buf.String(), "db-max-idle-conns exceeds db-max-open-conns")
+	assert.StringContains(t, buf.String(), "db-max-idle-time exceeds db-conn-max-lifetime")
+}
@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/i18n"
+	"greenlight.andreyklimov.net/internal/validator"
 )
 
 func (app *application) logError(r *http.Request, err error) {
-	// Use the PrintError() method to log the error message, and include the current
-	// request method and URL as properties in the log entry.
-	app.logger.PrintError(err, map[string]string{
-	"request_method": r.Method,
+	// Use the PrintError() method of the request-scoped logger to log the error
+	// message. It already carries the request ID, method and path, so we only add
+	// the full URL (including the query string) as a property.
+	app.contextGetLogger(r).PrintError(err, map[string]string{
 	"request_url": r.URL.String(),
 	})
 	}
@@ -19,7 +28,17 @@ func (app *application) logError(r *http.Request, err error) {
 // messages to the client with a given status code. Note that we're using an any
 // type for the message parameter, rather than just a string type, as this gives us
 // more flexibility over the values that we can include in the response.
+//
+// Сообщение (строка или карта сообщений) переводится на язык из заголовка
+// Accept-Language, если для него есть каталог переводов (см. пакет i18n).
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
+	language := errorLanguage(w, r)
+	switch m := message.(type) {
+	case string:
+		message = i18n.Translate(language, m)
+	case map[string]string:
+		message = translateMessages(language, m)
+	}
 	env := envelope{"error": message}
 	// Write the response using the writeJSON() helper. If this happens to return an
 	// error then log it, and fall back to sending the client an empty response with a
@@ -35,12 +54,45 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 // unexpected problem at runtime. It logs the detailed error message, then uses the
 // errorResponse() helper to send a 500 Internal Server Error status code and JSON
 // response (containing a generic error message) to the client.
+//
+// Если к моменту ошибки истек срок обработки запроса (см. middleware timeout()),
+// ошибка, скорее всего, вызвана отменой запроса к базе данных, поэтому клиент
+// получает 503 Service Unavailable вместо 500.
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		app.requestTimeoutResponse(w, r, err)
+		return
+	}
+	if errors.Is(err, data.ErrCircuitOpen) {
+		app.databaseUnavailableResponse(w, r)
+		return
+	}
 	app.logError(r, err)
 	message := "the server encountered a problem and could not process your request"
 	app.errorResponse(w, r, http.StatusInternalServerError, message)
 }
 
+// Метод panicResponse() используется middleware recoverPanic. Он записывает в лог
+// ошибку вместе со стеком вызовов, снятым в момент паники, отдельным полем "stack".
+// Если приложение запущено с флагом -debug, стек также включается в тело ответа.
+func (app *application) panicResponse(w http.ResponseWriter, r *http.Request, err error, stack []byte) {
+	app.contextGetLogger(r).PrintError(err, map[string]string{
+		"request_url": r.URL.String(),
+		"stack":       string(stack),
+	})
+
+	env := envelope{"error": "the server encountered a problem and could not process your request"}
+	if app.config.debug {
+		env["stack"] = strings.Split(strings.TrimSpace(string(stack)), "\n")
+	}
+
+	err = app.writeJSON(w, http.StatusInternalServerError, env, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
+
 // The notFoundResponse() method will be used to send a 404 Not Found status code and
 // JSON response to the client.
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
@@ -59,10 +111,45 @@ func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Reques
 	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
 }
 
-// Обратите внимание, что параметр errors здесь имеет тип map[string]string,
-// который точно такой же, как и errors map, содержащийся в нашем типе Validator.
-func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+// Метод failedValidationResponse() отправляет ошибки валидатора в двух формах: в поле
+// error — карта "поле — сообщение", как и раньше, а в поле error_details — подробные
+// описания ошибок с кодом правила, его параметрами и проверенным значением, по
+// которым клиенты могут сформировать собственные сообщения. Сообщения переводятся,
+// как и в errorResponse().
+func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator) {
+	language := errorLanguage(w, r)
+	details := make([]validator.FieldError, len(v.Details))
+	for i, e := range v.Details {
+		e.Message = i18n.Translate(language, e.Message)
+		details[i] = e
+	}
+	env := envelope{"error": translateMessages(language, v.Errors), "error_details": details}
+	err := app.writeJSON(w, http.StatusUnprocessableEntity, env, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
+
+// Метод invalidStatusTransitionResponse() используется, когда фильм нельзя перевести
+// из текущего статуса в запрошенный.
+func (app *application) invalidStatusTransitionResponse(w http.ResponseWriter, r *http.Request, from, to string) {
+	message := fmt.Sprintf("cannot change movie status from %s to %s", from, to)
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+// Метод invalidTenantResponse() используется, если заголовок X-Tenant-ID содержит
+// некорректный или неизвестный идентификатор арендатора.
+func (app *application) invalidTenantResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid or unknown tenant in the X-Tenant-ID header"
+	app.errorResponse(w, r, http.StatusBadRequest, message)
+}
+
+// Метод unsupportedAPIVersionResponse() используется, если заголовок Accept
+// запрашивает неизвестную версию API.
+func (app *application) unsupportedAPIVersionResponse(w http.ResponseWriter, r *http.Request, version string) {
+	message := fmt.Sprintf("API version %s is not supported", version)
+	app.errorResponse(w, r, http.StatusNotAcceptable, message)
 }
 
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
@@ -70,10 +157,94 @@ func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
-func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+// Метод constraintViolationResponse() используется, когда данные прошли валидацию
+// в приложении, но были отклонены ограничением CHECK в базе данных.
+func (app *application) constraintViolationResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record violates a database constraint"
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+}
+
+// Метод duplicateExternalIDResponse() используется, когда фильм с таким же внешним
+// идентификатором (IMDb или TMDB) уже существует. Ошибка сообщается клиенту как
+// ошибка валидации соответствующего поля.
+func (app *application) duplicateExternalIDResponse(w http.ResponseWriter, r *http.Request, err error) {
+	v := validator.New()
+	switch {
+	case errors.Is(err, data.ErrDuplicateIMDbID):
+		v.AddFieldError(validator.FieldError{Field: "imdb_id", Rule: validator.Rule{Code: "already_exists"}, Message: "a movie with this IMDb ID already exists"})
+	default:
+		v.AddFieldError(validator.FieldError{Field: "tmdb_id", Rule: validator.Rule{Code: "already_exists"}, Message: "a movie with this TMDB ID already exists"})
+	}
+	app.failedValidationResponse(w, r, v)
+}
+
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	// Заголовок Retry-After сообщает клиенту, через сколько секунд (не меньше одной)
+	// имеет смысл повторить запрос.
+	w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(retryAfter), 1)))
 	// Устанавливаем сообщение "rate limit exceeded"
 	message := "rate limit exceeded"
 	// Вызываем вспомогательную функцию errorResponse() для отправки клиенту
 	// ответа 429 Too Many Requests с сообщением.
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
-}
\ No newline at end of file
+}
+
+func (app *application) invalidAdminCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	// Заголовок WWW-Authenticate сообщает клиенту, что ожидается аутентификация Basic Auth.
+	w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
+	message := "invalid or missing admin credentials"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// Метод authenticationLockedResponse() используется, когда попытки аутентификации
+// временно заблокированы после нескольких неудач подряд.
+func (app *application) authenticationLockedResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(retryAfter), 1)))
+	message := "too many failed authentication attempts, please try again later"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+func (app *application) invalidSignatureResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid, expired or replayed request signature"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// Метод requestTimeoutResponse() используется, когда обработка запроса не уложилась
+// в отведенное время.
+func (app *application) requestTimeoutResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.contextGetLogger(r).PrintWarn("request timed out", map[string]string{
+		"error":   err.Error(),
+		"timeout": app.config.requestTimeout.String(),
+	})
+	message := "the server took too long to process your request"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
+// Метод databaseUnavailableResponse() используется, пока автоматический выключатель
+// базы данных разомкнут. Заголовок Retry-After сообщает, когда выключатель пропустит
+// следующий пробный запрос.
+func (app *application) databaseUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	_, retryAfter := app.models.CircuitBreakerStatus()
+	w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(retryAfter), 1)))
+	message := "the database is temporarily unavailable, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
+// Функция errorLanguage() выбирает язык сообщений об ошибке по заголовку
+// Accept-Language и сообщает его клиенту в заголовке Content-Language.
+func errorLanguage(w http.ResponseWriter, r *http.Request) string {
+	language := i18n.Match(acceptedLanguages(r))
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Language", language)
+	return language
+}
+
+// Функция translateMessages() возвращает копию карты сообщений, переведенных на
+// язык language.
+func translateMessages(language string, messages map[string]string) map[string]string {
+	translated := make(map[string]string, len(messages))
+	for key, message := range messages {
+		translated[key] = i18n.Translate(language, message)
+	}
+	return translated
+}
@@ -2,15 +2,43 @@ package main
 
 import (
 	"net/http"
+
+	"greenlight.andreyklimov.net/internal/data"
 )
 
 func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	// Если автоматический выключатель базы данных разомкнут, сервис продолжает
+	// работать, но запросы к базе отклоняются.
+	status := "available"
+	circuit, _ := app.models.CircuitBreakerStatus()
+	if circuit == data.CircuitOpen {
+	status = "degraded"
+	}
+	// Статистика пулов соединений помогает диагностировать их исчерпание.
+	database := map[string]any{
+	"circuit_breaker": circuit,
+	}
+	if stats := app.models.PoolStats(); stats != nil {
+	pools := make(map[string]any, len(stats))
+	for pool, s := range stats {
+	pools[pool] = map[string]any{
+	"max_open": s.MaxOpenConnections,
+	"open": s.OpenConnections,
+	"in_use": s.InUse,
+	"idle": s.Idle,
+	"wait_count": s.WaitCount,
+	"wait_duration": s.WaitDuration.String(),
+	}
+	}
+	database["pools"] = pools
+	}
 	env := envelope{
-	"status": "available",
+	"status": status,
 	"system_info": map[string]string{
 	"environment": app.config.env,
 	"version": version,
 	},
+	"database": database,
 	}
 	err := app.writeJSON(w, http.StatusOK, env, nil)
 	if err != nil {
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/data"
+)
+
+func TestHealthcheck(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, header, body := ts.get(t, "/v1/healthcheck")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/json")
+
+	resp := decodeJSON[struct {
+		Status     string            `json:"status"`
+		SystemInfo map[string]string `json:"system_info"`
+	}](t, body)
+	assert.Equal(t, resp.Status, "available")
+	assert.Equal(t, resp.SystemInfo["environment"], "testing")
+}
+
+func TestHealthcheckPoolStats(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NilError(t, err)
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(3)
+
+	app := newTestApplication(t)
+	app.models = data.NewSQLiteModels(data.NewDB(db, app.logger, 0), 0)
+	ts := newTestServer(t, app.routes())
+
+	code, _, body := ts.get(t, "/v1/healthcheck")
+	assert.Equal(t, code, http.StatusOK)
+	resp := decodeJSON[struct {
+		Database struct {
+			Pools map[string]struct {
+				MaxOpen int `json:"max_open"`
+				InUse   int `json:"in_use"`
+			}
+		}
+	}](t, body)
+	assert.Equal(t, resp.Database.Pools["primary"].MaxOpen, 3)
+
+	code, header, body := ts.do(t, http.MethodGet, "/metrics", "", adminHeader())
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, header.Get("Content-Type"), "text/plain")
+	assert.StringContains(t, body, "# TYPE greenlight_db_open_connections gauge\n")
+	assert.StringContains(t, body, `greenlight_db_max_open_connections{pool="primary"} 3`+"\n")
+
+	// Без базы данных статистики пулов нет.
+	app.models = data.NewMockModels()
+	_, _, body = ts.get(t, "/v1/healthcheck")
+	assert.Equal(t, strings.Contains(body, `"pools"`), false)
+}
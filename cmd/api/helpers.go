@@ -1,13 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io" 
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"greenlight.andreyklimov.net/internal/validator"
 	"net/url"
@@ -29,31 +36,170 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 // Define an envelope type.
 type envelope map[string]any
 
+// jsonBuffer — буфер для кодирования ответа вместе с привязанным к нему
+// json.Encoder. Они переиспользуются через пул, чтобы не выделять память заново
+// на каждый ответ (у Encoder есть собственный буфер для отступов).
+type jsonBuffer struct {
+	bytes.Buffer
+	enc *json.Encoder
+}
+
+var jsonBufferPool = sync.Pool{
+	New: func() any {
+		buf := &jsonBuffer{}
+		buf.enc = json.NewEncoder(&buf.Buffer)
+		return buf
+	},
+}
+
+// Буферы больше этого размера (например, после очень большого списка) не
+// возвращаются в пул, чтобы не удерживать лишнюю память.
+const maxPooledBufferSize = 256 << 10
+
 // Change the data parameter to have the type envelope instead of any.
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
-	js, err := json.MarshalIndent(data, "", "\t")
+	buf, err := app.encodeJSON(data)
 	if err != nil {
 		return err
 	}
-	js = append(js, '\n')
+	defer releaseJSONBuffer(buf)
+
 	for key, value := range headers {
 		w.Header()[key] = value
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+	return nil
+}
+
+// The encodeJSON() helper encodes the envelope exactly as it is sent in responses,
+// including the trailing newline, into a pooled buffer. The caller must return
+// the buffer with releaseJSONBuffer() once it is no longer used. With -json-compact
+// (the default in production) the output is not indented.
+func (app *application) encodeJSON(data envelope) (*jsonBuffer, error) {
+	buf := jsonBufferPool.Get().(*jsonBuffer)
+	buf.Reset()
+
+	if app.config.json.compact {
+		buf.enc.SetIndent("", "")
+	} else {
+		buf.enc.SetIndent("", "\t")
+	}
+	err := buf.enc.Encode(data)
+	if err != nil {
+		releaseJSONBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+func releaseJSONBuffer(buf *jsonBuffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	jsonBufferPool.Put(buf)
+}
+
+// Вспомогательный метод writeCacheableJSON() отправляет ответ 200 OK с заголовками
+// Cache-Control, ETag и (если lastModified не нулевое) Last-Modified. ETag вычисляется
+// по содержимому ответа. Если условные заголовки запроса (If-None-Match или
+// If-Modified-Since) показывают, что у клиента уже есть актуальная версия, вместо
+// тела отправляется ответ 304 Not Modified.
+func (app *application) writeCacheableJSON(w http.ResponseWriter, r *http.Request, data envelope, lastModified time.Time) error {
+	buf, err := app.encodeJSON(data)
+	if err != nil {
+		return err
+	}
+	defer releaseJSONBuffer(buf)
+	js := buf.Bytes()
+
+	sum := sha256.Sum256(js)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("Cache-Control", app.cacheControl(r))
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
 	w.Write(js)
 	return nil
 }
 
+// Метод cacheControl() возвращает значение заголовка Cache-Control для кешируемых
+// ответов. Если max-age не задан, клиенты и CDN должны проверять актуальность
+// ответа при каждом запросе (используя ETag и Last-Modified). Ответы на запросы
+// с учетными данными могут содержать неопубликованные фильмы, поэтому их нельзя
+// сохранять ни в общих кешах, ни у клиента.
+func (app *application) cacheControl(r *http.Request) string {
+	if app.hasCredentials(r) {
+		return "private, no-store"
+	}
+	if app.config.httpCache.maxAge <= 0 {
+		return "no-cache"
+	}
+	return fmt.Sprintf("public, max-age=%d", int(app.config.httpCache.maxAge.Seconds()))
+}
+
+// Функция notModified() проверяет условные заголовки запроса. Согласно RFC 9110,
+// если передан If-None-Match, заголовок If-Modified-Since игнорируется.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// Для условных GET-запросов используется слабое сравнение, поэтому
+			// префикс W/ не учитывается.
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err == nil && !lastModified.Truncate(time.Second).After(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
 	// Use http.MaxBytesReader() to limit the size of the request body to 1MB.
 	maxBytes := 1_048_576
 	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	// Тело может быть сжато gzip (это удобно для больших запросов). Размер после
+	// распаковки ограничен тем же пределом, что и несжатое тело, поэтому маленький
+	// архив, распаковывающийся в гигабайты (zip-бомба), отклоняется, как только
+	// распакованные данные превысят предел.
+	var body io.Reader = r.Body
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return errors.New("body is not valid gzip data")
+		}
+		defer zr.Close()
+		body = http.MaxBytesReader(w, zr, int64(maxBytes))
+	default:
+		return fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
 	// Initialize the json.Decoder, and call the DisallowUnknownFields() method on it
 	// before decoding. This means that if the JSON from the client now includes any
 	// field which cannot be mapped to the target destination, the decoder will return
 	// an error instead of just ignoring the field.
-	dec := json.NewDecoder(r.Body)
+	dec := json.NewDecoder(body)
 	dec.DisallowUnknownFields()
 	// Decode the request body to the destination.
 	err := dec.Decode(dst)
@@ -89,6 +235,8 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 		// size limit of 1MB and we return a clear error message.
 		case errors.As(err, &maxBytesError):
 			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		case errors.Is(err, gzip.ErrChecksum), errors.Is(err, gzip.ErrHeader):
+			return errors.New("body is not valid gzip data")
 		case errors.As(err, &invalidUnmarshalError):
 			panic(err)
 		default:
@@ -156,3 +304,110 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
     return i
 }
 
+
+// Вспомогательная функция readTime() получает из строки запроса время в формате
+// RFC 3339 или дату в формате ISO 8601 ("2006-01-02", полночь UTC). Если ключ не
+// найден, возвращает нулевое время. Если значение нельзя разобрать, записывает
+// сообщение об ошибке в переданный экземпляр Validator.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return time.Time{}
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t
+		}
+	}
+	v.AddError(key, "must be a valid RFC 3339 timestamp")
+	return time.Time{}
+}
+
+// Функция dateLayouts() возвращает форматы дат, которые принимаются от клиента
+// с языками languages (см. acceptedLanguages()): ISO 8601 принимается всегда, а
+// кроме него — принятый в языке клиента формат с точками или косой чертой.
+// Порядок дня и месяца в "01/02/2006" зависит от языка, поэтому такой формат
+// определяется первым подходящим языком.
+func dateLayouts(languages []string) []string {
+	layouts := []string{time.DateOnly, "02.01.2006"}
+	for _, language := range languages {
+		switch {
+		case language == "en-us" || language == "en":
+			return append(layouts, "01/02/2006")
+		case strings.HasPrefix(language, "en-"), language == "fr", language == "es", language == "it", language == "pt":
+			return append(layouts, "02/01/2006")
+		}
+	}
+	return layouts
+}
+
+// Метод readDate() получает из строки запроса дату (без времени, полночь UTC) в одном
+// из форматов dateLayouts() для языков клиента. Если ключ не найден, возвращает
+// нулевое время. Некорректное значение записывается в валидатор.
+func (app *application) readDate(qs url.Values, key string, languages []string, v *validator.Validator) time.Time {
+	s := strings.TrimSpace(qs.Get(key))
+	if s == "" {
+		return time.Time{}
+	}
+	for _, layout := range dateLayouts(languages) {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t
+		}
+	}
+	v.AddError(key, "must be a valid date (YYYY-MM-DD)")
+	return time.Time{}
+}
+
+// Метод readBool() возвращает логическое значение из строки запроса или значение
+// по умолчанию, если ключ не указан. Кроме значений, которые понимает
+// strconv.ParseBool(), принимаются yes/no и on/off (так флажки отправляют HTML-формы).
+// Некорректное значение записывается в валидатор.
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	switch strings.ToLower(s) {
+	case "yes", "on":
+		return true
+	case "no", "off":
+		return false
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+	return b
+}
+
+// Метод background() запускает функцию fn в отдельной горутине. Паника в fn
+// записывается в лог и не завершает приложение, а при остановке сервера serve()
+// дожидается завершения всех запущенных так задач.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.PrintError(fmt.Errorf("%s", err), nil)
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// Метод featureEnabled() сообщает, включена ли функция name для клиента, отправившего
+// запрос. Постепенное включение (percentage) распределяет клиентов по IP-адресу.
+func (app *application) featureEnabled(r *http.Request, name string) bool {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return app.features.Enabled(name, ip)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/fixtures"
+	"greenlight.andreyklimov.net/internal/validator"
+)
+
+// discardResponseWriter — http.ResponseWriter, который отбрасывает ответ, чтобы
+// бенчмарки измеряли только кодирование.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+// Функция testMovies() возвращает n сгенерированных фильмов.
+func testMovies(n int) []*data.Movie {
+	g := fixtures.NewGenerator(1)
+	movies := make([]*data.Movie, n)
+	for i := range movies {
+		movies[i] = g.Movie()
+		movies[i].ID = int64(i + 1)
+		movies[i].Version = 1
+	}
+	return movies
+}
+
+func TestWriteJSONCompact(t *testing.T) {
+	app := newTestApplication(t)
+	env := envelope{"movie": testMovies(1)[0]}
+
+	indented := httptest.NewRecorder()
+	err := app.writeJSON(indented, http.StatusOK, env, nil)
+	assert.NilError(t, err)
+
+	app.config.json.compact = true
+	compact := httptest.NewRecorder()
+	err = app.writeJSON(compact, http.StatusOK, env, nil)
+	assert.NilError(t, err)
+
+	want, err := json.Marshal(env)
+	assert.NilError(t, err)
+	assert.Equal(t, compact.Body.String(), string(want)+"\n")
+
+	// Отступы влияют только на форму, но не на содержимое.
+	assert.Equal(t, json.Valid(indented.Body.Bytes()), true)
+	assert.Equal(t, indented.Body.Len() > compact.Body.Len(), true)
+}
+
+// BenchmarkWriteJSON сравнивает кодирование страницы из 100 фильмов прежним
+// способом (json.MarshalIndent() на каждый ответ) с writeJSON() в обоих режимах.
+func BenchmarkWriteJSON(b *testing.B) {
+	env := envelope{"movies": testMovies(100), "metadata": data.Metadata{CurrentPage: 1, PageSize: 100}}
+	w := &discardResponseWriter{header: make(http.Header)}
+
+	b.Run("MarshalIndent", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			js, err := json.MarshalIndent(env, "", "\t")
+			if err != nil {
+				b.Fatal(err)
+			}
+			w.Write(append(js, '\n'))
+		}
+	})
+
+	for _, compact := range []bool{false, true} {
+		name := "Indented"
+		if compact {
+			name = "Compact"
+		}
+		b.Run(name, func(b *testing.B) {
+			app := &application{}
+			app.config.json.compact = compact
+			b.ReportAllocs()
+			for range b.N {
+				err := app.writeJSON(w, http.StatusOK, env, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkListMovies измеряет полный запрос GET /v1/movies со страницей из
+// 100 фильмов в обоих режимах.
+func BenchmarkListMovies(b *testing.B) {
+	for _, compact := range []bool{false, true} {
+		name := "Indented"
+		if compact {
+			name = "Compact"
+		}
+		b.Run(name, func(b *testing.B) {
+			app := newTestApplication(b)
+			app.config.json.compact = compact
+			err := fixtures.Generate(context.Background(), app.models, fixtures.NewGenerator(1), 100, 100, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			routes := app.routes()
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies?page_size=100", nil)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				rr := httptest.NewRecorder()
+				routes.ServeHTTP(rr, r)
+				if rr.Code != http.StatusOK {
+					b.Fatalf("got status %d", rr.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestReadDate(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		value     string
+		languages []string
+		want      string
+	}{
+		{"2024-03-01", nil, "2024-03-01"},
+		{"01.03.2024", []string{"ru"}, "2024-03-01"},
+		{"03/01/2024", []string{"en-us", "en"}, "2024-03-01"},
+		{"01/03/2024", []string{"en-gb", "en"}, "2024-03-01"},
+		{"01/03/2024", []string{"de"}, ""},
+		{"2024-02-30", nil, ""},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		got := app.readDate(url.Values{"date": {tt.value}}, "date", tt.languages, v)
+		if tt.want == "" {
+			assert.Equal(t, v.Valid(), false)
+			continue
+		}
+		assert.Equal(t, v.Valid(), true)
+		assert.Equal(t, got.Format(time.DateOnly), tt.want)
+	}
+}
+
+func TestReadBool(t *testing.T) {
+	app := newTestApplication(t)
+
+	for value, want := range map[string]bool{"true": true, "1": true, "on": true, "Yes": true, "false": false, "off": false, "no": false} {
+		v := validator.New()
+		assert.Equal(t, app.readBool(url.Values{"flag": {value}}, "flag", !want, v), want)
+		assert.Equal(t, v.Valid(), true)
+	}
+
+	v := validator.New()
+	assert.Equal(t, app.readBool(url.Values{"flag": {"maybe"}}, "flag", true, v), true)
+	assert.Equal(t, v.Errors["flag"], "must be a boolean value")
+}
+
+func gzipBody(t *testing.T, s string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(s))
+	assert.NilError(t, err)
+	assert.NilError(t, zw.Close())
+	return buf.String()
+}
+
+func TestReadJSONGzip(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	header := http.Header{"Content-Encoding": {"gzip"}}
+
+	code, _, body := ts.do(t, http.MethodPost, "/v1/movies", gzipBody(t, `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`), header)
+	assert.Equal(t, code, http.StatusCreated)
+	assert.StringContains(t, body, `"title": "Moana"`)
+
+	code, _, body = ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana"}`, header)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, body, "body is not valid gzip data")
+
+	// Несколько килобайт сжатых данных распаковываются больше чем в предел 1 МБ.
+	bomb := gzipBody(t, `{"title":"`+strings.Repeat("a", 2<<20)+`"}`)
+	assert.Equal(t, len(bomb) < 16<<10, true)
+	code, _, body = ts.do(t, http.MethodPost, "/v1/movies", bomb, header)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, body, "body must not be larger than 1048576 bytes")
+
+	code, _, body = ts.do(t, http.MethodPost, "/v1/movies", `{}`, http.Header{"Content-Encoding": {"br"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, body, `unsupported Content-Encoding \"br\"`)
+}
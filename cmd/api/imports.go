@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/metadata"
+	"greenlight.andreyklimov.net/internal/validator"
+)
+
+// Обработчик importMovieHandler() создает фильм по сведениям из внешнего каталога
+// (OMDb или TMDB, см. -metadata-provider), найденным по идентификатору IMDb
+// из параметра imdb_id. Эндпоинт зарегистрирован как POST /v1/movie-imports, а не
+// /v1/movies/import-external: httprouter не допускает статический сегмент рядом
+// с параметром /v1/movies/:id.
+func (app *application) importMovieHandler(w http.ResponseWriter, r *http.Request) {
+	imdbID := app.readString(r.URL.Query(), "imdb_id", "")
+
+	v := validator.New()
+	v.Check(imdbID != "", "imdb_id", "must be provided")
+	v.Check(imdbID == "" || validator.Matches(imdbID, validator.IMDbIDRX), "imdb_id", "must be a valid IMDb ID")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	external, err := app.metadata.Lookup(r.Context(), imdbID)
+	if err != nil {
+		switch {
+		case errors.Is(err, metadata.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	movie := &data.Movie{
+		Title:   external.Title,
+		Year:    external.Year,
+		Runtime: data.Runtime(external.Runtime),
+		Genres:  external.Genres,
+		IMDbID:  imdbID,
+		Status:  data.MoviePublished,
+	}
+
+	// Внешние каталоги могут указывать больше жанров, чем мы допускаем; оставляем
+	// первые (основные).
+	if len(movie.Genres) > 5 {
+		movie.Genres = movie.Genres[:5]
+	}
+
+	// Данные внешнего каталога проверяются так же, как данные от клиента: например,
+	// у фильма может не быть известной продолжительности.
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Movies.Insert(r.Context(), movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrCheckViolation):
+			app.constraintViolationResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateIMDbID), errors.Is(err, data.ErrDuplicateTMDBID):
+			app.duplicateExternalIDResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/%s/movies/%d", app.contextGetAPIVersion(r), movie.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
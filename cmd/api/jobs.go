@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/scheduler"
+)
+
+// Метод scheduleJobs() регистрирует периодические задачи обслуживания. Интервал
+// каждой задачи можно изменить или обнулить (отключив задачу) флагом -job-interval.
+// Планировщик выполняет только необязательные задачи: очистка карт, которая
+// защищает память (authLockout, signatureVerifier), выполняется в самих типах.
+func (app *application) scheduleJobs(s *scheduler.Scheduler) {
+	if app.config.features.file != "" {
+		s.Add(scheduler.Job{
+			Name:     "reload_feature_flags",
+			Interval: app.jobInterval("reload_feature_flags", 30*time.Second),
+			Run: func(ctx context.Context) error {
+				reloaded, err := app.features.Reload()
+				if err != nil {
+					return err
+				}
+				if reloaded {
+					app.logger.PrintInfo("reloaded feature flags", map[string]string{
+						"file": app.config.features.file,
+					})
+				}
+				return nil
+			},
+		})
+	}
+
+	// В демонстрационном режиме пулов соединений нет, и проверять нечего.
+	if app.models.PoolStats() != nil {
+		probe := newPoolProbe(app.logger)
+		s.Add(scheduler.Job{
+			Name:     "probe_db_pool",
+			Interval: app.jobInterval("probe_db_pool", 30*time.Second),
+			Run: func(ctx context.Context) error {
+				probe.check(app.models.PoolStats())
+				return nil
+			},
+		})
+	}
+
+	s.Add(scheduler.Job{
+		Name:     "publish_scheduled_movies",
+		Interval: app.jobInterval("publish_scheduled_movies", time.Minute),
+		Run:      app.publishScheduledMovies,
+	})
+
+	if app.config.audit.retention > 0 {
+		s.Add(scheduler.Job{
+			Name:     "prune_audit_events",
+			Interval: app.jobInterval("prune_audit_events", time.Hour),
+			Run: func(ctx context.Context) error {
+				cutoff := time.Now().Add(-app.config.audit.retention)
+				deleted, err := app.models.AuditEvents.DeleteBefore(ctx, cutoff, 1000)
+				if err != nil {
+					return err
+				}
+				if deleted > 0 {
+					app.logger.PrintInfo("pruned audit events", map[string]string{
+						"deleted": strconv.FormatInt(deleted, 10),
+					})
+				}
+				return nil
+			},
+		})
+	}
+}
+
+// Метод publishScheduledMovies() публикует черновики, время публикации которых
+// наступило. О каждой публикации делается запись в журнале аудита, а кеш ответов
+// сбрасывается, чтобы фильмы сразу появились в каталоге.
+func (app *application) publishScheduledMovies(ctx context.Context) error {
+	movies, err := app.models.Movies.PublishDue(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(movies) == 0 {
+		return nil
+	}
+
+	for _, movie := range movies {
+		id := strconv.FormatInt(movie.ID, 10)
+		app.logger.PrintInfo("published scheduled movie", map[string]string{
+			"movie_id": id,
+			"title":    movie.Title,
+		})
+
+		err := app.models.AuditEvents.Insert(ctx, &data.AuditEvent{
+			Action:  data.AuditMoviePublished,
+			Actor:   "scheduler",
+			Details: map[string]string{"movie_id": id, "title": movie.Title},
+		})
+		// Фильм уже опубликован, поэтому ошибка записи аудита не прерывает задачу.
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	}
+
+	if app.cache != nil {
+		_, err = app.cache.Incr(ctx, responseCacheGenerationKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Метод jobInterval() возвращает интервал задачи name, заданный флагом
+// -job-interval, или значение по умолчанию.
+func (app *application) jobInterval(name string, defaultInterval time.Duration) time.Duration {
+	if interval, ok := app.config.scheduler.intervals[name]; ok {
+		return interval
+	}
+	return defaultInterval
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/data"
+)
+
+// recordingAuditEvents запоминает события аудита, чтобы тесты могли их проверить.
+type recordingAuditEvents struct {
+	data.MockAuditEventModel
+	mu     sync.Mutex
+	events []*data.AuditEvent
+}
+
+func (m *recordingAuditEvents) Insert(ctx context.Context, event *data.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *recordingAuditEvents) actions() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	actions := make([]string, len(m.events))
+	for i, event := range m.events {
+		actions[i] = event.Action
+	}
+	return actions
+}
+
+func TestPublishScheduledMovies(t *testing.T) {
+	app := newTestApplication(t)
+	audit := &recordingAuditEvents{}
+	app.models.AuditEvents = audit
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute)
+	movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: data.MovieDraft, PublishAt: &past}
+	assert.NilError(t, app.models.Movies.Insert(ctx, movie))
+
+	assert.NilError(t, app.publishScheduledMovies(ctx))
+
+	stored, err := app.models.Movies.Get(ctx, movie.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, stored.Status, data.MoviePublished)
+	assert.DeepEqual(t, audit.actions(), []string{data.AuditMoviePublished})
+	assert.Equal(t, audit.events[0].Actor, "scheduler")
+
+	// Повторный запуск ничего не публикует и не пишет в журнал аудита.
+	assert.NilError(t, app.publishScheduledMovies(ctx))
+	assert.Equal(t, len(audit.actions()), 1)
+}
+
+func TestPublishAtAdminOnly(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, _, body := ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"],"publish_at":"2000-01-01T00:00:00Z"}`, nil)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, body, `admin-only key \"publish_at\"`)
+
+	code, _, _ = ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, nil)
+	assert.Equal(t, code, http.StatusCreated)
+	for _, body := range []string{`{"publish_at":"2000-01-01T00:00:00Z"}`, `{"publish_at":""}`} {
+		code, _, _ = ts.do(t, http.MethodPatch, "/v1/movies/1", body, nil)
+		assert.Equal(t, code, http.StatusBadRequest)
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	code, _, body = ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Up","year":2009,"runtime":"96 mins","genres":["animation"],"publish_at":"`+future+`"}`, adminHeader())
+	assert.Equal(t, code, http.StatusCreated)
+	assert.StringContains(t, body, `"status": "draft"`)
+	code, _, _ = ts.do(t, http.MethodPatch, "/v1/movies/2", `{"publish_at":""}`, adminHeader())
+	assert.Equal(t, code, http.StatusOK)
+}
+
+// Смена статуса администратором попадает в журнал аудита так же, как публикация
+// по расписанию, а успешный вход записывается не чаще раза в час.
+func TestManualStatusChangesAudited(t *testing.T) {
+	app := newTestApplication(t)
+	audit := &recordingAuditEvents{}
+	app.models.AuditEvents = audit
+	ts := newTestServer(t, app.routes())
+
+	code, _, _ := ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, nil)
+	assert.Equal(t, code, http.StatusCreated)
+
+	for _, action := range []string{"unpublish", "publish", "archive"} {
+		code, _, _ = ts.do(t, http.MethodPost, "/v1/movies/1/"+action, "", adminHeader())
+		assert.Equal(t, code, http.StatusOK)
+	}
+	app.wg.Wait()
+
+	// События пишутся в фоне, поэтому их порядок не определен.
+	actions := audit.actions()
+	slices.Sort(actions)
+	assert.DeepEqual(t, actions, []string{
+		data.AuditAdminLogin,
+		data.AuditMovieArchived,
+		data.AuditMoviePublished,
+		data.AuditMovieUnpublished,
+	})
+	for _, event := range audit.events {
+		assert.Equal(t, event.Actor, testAdminUsername)
+		if event.Action == data.AuditMovieArchived {
+			assert.Equal(t, event.Details["from"], data.MoviePublished)
+		}
+	}
+}
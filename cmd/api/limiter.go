@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Классы маршрутов, у каждого из которых свой лимит частоты запросов (см. routes()):
+// поиск по каталогу, изменяющие запросы и маршруты, проверяющие учетные данные
+// администратора. Остальные маршруты относятся к классу по умолчанию.
+const (
+	limiterDefaultClass = "default"
+	limiterSearchClass  = "search"
+	limiterWriteClass   = "write"
+	limiterAuthClass    = "auth"
+)
+
+var limiterClasses = []string{limiterDefaultClass, limiterSearchClass, limiterWriteClass, limiterAuthClass}
+
+// rateLimitSettings задает среднюю скорость (запросов в секунду) и максимальный
+// всплеск запросов.
+type rateLimitSettings struct {
+	rps   float64
+	burst int
+}
+
+// limiterConfig содержит настройки ограничителя частоты запросов. Значения rps и
+// burst относятся к классу по умолчанию и к классам, для которых в classes нет
+// собственных настроек.
+type limiterConfig struct {
+	rps     float64
+	burst   int
+	enabled bool
+	classes map[string]rateLimitSettings
+}
+
+// Метод limitFor() возвращает настройки ограничителя для класса маршрутов class.
+func (c limiterConfig) limitFor(class string) rateLimitSettings {
+	if limit, ok := c.classes[class]; ok {
+		return limit
+	}
+	return rateLimitSettings{rps: c.rps, burst: c.burst}
+}
+
+// Метод setClass() разбирает значение флага -limiter-class в формате
+// "класс=rps:burst", например "search=5:10".
+func (c *limiterConfig) setClass(s string) error {
+	class, value, ok := strings.Cut(s, "=")
+	if !ok || !slices.Contains(limiterClasses, class) {
+		return fmt.Errorf("expected class=rps:burst with class one of %s", strings.Join(limiterClasses, ", "))
+	}
+
+	rps, burst, ok := strings.Cut(value, ":")
+	if !ok {
+		return fmt.Errorf("expected class=rps:burst, got %q", s)
+	}
+	var (
+		limit rateLimitSettings
+		err   error
+	)
+	limit.rps, err = strconv.ParseFloat(rps, 64)
+	if err != nil || limit.rps < 0 {
+		return fmt.Errorf("invalid rps %q", rps)
+	}
+	limit.burst, err = strconv.Atoi(burst)
+	if err != nil || limit.burst < 1 {
+		return fmt.Errorf("invalid burst %q", burst)
+	}
+
+	if c.classes == nil {
+		c.classes = make(map[string]rateLimitSettings)
+	}
+	c.classes[class] = limit
+	return nil
+}
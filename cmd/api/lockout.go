@@ -0,0 +1,142 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Тип authLockout отслеживает неудачные попытки аутентификации по IP-адресу и по
+// имени пользователя. После maxFailures неудачных попыток подряд ключ блокируется
+// на baseLockout, а каждая следующая неудача удваивает время блокировки (но не
+// больше maxLockout). Успешная аутентификация сбрасывает счетчики.
+type authLockout struct {
+	maxFailures int
+	baseLockout time.Duration
+	maxLockout  time.Duration
+
+	mu      sync.Mutex
+	records map[string]*failureRecord
+	// Время последней записи об успешном входе в журнал аудита (см. shouldAuditLogin()).
+	logins map[string]time.Time
+	// Время последней очистки карт (см. prune()).
+	prunedAt time.Time
+}
+
+// Успешный вход с Basic Auth повторяется в каждом запросе, поэтому в журнал аудита
+// он записывается не чаще одного раза за этот интервал для пары имя — IP-адрес.
+const loginAuditInterval = time.Hour
+
+type failureRecord struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func newAuthLockout(maxFailures int, baseLockout, maxLockout time.Duration) *authLockout {
+	return &authLockout{
+		maxFailures: maxFailures,
+		baseLockout: baseLockout,
+		maxLockout:  maxLockout,
+		records:     make(map[string]*failureRecord),
+		logins:      make(map[string]time.Time),
+	}
+}
+
+// Метод prune() удаляет записи без неудачных попыток за последние сутки и
+// устаревшие записи об успешных входах, чтобы карты не росли бесконечно (в том
+// числе из-за подделанных заголовков аутентификации). Он вызывается при добавлении
+// записей не чаще раза в минуту и не зависит от планировщика задач. Вызывающий код
+// должен удерживать l.mu.
+func (l *authLockout) prune() {
+	if time.Since(l.prunedAt) < time.Minute {
+		return
+	}
+	l.prunedAt = time.Now()
+
+	for key, record := range l.records {
+		if time.Since(record.lastFailure) > 24*time.Hour && time.Now().After(record.lockedUntil) {
+			delete(l.records, key)
+		}
+	}
+	for key, recorded := range l.logins {
+		if time.Since(recorded) > loginAuditInterval {
+			delete(l.logins, key)
+		}
+	}
+}
+
+// Метод lockedFor() возвращает оставшееся время блокировки для первого из
+// заблокированных ключей или 0, если ни один ключ не заблокирован.
+func (l *authLockout) lockedFor(keys ...string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var remaining time.Duration
+	for _, key := range keys {
+		record, ok := l.records[key]
+		if !ok {
+			continue
+		}
+		remaining = max(remaining, time.Until(record.lockedUntil))
+	}
+	return max(remaining, 0)
+}
+
+// Метод fail() регистрирует неудачную попытку для всех ключей и возвращает
+// максимальное время блокировки, назначенное в результате (или 0).
+func (l *authLockout) fail(keys ...string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune()
+
+	var lockout time.Duration
+	for _, key := range keys {
+		record, ok := l.records[key]
+		if !ok {
+			record = &failureRecord{}
+			l.records[key] = record
+		}
+
+		record.failures++
+		record.lastFailure = time.Now()
+
+		if l.maxFailures > 0 && record.failures >= l.maxFailures {
+			// Время блокировки удваивается с каждой попыткой сверх допустимой.
+			duration := l.baseLockout
+			for i := l.maxFailures; i < record.failures && duration < l.maxLockout; i++ {
+				duration *= 2
+			}
+			duration = min(duration, l.maxLockout)
+
+			record.lockedUntil = time.Now().Add(duration)
+			lockout = max(lockout, duration)
+		}
+	}
+	return lockout
+}
+
+// Метод succeed() сбрасывает счетчики неудачных попыток для всех ключей.
+func (l *authLockout) succeed(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		delete(l.records, key)
+	}
+}
+
+// Метод shouldAuditLogin() сообщает, нужно ли записать успешный вход с ключом key
+// в журнал аудита: не чаще одного раза за loginAuditInterval.
+func (l *authLockout) shouldAuditLogin(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune()
+
+	if recorded, ok := l.logins[key]; ok && time.Since(recorded) < loginAuditInterval {
+		return false
+	}
+	l.logins[key] = time.Now()
+	return true
+}
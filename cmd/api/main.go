@@ -3,16 +3,29 @@ package main
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"expvar"
 	"flag"
 	"fmt"
-	"log"
-	"net/http"
+	"io"
+	"log/slog"
+	"math/rand/v2"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/lib/pq"
+	"greenlight.andreyklimov.net/internal/cache"
 	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/featureflags"
 	"greenlight.andreyklimov.net/internal/jsonlog"
+	"greenlight.andreyklimov.net/internal/metadata"
+	"greenlight.andreyklimov.net/internal/storage"
+	_ "modernc.org/sqlite"
 )
 
 const version = "1.0.0"
@@ -20,97 +33,584 @@ const version = "1.0.0"
 // Добавляем поля maxOpenConns, maxIdleConns и maxIdleTime для хранения
 // параметров конфигурации пула подключений.
 type config struct {
-	port int
-	env  string
-	db   struct {
+	port  int
+	env   string
+	debug bool
+	// Демонстрационный режим: модели в памяти вместо базы данных.
+	demo     bool
+	logLevel jsonlog.Level
+	// Максимальное время обработки одного запроса.
+	requestTimeout time.Duration
+	// Максимальное время корректного завершения работы сервера.
+	shutdownTimeout time.Duration
+	// Настройки записи логов в файл с ротацией. Если путь не задан,
+	// логи пишутся только в стандартный поток вывода.
+	logFile struct {
+		path       string
+		maxSize    int
+		interval   time.Duration
+		maxBackups int
+		maxAge     time.Duration
+	}
+	db struct {
+		driver       string
 		dsn          string
+		readDSN      string
 		maxOpenConns int
 		maxIdleConns int
 		maxIdleTime  string
+		// Максимальное время жизни соединения, после которого оно закрывается
+		// и открывается заново (0 — без ограничения).
+		connMaxLifetime time.Duration
+		// Запросы, выполняющиеся дольше этого порога, логируются с уровнем WARN.
+		slowQueryThreshold time.Duration
+		// Тайм-аут каждого запроса на стороне приложения и значение statement_timeout,
+		// которое устанавливается для соединений на стороне PostgreSQL.
+		queryTimeout     time.Duration
+		statementTimeout time.Duration
+		// Максимальное число подготовленных выражений в кеше каждого пула.
+		statementCacheSize int
+		// Число ошибок базы данных подряд, после которого размыкается автоматический
+		// выключатель, и время, в течение которого он остается разомкнутым.
+		breakerThreshold int
+		breakerCooldown  time.Duration
+		// Параметры повторных попыток подключения к базе данных при запуске.
+		connectRetries    int
+		connectBackoff    time.Duration
+		connectMaxBackoff time.Duration
 	}
 	// Добавляем новую структуру limiter, содержащую поля для количества запросов в секунду,
 	// максимального числа запросов в очереди (burst) и булево поле, которое можно использовать
-	// для включения/отключения ограничения запросов.
-	limiter struct {
-		rps     float64
-		burst   int
+	// для включения/отключения ограничения запросов. Для отдельных классов маршрутов
+	// можно задать собственные лимиты (см. limiterConfig).
+	limiter limiterConfig
+	// Учетные данные администратора, которые проверяются middleware requireAdmin().
+	admin struct {
+		username string
+		password string
+	}
+	// Настройки защиты от перебора паролей.
+	auth struct {
+		maxFailures int
+		lockout     time.Duration
+		maxLockout  time.Duration
+	}
+	pprof struct {
 		enabled bool
 	}
+	// Middleware, которые можно отключить при запуске. Действующие цепочки
+	// middleware выводятся в лог при запуске (см. routes()).
+	middleware struct {
+		compression bool
+		cors        bool
+		metrics     bool
+		logging     bool
+	}
+	// Источники (Origin), которым разрешены запросы из браузера.
+	cors struct {
+		trustedOrigins []string
+	}
+	// Ключи HMAC для подписи запросов межсерверными клиентами ("id:secret,...")
+	// и допустимое расхождение временной метки подписи с текущим временем.
+	signing struct {
+		keys    string
+		maxSkew time.Duration
+	}
+	// Настройки TLS. Если задан clientCAFile, сервер требует клиентские сертификаты,
+	// а клиенты с Common Name из adminSubjects считаются администраторами.
+	tls struct {
+		certFile      string
+		keyFile       string
+		clientCAFile  string
+		adminSubjects []string
+	}
+	// Настройки планировщика периодических задач. В intervals хранятся интервалы
+	// задач, переопределенные флагом -job-interval.
+	scheduler struct {
+		enabled   bool
+		intervals map[string]time.Duration
+	}
+	// Срок хранения записей журнала аудита.
+	audit struct {
+		retention time.Duration
+	}
+	// Если public равно false, чтение каталога фильмов требует аутентификации.
+	catalog struct {
+		public bool
+	}
+	// Настройки LRU-кеша для запросов отдельных фильмов.
+	cache struct {
+		movieSize int
+		movieTTL  time.Duration
+		// Время жизни ответов в общем кеше ответов в Redis.
+		responseTTL time.Duration
+	}
+	// Значение max-age для заголовка Cache-Control кешируемых ответов.
+	httpCache struct {
+		maxAge time.Duration
+	}
+	// Настройки подключения к Redis. Если адрес не задан, Redis не используется.
+	redis struct {
+		addr     string
+		password string
+		db       int
+		prefix   string
+	}
+	// Настройки внешнего каталога фильмов для импорта и ограничение частоты
+	// запросов к нему.
+	metadata struct {
+		provider string
+		apiKey   string
+		rps      float64
+		burst    int
+	}
+	// Настройки хранилища загруженных файлов (постеров фильмов).
+	storage struct {
+		backend       string
+		dir           string
+		baseURL       string
+		posterMaxSize int64
+		s3            storage.S3Config
+	}
+	// Путь к JSON-файлу с флагами функций (см. пакет featureflags).
+	features struct {
+		file string
+	}
+	// Допустимые арендаторы (заголовок X-Tenant-ID). Пустой список разрешает
+	// любой корректный идентификатор.
+	tenants []string
+	// Компактный JSON в ответах (без отступов).
+	json struct {
+		compact bool
+	}
+	// Файлы с фильмами, которые загружаются при запуске (см. пакет fixtures).
+	seed []string
 }
 
 // Измените поле logger, чтобы оно имело тип *jsonlog.Logger вместо *log.Logger.
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
+	config      config
+	logger      *jsonlog.Logger
+	models      data.Models
+	cache       *cache.Client
+	authLockout *authLockout
+	signatures  *signatureVerifier
+	storage     storage.Storage
+	metadata    *metadata.Client
+	features    *featureflags.Set
+	// Группа ожидания фоновых задач, запущенных методом background().
+	wg sync.WaitGroup
 }
 
 func main() {
 	var cfg config
 	flag.IntVar(&cfg.port, "port", 4000, "API server port")
+	flag.DurationVar(&cfg.requestTimeout, "request-timeout", 10*time.Second, "Maximum time to process a request (0 disables)")
+	flag.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 30*time.Second, "Maximum time to wait for requests and background tasks on shutdown")
 	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
+	// Флаг -log-level разбирается сразу в значение jsonlog.Level. Если передано
+	// неизвестное имя уровня, пакет flag выведет ошибку и справку по флагам.
+	cfg.logLevel = jsonlog.LevelInfo
+	flag.Func("log-level", "Minimum log level (debug|info|warn|error)", func(s string) error {
+		level, err := jsonlog.ParseLevel(s)
+		if err != nil {
+			return err
+		}
+		cfg.logLevel = level
+		return nil
+	})
+	flag.StringVar(&cfg.logFile.path, "log-file", "", "Also write logs to this file (rotated)")
+	flag.IntVar(&cfg.logFile.maxSize, "log-file-max-size", 100, "Rotate the log file after this many megabytes (0 disables)")
+	flag.DurationVar(&cfg.logFile.interval, "log-file-rotate-interval", 24*time.Hour, "Rotate the log file after this interval (0 disables)")
+	flag.IntVar(&cfg.logFile.maxBackups, "log-file-max-backups", 7, "Maximum number of rotated log files to keep (0 keeps all)")
+	flag.DurationVar(&cfg.logFile.maxAge, "log-file-max-age", 30*24*time.Hour, "Maximum age of rotated log files to keep (0 keeps all)")
+	flag.BoolVar(&cfg.debug, "debug", false, "Include panic stack traces in error responses")
+	flag.BoolVar(&cfg.demo, "demo", false, "Run without a database, keeping movies in memory")
+	// По умолчанию используется драйвер lib/pq, а -db-driver=pgx включает pgxpool.
+	// С -db-driver=sqlite вместо PostgreSQL используется файл SQLite (DSN — путь
+	// к файлу), что удобно для локальной разработки.
+	cfg.db.driver = "pq"
+	cfg.storage.backend = "local"
+	flag.Func("db-driver", "Database driver (pq|pgx|sqlite)", func(s string) error {
+		if s != "pq" && s != "pgx" && s != "sqlite" {
+			return fmt.Errorf("unknown database driver %q", s)
+		}
+		cfg.db.driver = s
+		return nil
+	})
 	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("GREENLIGHT_DB_DSN"), "PostgreSQL DSN")
+	flag.StringVar(&cfg.db.readDSN, "db-read-dsn", os.Getenv("GREENLIGHT_DB_READ_DSN"), "PostgreSQL read replica DSN (optional)")
 	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")
 	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
 	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max connection idle time")
+	flag.DurationVar(&cfg.db.connMaxLifetime, "db-conn-max-lifetime", 0, "PostgreSQL max connection lifetime (0 disables)")
+	flag.IntVar(&cfg.db.connectRetries, "db-connect-retries", 5, "Number of times to retry connecting to PostgreSQL at startup")
+	flag.DurationVar(&cfg.db.connectBackoff, "db-connect-backoff", time.Second, "Initial delay between PostgreSQL connection attempts")
+	flag.DurationVar(&cfg.db.connectMaxBackoff, "db-connect-max-backoff", 30*time.Second, "Maximum delay between PostgreSQL connection attempts")
+	flag.DurationVar(&cfg.db.queryTimeout, "db-query-timeout", 3*time.Second, "Timeout for each database query (0 disables)")
+	flag.DurationVar(&cfg.db.statementTimeout, "db-statement-timeout", 5*time.Second, "PostgreSQL statement_timeout for pool connections (0 disables)")
+	flag.IntVar(&cfg.db.statementCacheSize, "db-statement-cache-size", 256, "Maximum number of cached prepared statements per pool (0 disables)")
+	flag.IntVar(&cfg.db.breakerThreshold, "db-breaker-threshold", 5, "Consecutive database errors before the circuit breaker opens (0 disables)")
+	flag.DurationVar(&cfg.db.breakerCooldown, "db-breaker-cooldown", 10*time.Second, "How long the circuit breaker stays open before probing the database again")
+	flag.DurationVar(&cfg.db.slowQueryThreshold, "db-slow-query-threshold", 500*time.Millisecond, "Log queries slower than this duration (0 disables)")
 	// Создаем флаги командной строки для чтения значений настроек в структуру config.
 	// Обратите внимание, что по умолчанию для параметра 'enabled' установлено значение true.
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+	flag.Func("limiter-class", "Rate limit for a route class as class=rps:burst (classes: default, search, write, auth; repeatable)", cfg.limiter.setClass)
+	flag.BoolVar(&cfg.middleware.compression, "compression-enabled", true, "Compress responses with gzip when the client accepts it")
+	flag.BoolVar(&cfg.middleware.cors, "cors-enabled", true, "Enable CORS headers for trusted origins")
+	flag.BoolVar(&cfg.middleware.metrics, "metrics-enabled", true, "Collect request metrics published at /debug/vars")
+	flag.BoolVar(&cfg.middleware.logging, "request-log-enabled", true, "Log every completed request")
+	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
+		cfg.cors.trustedOrigins = strings.Fields(val)
+		return nil
+	})
+	flag.IntVar(&cfg.cache.movieSize, "cache-movie-size", 0, "Maximum number of movies in the in-memory cache (0 disables)")
+	flag.DurationVar(&cfg.cache.movieTTL, "cache-movie-ttl", time.Minute, "Time-to-live of cached movies")
+	flag.DurationVar(&cfg.cache.responseTTL, "cache-response-ttl", 30*time.Second, "Time-to-live of cached GET responses in Redis (0 disables)")
+	flag.DurationVar(&cfg.httpCache.maxAge, "http-cache-max-age", 0, "Cache-Control max-age for cacheable GET responses (0 means no-cache)")
+	flag.StringVar(&cfg.redis.addr, "redis-addr", os.Getenv("GREENLIGHT_REDIS_ADDR"), "Redis address (host:port); enables shared caching and rate limiting")
+	flag.StringVar(&cfg.redis.password, "redis-password", os.Getenv("GREENLIGHT_REDIS_PASSWORD"), "Redis password")
+	flag.IntVar(&cfg.redis.db, "redis-db", 0, "Redis database number")
+	flag.StringVar(&cfg.redis.prefix, "redis-prefix", "greenlight:", "Prefix for all Redis keys")
+	flag.Func("metadata-provider", "External movie metadata provider for imports (omdb|tmdb)", func(s string) error {
+		if s != "omdb" && s != "tmdb" {
+			return fmt.Errorf("unknown metadata provider %q", s)
+		}
+		cfg.metadata.provider = s
+		return nil
+	})
+	flag.StringVar(&cfg.metadata.apiKey, "metadata-api-key", os.Getenv("GREENLIGHT_METADATA_API_KEY"), "API key (OMDb) or read access token (TMDB) for the metadata provider")
+	flag.Float64Var(&cfg.metadata.rps, "metadata-rps", 1, "Maximum requests per second to the metadata provider")
+	flag.IntVar(&cfg.metadata.burst, "metadata-burst", 5, "Maximum burst of requests to the metadata provider")
+	flag.Func("storage", "File storage backend (local|s3)", func(s string) error {
+		if s != "local" && s != "s3" {
+			return fmt.Errorf("unknown storage backend %q", s)
+		}
+		cfg.storage.backend = s
+		return nil
+	})
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "uploads", "Directory for uploaded files (local storage)")
+	flag.StringVar(&cfg.storage.baseURL, "storage-base-url", "", "Public base URL of uploaded files (default: served by the API for local storage, bucket URL for S3)")
+	flag.Int64Var(&cfg.storage.posterMaxSize, "poster-max-size", 5*1024*1024, "Maximum poster upload size in bytes")
+	flag.StringVar(&cfg.storage.s3.Endpoint, "s3-endpoint", "", "S3 endpoint URL (default: AWS endpoint for the region)")
+	flag.StringVar(&cfg.storage.s3.Bucket, "s3-bucket", "", "S3 bucket for uploaded files")
+	flag.StringVar(&cfg.storage.s3.Region, "s3-region", os.Getenv("AWS_REGION"), "S3 region")
+	flag.StringVar(&cfg.storage.s3.AccessKey, "s3-access-key", os.Getenv("AWS_ACCESS_KEY_ID"), "S3 access key ID")
+	flag.StringVar(&cfg.storage.s3.SecretKey, "s3-secret-key", os.Getenv("AWS_SECRET_ACCESS_KEY"), "S3 secret access key")
+	flag.StringVar(&cfg.admin.username, "admin-username", "admin", "Admin username for protected endpoints")
+	flag.StringVar(&cfg.admin.password, "admin-password", os.Getenv("GREENLIGHT_ADMIN_PASSWORD"), "Admin password for protected endpoints")
+	flag.IntVar(&cfg.auth.maxFailures, "auth-max-failures", 5, "Failed authentication attempts before a temporary lockout (0 disables)")
+	flag.DurationVar(&cfg.auth.lockout, "auth-lockout", time.Minute, "Initial lockout duration, doubled on each further failure")
+	flag.DurationVar(&cfg.auth.maxLockout, "auth-max-lockout", time.Hour, "Maximum lockout duration")
+	flag.StringVar(&cfg.signing.keys, "signing-keys", os.Getenv("GREENLIGHT_SIGNING_KEYS"), "HMAC request signing keys as id:secret pairs, comma-separated (signed requests are authenticated as admin)")
+	flag.DurationVar(&cfg.signing.maxSkew, "signing-max-skew", 5*time.Minute, "Maximum allowed age of a request signature timestamp")
+	flag.StringVar(&cfg.tls.certFile, "tls-cert", "", "TLS certificate file (enables HTTPS)")
+	flag.StringVar(&cfg.tls.keyFile, "tls-key", "", "TLS private key file")
+	flag.StringVar(&cfg.tls.clientCAFile, "tls-client-ca", "", "CA bundle for verifying client certificates (requires a client certificate on every request)")
+	flag.Func("tls-admin-subjects", "Comma-separated client certificate Common Names granted admin access", func(s string) error {
+		cfg.tls.adminSubjects = append(cfg.tls.adminSubjects, strings.Split(s, ",")...)
+		return nil
+	})
+	flag.BoolVar(&cfg.scheduler.enabled, "scheduler-enabled", true, "Run periodic jobs: publishing movies at publish_at, reloading -feature-flags, pruning audit events and probing DB pools (all stop when disabled)")
+	cfg.scheduler.intervals = make(map[string]time.Duration)
+	flag.Func("job-interval", "Override a job interval as name=duration, 0 disables the job (repeatable); jobs: publish_scheduled_movies, reload_feature_flags, prune_audit_events, probe_db_pool", func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("expected name=duration, got %q", s)
+		}
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		cfg.scheduler.intervals[name] = interval
+		return nil
+	})
+	flag.DurationVar(&cfg.audit.retention, "audit-retention", 90*24*time.Hour, "Delete audit events older than this (0 keeps them forever)")
+	flag.BoolVar(&cfg.catalog.public, "catalog-public", true, "Allow anonymous access to /v1/movies endpoints (when false, reads and writes require admin credentials)")
+	flag.Func("tenants", "Comma-separated list of allowed tenant IDs (default: any valid ID)", func(s string) error {
+		for _, tenant := range strings.Split(s, ",") {
+			if !data.ValidTenantID(tenant) {
+				return fmt.Errorf("invalid tenant ID %q", tenant)
+			}
+			cfg.tenants = append(cfg.tenants, tenant)
+		}
+		return nil
+	})
+	flag.Func("seed", "Comma-separated fixture files (.json or .csv) to load at startup", func(s string) error {
+		cfg.seed = append(cfg.seed, strings.Split(s, ",")...)
+		return nil
+	})
+	flag.StringVar(&cfg.features.file, "feature-flags", "", "Path to a JSON file with feature flags (reloaded when it changes)")
+	flag.BoolVar(&cfg.json.compact, "json-compact", false, "Write compact JSON responses without indentation (on in production unless set explicitly)")
+	flag.BoolVar(&cfg.pprof.enabled, "pprof-enabled", true, "Enable /debug/pprof endpoints (off in production unless set explicitly)")
 	flag.Parse()
 
+	// В production эндпоинты профилирования отключены, если флаг -pprof-enabled
+	// не был указан явно в командной строке.
+	if cfg.env == "production" && !isFlagPassed("pprof-enabled") {
+		cfg.pprof.enabled = false
+	}
+	// Отступы в JSON удобны при разработке, но в production увеличивают размер
+	// ответов и время кодирования.
+	if cfg.env == "production" && !isFlagPassed("json-compact") {
+		cfg.json.compact = true
+	}
+
 	// Инициализируйте новый jsonlog.Logger, который записывает все сообщения
-	// *уровня INFO и выше* в стандартный поток вывода.
-	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
+	// уровня не ниже заданного флагом -log-level в стандартный поток вывода.
+	logger := jsonlog.New(os.Stdout, cfg.logLevel)
+
+	// Если задан файл лога, пишем записи одновременно в стандартный поток вывода
+	// и в файл с ротацией.
+	if cfg.logFile.path != "" {
+		logFile, err := jsonlog.NewRotatingFile(
+			cfg.logFile.path,
+			int64(cfg.logFile.maxSize)*1024*1024,
+			cfg.logFile.interval,
+			cfg.logFile.maxBackups,
+			cfg.logFile.maxAge,
+		)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		defer logFile.Close()
+
+		logger = jsonlog.New(io.MultiWriter(os.Stdout, logFile), cfg.logLevel)
+	}
+
+	// Делаем наш логгер логгером slog по умолчанию, чтобы записи сторонних библиотек,
+	// использующих log/slog, попадали в тот же поток в том же JSON-формате.
+	slog.SetDefault(logger.Slog())
+
+	signingKeys, err := parseSigningKeys(cfg.signing.keys)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	features, err := featureflags.Load(cfg.features.file, cfg.env)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+	// Текущие флаги видны в /debug/vars, чтобы было видно, что именно включено.
+	expvar.Publish("feature_flags", expvar.Func(func() any {
+		return features.All()
+	}))
+
+	// В демонстрационном режиме база данных не нужна: фильмы хранятся в памяти
+	// и пропадают при перезапуске.
+	var models data.Models
+	if cfg.demo {
+		models = data.NewMockModels()
+		logger.PrintInfo("demo mode enabled, using in-memory models", nil)
+	} else {
+		var closeDB func()
+		models, closeDB, err = openModels(cfg, logger)
+		if err != nil {
+			// Используйте метод PrintFatal(), чтобы записать сообщение об ошибке
+			// с уровнем FATAL и завершить работу. У нас нет дополнительных параметров
+			// для включения в запись лога, поэтому мы передаем nil как второй параметр.
+			logger.PrintFatal(err, nil)
+		}
+		defer closeDB()
+	}
+
+	// Команда seed загружает фильмы из файлов, переданных аргументами, и завершает
+	// работу: greenlight -db-dsn=... seed fixtures/movies.json
+	// Команда generate добавляет случайные фильмы для нагрузочного тестирования:
+	// greenlight -db-dsn=... generate -count 100000
+	switch flag.Arg(0) {
+	case "seed":
+		err = seedFixtures(models, logger, flag.Args()[1:])
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		return
+	case "generate":
+		err = generateMovies(models, logger, flag.Args()[1:])
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		return
+	}
+	err = seedFixtures(models, logger, cfg.seed)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	// Статистика пулов соединений с базой данных в /debug/vars считывается при
+	// каждом запросе.
+	expvar.Publish("database", expvar.Func(func() any {
+		return models.PoolStats()
+	}))
 
-	db, err := openDB(cfg)
+	app := &application{
+		config:      cfg,
+		logger:      logger,
+		models:      models,
+		authLockout: newAuthLockout(cfg.auth.maxFailures, cfg.auth.lockout, cfg.auth.maxLockout),
+		signatures:  newSignatureVerifier(signingKeys, cfg.signing.maxSkew),
+		features:    features,
+	}
+
+	switch cfg.storage.backend {
+	case "s3":
+		cfg.storage.s3.BaseURL = cfg.storage.baseURL
+		app.storage, err = storage.NewS3(cfg.storage.s3)
+	default:
+		baseURL := cfg.storage.baseURL
+		if baseURL == "" {
+			baseURL = "/v1/posters"
+		}
+		app.storage, err = storage.NewLocal(cfg.storage.dir, baseURL)
+	}
 	if err != nil {
-		// Используйте метод PrintFatal(), чтобы записать сообщение об ошибке
-		// с уровнем FATAL и завершить работу. У нас нет дополнительных параметров
-		// для включения в запись лога, поэтому мы передаем nil как второй параметр.
 		logger.PrintFatal(err, nil)
 	}
-	defer db.Close()
+
+	switch cfg.metadata.provider {
+	case "omdb":
+		app.metadata = metadata.New(metadata.NewOMDb(cfg.metadata.apiKey), cfg.metadata.rps, cfg.metadata.burst)
+	case "tmdb":
+		app.metadata = metadata.New(metadata.NewTMDB(cfg.metadata.apiKey), cfg.metadata.rps, cfg.metadata.burst)
+	}
+
+	if cfg.redis.addr != "" {
+		app.cache, err = cache.New(cfg.redis.addr, cfg.redis.password, cfg.redis.db, cfg.redis.prefix)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		defer app.cache.Close()
+
+		logger.PrintInfo("redis connection established", nil)
+	}
+
+	err = app.serve()
+	if err != nil {
+		// Используйте метод PrintFatal() для логирования ошибки и завершения работы.
+		logger.PrintFatal(err, nil)
+	}
+}
+
+// Функция openModels() подключается к базе данных (и реплике, если она задана)
+// и возвращает модели, а также функцию, закрывающую пулы соединений.
+func openModels(cfg config, logger *jsonlog.Logger) (data.Models, func(), error) {
+	warnPoolConfig(cfg, logger)
+
+	db, err := openDB(cfg, logger)
+	if err != nil {
+		return data.Models{}, nil, err
+	}
+	closeDB := func() { db.Close() }
 
 	// Аналогично, используем метод PrintInfo() для записи сообщения уровня INFO.
 	logger.PrintInfo("database connection pool established", nil)
 
-	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-	}
-
-	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.port),
-		Handler: app.routes(),
-		// Создается новый экземпляр Go log.Logger с помощью log.New(),
-		// передавая кастомный Logger в качестве первого параметра.
-		// Пустая строка и 0 указывают, что экземпляр log.Logger
-		// не должен использовать префикс или какие-либо флаги.
-		ErrorLog:     log.New(logger, "", 0),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
-	}
-
-	// Снова используем метод PrintInfo() для записи сообщения "starting server"
-	// на уровне INFO. Но на этот раз передаем карту с дополнительными параметрами
-	// (операционная среда и адрес сервера) в качестве последнего параметра.
-	logger.PrintInfo("starting server", map[string]string{
-		"addr": srv.Addr,
-		"env":  cfg.env,
-	})
+	dbWrapper := data.NewDB(db, logger, cfg.db.slowQueryThreshold)
+
+	// Если задан DSN реплики, запросы на чтение будут направляться в нее.
+	if cfg.db.readDSN != "" {
+		replica, err := openReplicaDB(cfg, logger)
+		if err != nil {
+			closeDB()
+			return data.Models{}, nil, err
+		}
+		closeDB = func() {
+			replica.Close()
+			db.Close()
+		}
+
+		dbWrapper.SetReplica(replica)
+		logger.PrintInfo("read replica connection pool established", nil)
+	}
+
+	dbWrapper.SetStatementCacheSize(cfg.db.statementCacheSize)
+
+	models := data.NewModels(dbWrapper, cfg.db.queryTimeout)
+	if cfg.db.driver == "sqlite" {
+		err = data.ApplySQLiteSchema(context.Background(), db)
+		if err != nil {
+			closeDB()
+			return data.Models{}, nil, err
+		}
+		models = data.NewSQLiteModels(dbWrapper, cfg.db.queryTimeout)
+	}
+	if cfg.db.breakerThreshold > 0 {
+		models = models.WithCircuitBreaker(cfg.db.breakerThreshold, cfg.db.breakerCooldown)
+	}
+	if cfg.cache.movieSize > 0 {
+		models = models.WithMovieCache(cfg.cache.movieSize, cfg.cache.movieTTL)
+	}
+
+	return models, closeDB, nil
+}
 
-	err = srv.ListenAndServe()
+func openDB(cfg config, logger *jsonlog.Logger) (*sql.DB, error) {
+	db, err := openPool(cfg.db.dsn, cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	// Используйте метод PrintFatal() для логирования ошибки и завершения работы.
-	logger.PrintFatal(err, nil)
+	// Проверяем соединение с базой данных. Если база данных еще не готова (например,
+	// контейнер с PostgreSQL запускается одновременно с API), повторяем попытки.
+	err = pingDB(db, cfg, logger)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
 
-func openDB(cfg config) (*sql.DB, error) {
-	db, err := sql.Open("postgres", cfg.db.dsn)
+// Функция openReplicaDB() открывает пул соединений с репликой для чтения. В отличие
+// от openDB(), недоступность реплики при запуске не является фатальной ошибкой:
+// слой данных сам переключает чтение на основную базу, пока реплика не ответит.
+func openReplicaDB(cfg config, logger *jsonlog.Logger) (*sql.DB, error) {
+	db, err := openPool(cfg.db.readDSN, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		logger.PrintWarn("read replica is not reachable, reads will fall back to the primary", map[string]string{
+			"error": err.Error(),
+		})
+	}
+	return db, nil
+}
+
+// Функция openPool() создает пул соединений для указанного DSN и применяет к нему
+// настройки пула из конфигурации. Соединение с базой данных при этом не проверяется.
+func openPool(dsn string, cfg config) (*sql.DB, error) {
+	// Используем функцию time.ParseDuration() для преобразования строки с таймаутом простоя
+	// в тип time.Duration.
+	duration, err := time.ParseDuration(cfg.db.maxIdleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.db.driver {
+	case "pgx":
+		return openPgxPool(dsn, cfg, duration)
+	case "sqlite":
+		return openSQLite(dsn)
+	}
+
+	// lib/pq передает неизвестные ему параметры строки подключения в PostgreSQL как
+	// параметры времени выполнения, поэтому statement_timeout задаем прямо в DSN.
+	if cfg.db.statementTimeout > 0 {
+		dsn, err = withRuntimeParam(dsn, "statement_timeout", strconv.FormatInt(cfg.db.statementTimeout.Milliseconds(), 10))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -123,23 +623,136 @@ func openDB(cfg config) (*sql.DB, error) {
 	// Если передано значение меньше или равное 0, ограничение не устанавливается.
 	db.SetMaxIdleConns(cfg.db.maxIdleConns)
 
-	// Используем функцию time.ParseDuration() для преобразования строки с таймаутом простоя
-	// в тип time.Duration.
-	duration, err := time.ParseDuration(cfg.db.maxIdleTime)
+	// Устанавливаем максимальное время простоя соединений.
+	db.SetConnMaxIdleTime(duration)
+
+	// Устанавливаем максимальное время жизни соединений. Если передано значение
+	// меньше или равное 0, соединения не закрываются из-за возраста.
+	db.SetConnMaxLifetime(cfg.db.connMaxLifetime)
+
+	return db, nil
+}
+
+// Функция openSQLite() открывает базу данных SQLite. Включаются внешние ключи и
+// ожидание блокировок, а время хранится в формате, который понимает SQLite.
+// SQLite допускает только одного писателя, поэтому пул ограничен одним соединением.
+func openSQLite(dsn string) (*sql.DB, error) {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	dsn += separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, err
 	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
 
-	// Устанавливаем максимальное время простоя соединений.
-	db.SetConnMaxIdleTime(duration)
+// Функция withRuntimeParam() добавляет параметр в строку подключения lib/pq.
+// DSN в формате URL сначала преобразуется в формат "ключ=значение".
+func withRuntimeParam(dsn, key, value string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var err error
+		dsn, err = pq.ParseURL(dsn)
+		if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%s %s=%s", dsn, key, value), nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Функция openPgxPool() создает пул pgxpool и оборачивает его в *sql.DB, чтобы
+// модели продолжали работать через database/sql независимо от драйвера. Свободными
+// соединениями в этом случае управляет pgxpool, поэтому в *sql.DB их число равно нулю.
+func openPgxPool(dsn string, cfg config, maxIdleTime time.Duration) (*sql.DB, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
 
-	// Проверяем соединение с базой данных.
-	err = db.PingContext(ctx)
+	if cfg.db.maxOpenConns > 0 {
+		poolConfig.MaxConns = int32(cfg.db.maxOpenConns)
+	}
+	poolConfig.MaxConnIdleTime = maxIdleTime
+	if cfg.db.connMaxLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.db.connMaxLifetime
+	}
+
+	// Устанавливаем statement_timeout для всех соединений пула, чтобы PostgreSQL сам
+	// прерывал слишком долгие запросы.
+	if cfg.db.statementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.db.statementTimeout.Milliseconds(), 10)
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		return nil, err
 	}
+
+	db := sql.OpenDB(pgxPoolConnector{Connector: stdlib.GetPoolConnector(pool), pool: pool})
+	db.SetMaxIdleConns(0)
+
 	return db, nil
 }
+
+// Тип pgxPoolConnector дополняет коннектор pgx методом Close(). Если коннектор
+// реализует io.Closer, метод sql.DB.Close() вызывает его, поэтому при закрытии
+// *sql.DB закрывается и нижележащий pgxpool.
+type pgxPoolConnector struct {
+	driver.Connector
+	pool *pgxpool.Pool
+}
+
+func (c pgxPoolConnector) Close() error {
+	c.pool.Close()
+	return nil
+}
+
+// Функция pingDB() проверяет соединение с базой данных, повторяя попытки до
+// cfg.db.connectRetries раз. Задержка между попытками растет экспоненциально
+// (но не больше cfg.db.connectMaxBackoff), а случайный разброс (jitter) не дает
+// нескольким экземплярам приложения переподключаться синхронно.
+func pingDB(db *sql.DB, cfg config, logger *jsonlog.Logger) error {
+	backoff := cfg.db.connectBackoff
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if attempt > cfg.db.connectRetries {
+			return err
+		}
+
+		// Выбираем случайную задержку в диапазоне [backoff/2, backoff*3/2).
+		delay := backoff/2 + time.Duration(rand.Int64N(int64(backoff)+1))
+
+		logger.PrintWarn("database connection failed, retrying", map[string]string{
+			"attempt":  strconv.Itoa(attempt),
+			"retries":  strconv.Itoa(cfg.db.connectRetries),
+			"retry_in": delay.String(),
+			"error":    err.Error(),
+		})
+
+		time.Sleep(delay)
+		backoff = min(backoff*2, cfg.db.connectMaxBackoff)
+	}
+}
+
+// Функция isFlagPassed() сообщает, был ли флаг с указанным именем явно задан
+// в командной строке (а не получил значение по умолчанию).
+func isFlagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
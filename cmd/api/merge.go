@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/validator"
+)
+
+// errMergeSourceNotFound возвращается из транзакции слияния, если фильм-дубликат
+// не найден.
+var errMergeSourceNotFound = errors.New("merge source not found")
+
+// Обработчик mergeMoviesHandler() объединяет фильм-дубликат (source_id) с фильмом
+// из URL: переводы дубликата переносятся на основной фильм, а сам дубликат
+// отправляется в архив. Все изменения выполняются в одной транзакции, а слияние
+// записывается в журнал аудита.
+func (app *application) mergeMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		SourceID int64 `json:"source_id"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.SourceID > 0, "source_id", "must be provided")
+	v.Check(input.SourceID != id, "source_id", "must not be the same as the target movie")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	var (
+		movie *data.Movie
+		moved int64
+	)
+
+	err = app.models.WithTx(r.Context(), func(models data.Models) error {
+		var err error
+		movie, err = models.Movies.Get(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		source, err := models.Movies.Get(r.Context(), input.SourceID)
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				return errMergeSourceNotFound
+			}
+			return err
+		}
+
+		moved, err = models.Translations.Reassign(r.Context(), source.ID, movie.ID)
+		if err != nil {
+			return err
+		}
+
+		// Архивный фильм уже скрыт от клиентов, и повторно архивировать его не нужно.
+		if source.Status != data.MovieArchived {
+			err = models.Movies.SetStatus(r.Context(), source, data.MovieArchived)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, errMergeSourceNotFound):
+			v.AddError("source_id", "must refer to an existing movie")
+			app.failedValidationResponse(w, r, v)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.recordAuditEvent(r, data.AuditMovieMerged, app.adminActor(r), map[string]string{
+		"movie_id":           strconv.FormatInt(movie.ID, 10),
+		"source_id":          strconv.FormatInt(input.SourceID, 10),
+		"translations_moved": strconv.FormatInt(moved, 10),
+	})
+
+	app.setPosterURL(movie)
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie, "translations_moved": moved}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
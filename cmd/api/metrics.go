@@ -0,0 +1,65 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Метрики запросов публикуются через expvar (см. /debug/vars). Переменные expvar
+// регистрируются один раз на процесс, поэтому они объявлены на уровне пакета, а не
+// в middleware metrics().
+var (
+	totalRequestsReceived           = expvar.NewInt("total_requests_received")
+	totalResponsesSent              = expvar.NewInt("total_responses_sent")
+	totalProcessingTimeMicroseconds = expvar.NewInt("total_processing_time_μs")
+	totalResponsesSentByStatus      = expvar.NewMap("total_responses_sent_by_status")
+	// Количество пропущенных и отклоненных ограничителем запросов по классам
+	// маршрутов (см. rateLimit()).
+	rateLimiterAllowed  = expvar.NewMap("rate_limiter_allowed")
+	rateLimiterRejected = expvar.NewMap("rate_limiter_rejected")
+)
+
+// Middleware metrics() считает полученные запросы, отправленные ответы (в том числе
+// по кодам статуса) и суммарное время обработки.
+func (app *application) metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		totalRequestsReceived.Add(1)
+
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		totalResponsesSent.Add(1)
+		totalResponsesSentByStatus.Add(strconv.Itoa(sw.status), 1)
+		totalProcessingTimeMicroseconds.Add(time.Since(start).Microseconds())
+	})
+}
+
+// Тип statusResponseWriter запоминает код статуса ответа для метрик и журнала
+// запросов. В отличие от responseRecorder, тело ответа не копируется.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sw *statusResponseWriter) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.status = status
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusResponseWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(b)
+}
+
+// Метод Unwrap() позволяет http.ResponseController получить доступ
+// к исходному http.ResponseWriter.
+func (sw *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
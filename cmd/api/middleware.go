@@ -1,15 +1,61 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
+	"greenlight.andreyklimov.net/internal/data"
 )
 
+// Middleware requestContext() присваивает каждому запросу идентификатор (берется из
+// заголовка X-Request-ID, если клиент или прокси его передали, иначе генерируется)
+// и помещает в контекст запроса логгер, который добавляет request_id, метод и путь
+// во все записи, сделанные при обработке этого запроса.
+func (app *application) requestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" || len(requestID) > 128 {
+			requestID = generateRequestID()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		logger := app.logger.With(map[string]string{
+			"request_id":     requestID,
+			"request_method": r.Method,
+			"request_path":   r.URL.Path,
+		})
+
+		r = app.contextSetRequestID(r, requestID)
+		r = app.contextSetLogger(r, logger)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Функция generateRequestID() возвращает случайный идентификатор запроса
+// из 16 байт в шестнадцатеричной записи.
+func generateRequestID() string {
+	b := make([]byte, 16)
+	// Функция rand.Read() из crypto/rand не возвращает ошибок на поддерживаемых платформах.
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Определяется отложенная функция, которая всегда выполнится в случае паники,
@@ -23,18 +69,47 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 				w.Header().Set("Connection", "close")
 
 				// Значение, возвращаемое recover(), имеет тип any, поэтому оно приводится
-				// к error с помощью fmt.Errorf(), а затем передается в вспомогательную
-				// функцию serverErrorResponse(). В свою очередь, она записывает ошибку
-				// с уровнем ERROR в наш кастомный логгер и отправляет клиенту ответ
-				// с кодом 500 Internal Server Error.
-				app.serverErrorResponse(w, r, fmt.Errorf("%s", err))
+				// к error с помощью fmt.Errorf(). Вместе со стеком вызовов, снятым в момент
+				// паники, ошибка передается во вспомогательную функцию panicResponse(),
+				// которая записывает ее в лог и отправляет клиенту ответ с кодом
+				// 500 Internal Server Error.
+				app.panicResponse(w, r, fmt.Errorf("%s", err), debug.Stack())
 			}
 		}()
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (app *application) rateLimit(next http.Handler) http.Handler {
+// Middleware timeout() ограничивает время обработки запроса значением
+// -request-timeout. Срок передается через контекст запроса до слоя данных, поэтому
+// запросы к базе отменяются, а обработчик отвечает клиенту 503 (см.
+// serverErrorResponse()).
+func (app *application) timeout(next http.Handler) http.Handler {
+	if app.config.requestTimeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), app.config.requestTimeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Middleware rateLimit() ограничивает частоту запросов с одного IP-адреса к маршрутам
+// класса class с настройками этого класса (см. limiterClass()). Состояние ограничителя
+// общее для всех маршрутов, к которым применяется возвращенное middleware, а у
+// каждого класса свой лимит.
+func (app *application) rateLimit(class string) middleware {
+	limit := app.config.limiter.limitFor(class)
+	// Ключ клиента в Redis. Для класса по умолчанию ключом остается IP-адрес.
+	sharedKey := func(ip string) string {
+		if class == limiterDefaultClass {
+			return ip
+		}
+		return class + ":" + ip
+	}
+
 	// Определяем структуру client, которая будет содержать ограничитель скорости и время последней активности для каждого клиента.
 	type client struct {
 		limiter  *rate.Limiter
@@ -64,29 +139,538 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 		}
 	}()
 
+	// Общий ограничитель в Redis использует фиксированное окно, в котором разрешено
+	// burst запросов. Длительность окна выбирается так, чтобы средняя скорость
+	// совпадала с rps, как у локального ограничителя на основе token bucket.
+	sharedWindow := time.Second
+	if limit.rps > 0 {
+		sharedWindow = time.Duration(float64(limit.burst) / limit.rps * float64(time.Second))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Выполняем проверку только в том случае, если ограничение запросов включено.
+			if app.config.limiter.enabled {
+				ip, _, err := net.SplitHostPort(r.RemoteAddr)
+				if err != nil {
+					app.serverErrorResponse(w, r, err)
+					return
+				}
+
+				// Если настроен Redis, используем общий для всех экземпляров приложения
+				// счетчик. При ошибке Redis переходим к локальному ограничителю ниже.
+				if app.cache != nil {
+					result, err := app.cache.Allow(r.Context(), sharedKey(ip), limit.burst, sharedWindow)
+					if err == nil {
+						app.setRateLimitHeaders(w, limit.burst, result.Remaining, result.Reset)
+						if !result.Allowed {
+							app.logRateLimited(r, class, ip, float64(result.Remaining), limit)
+							app.rateLimitExceededResponse(w, r, result.Reset)
+							return
+						}
+						rateLimiterAllowed.Add(class, 1)
+						next.ServeHTTP(w, r)
+						return
+					}
+					app.logError(r, err)
+				}
+
+				mu.Lock()
+				if _, found := clients[ip]; !found {
+					clients[ip] = &client{
+						// Используем значения количества запросов в секунду и burst из структуры config.
+						limiter: rate.NewLimiter(rate.Limit(limit.rps), limit.burst),
+					}
+				}
+				clients[ip].lastSeen = time.Now()
+				allowed := clients[ip].limiter.Allow()
+				// Количество оставшихся токенов нужно для заголовков X-RateLimit-*.
+				tokens := clients[ip].limiter.Tokens()
+				mu.Unlock()
+
+				// Вычисляем, через сколько корзина токенов наполнится полностью (reset)
+				// и через сколько появится хотя бы один токен (retryAfter).
+				var reset, retryAfter time.Duration
+				if limit.rps > 0 {
+					reset = time.Duration((float64(limit.burst) - tokens) / limit.rps * float64(time.Second))
+					retryAfter = time.Duration((1 - tokens) / limit.rps * float64(time.Second))
+				}
+
+				app.setRateLimitHeaders(w, limit.burst, max(int(tokens), 0), reset)
+				if !allowed {
+					app.logRateLimited(r, class, ip, tokens, limit)
+					app.rateLimitExceededResponse(w, r, retryAfter)
+					return
+				}
+				rateLimiterAllowed.Add(class, 1)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Метод logRateLimited() учитывает отклоненный запрос в метриках и записывает его в
+// лог с уровнем INFO: по IP-адресам, путям и остатку токенов можно отличить атаку
+// от всплеска обычной нагрузки при настройке лимитов. Путь и метод запроса уже
+// содержатся в логгере запроса (см. requestContext()).
+func (app *application) logRateLimited(r *http.Request, class, ip string, tokens float64, limit rateLimitSettings) {
+	rateLimiterRejected.Add(class, 1)
+	app.contextGetLogger(r).PrintInfo("request rate limited", map[string]string{
+		"limiter_class": class,
+		"client_ip":     ip,
+		"tokens":        strconv.FormatFloat(tokens, 'f', 2, 64),
+		"limit_rps":     strconv.FormatFloat(limit.rps, 'f', -1, 64),
+		"limit_burst":   strconv.Itoa(limit.burst),
+	})
+}
+
+// Метод setRateLimitHeaders() добавляет в ответ заголовки X-RateLimit-*, чтобы
+// клиенты могли заранее снижать частоту запросов. X-RateLimit-Reset содержит
+// количество секунд до полного восстановления лимита.
+func (app *application) setRateLimitHeaders(w http.ResponseWriter, limit, remaining int, reset time.Duration) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+}
+
+// Функция ceilSeconds() округляет длительность вверх до целого числа секунд.
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// Результаты проверки учетных данных администратора.
+type adminAuthResult int
+
+const (
+	adminAuthMissing adminAuthResult = iota // учетные данные не переданы
+	adminAuthOK
+	adminAuthFailed
+	adminAuthLocked
+)
+
+// Метод authenticateAdmin() проверяет, является ли клиент администратором: либо
+// он предъявил доверенный сертификат администратора (mTLS), либо передал корректные
+// учетные данные через Basic Auth. Для результата adminAuthLocked также возвращается
+// оставшееся время блокировки.
+//
+// Для защиты от перебора паролей неудачные попытки учитываются отдельно по IP-адресу
+// клиента и по имени пользователя: после нескольких неудач подряд дальнейшие
+// попытки временно отклоняются без проверки пароля.
+func (app *application) authenticateAdmin(r *http.Request) (adminAuthResult, time.Duration) {
+	// Клиент с доверенным сертификатом администратора или запрос с проверенной
+	// подписью (см. verifySignature()) не должен дополнительно передавать пароль.
+	if app.clientCertIsAdmin(r) || app.contextGetSignatureKeyID(r) != "" {
+		return adminAuthOK, 0
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return adminAuthMissing, 0
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	lockoutKeys := []string{"ip:" + ip, "username:" + username}
+
+	if remaining := app.authLockout.lockedFor(lockoutKeys...); remaining > 0 {
+		return adminAuthLocked, remaining
+	}
+
+	if !app.adminCredentialsMatch(username, password) {
+		lockout := app.authLockout.fail(lockoutKeys...)
+
+		properties := map[string]string{
+			"ip":       ip,
+			"username": username,
+		}
+		if lockout > 0 {
+			properties["lockout"] = lockout.String()
+			app.contextGetLogger(r).PrintWarn("admin authentication locked out", properties)
+			app.recordAuditEvent(r, data.AuditAdminLockedOut, username, map[string]string{"lockout": lockout.String()})
+		} else {
+			app.contextGetLogger(r).PrintWarn("admin authentication failed", properties)
+			app.recordAuditEvent(r, data.AuditAdminLoginFailed, username, nil)
+		}
+
+		return adminAuthFailed, 0
+	}
+
+	app.authLockout.succeed(lockoutKeys...)
+	if app.authLockout.shouldAuditLogin(username + "@" + ip) {
+		app.recordAuditEvent(r, data.AuditAdminLogin, username, nil)
+	}
+	return adminAuthOK, 0
+}
+
+// Middleware authenticate() один раз проверяет учетные данные администратора
+// (см. authenticateAdmin()) и сохраняет результат в контексте запроса. Неудачная
+// попытка учитывается и попадает в журнал аудита только здесь, сколько бы раз
+// обработчик ни проверял права клиента. Middleware должно стоять после
+// verifySignature(), чтобы учитывать подпись запроса.
+func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Выполняем проверку только в том случае, если ограничение запросов включено.
-		if app.config.limiter.enabled {
-			ip, _, err := net.SplitHostPort(r.RemoteAddr)
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
+		result, remaining := app.authenticateAdmin(r)
+		r = app.contextSetAdminAuth(r, result, remaining)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Метод isAdmin() сообщает, аутентифицирован ли клиент как администратор. Он
+// используется там, где администратор видит больше данных, но доступ разрешен всем.
+// Учетные данные не проверяются повторно: используется результат authenticate().
+func (app *application) isAdmin(r *http.Request) bool {
+	result, _ := app.contextGetAdminAuth(r)
+	return result == adminAuthOK
+}
+
+// Метод adminActor() возвращает имя администратора для журнала аудита: имя
+// пользователя Basic Auth или CN клиентского сертификата.
+func (app *application) adminActor(r *http.Request) string {
+	if username, _, ok := r.BasicAuth(); ok {
+		return username
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return r.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+	if keyID := app.contextGetSignatureKeyID(r); keyID != "" {
+		return "signing-key:" + keyID
+	}
+	return ""
+}
+
+// Заголовок, в котором клиент указывает арендатора.
+const tenantHeader = "X-Tenant-ID"
+
+// Middleware tenant() определяет арендатора по заголовку X-Tenant-ID и сохраняет его
+// в контексте запроса, после чего модели работают только с данными этого арендатора.
+// Без заголовка используется арендатор по умолчанию. Фильмы других арендаторов для
+// клиента не существуют (404 Not Found).
+func (app *application) tenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", tenantHeader)
+
+		tenant := r.Header.Get(tenantHeader)
+		if tenant == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !data.ValidTenantID(tenant) || (len(app.config.tenants) > 0 && !slices.Contains(app.config.tenants, tenant)) {
+			app.invalidTenantResponse(w, r)
+			return
+		}
+
+		r = r.WithContext(data.WithTenant(r.Context(), tenant))
+		r = app.contextSetLogger(r, app.contextGetLogger(r).With(map[string]string{
+			"tenant": tenant,
+		}))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware requireAdmin() пропускает запрос дальше только в том случае, если
+// клиент аутентифицирован как администратор (см. authenticate()).
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, remaining := app.contextGetAdminAuth(r)
+
+		switch result {
+		case adminAuthOK:
+			next.ServeHTTP(w, r)
+		case adminAuthLocked:
+			app.authenticationLockedResponse(w, r, remaining)
+		default:
+			app.invalidAdminCredentialsResponse(w, r)
+		}
+	})
+}
+
+// Middleware catalogRead() оборачивает эндпоинты чтения каталога фильмов. Если
+// каталог не публичный (-catalog-public=false), они доступны только после
+// аутентификации, как и остальные закрытые эндпоинты.
+func (app *application) catalogRead(next http.Handler) http.Handler {
+	if app.config.catalog.public {
+		return next
+	}
+	return app.requireAdmin(next)
+}
+
+// Метод adminCredentialsMatch() сравнивает переданные учетные данные с настроенными.
+// Сравниваются SHA-256 хеши значений с помощью subtle.ConstantTimeCompare(), чтобы
+// время сравнения не зависело ни от содержимого, ни от длины строк. Если пароль
+// администратора не задан, доступ запрещен всегда.
+func (app *application) adminCredentialsMatch(username, password string) bool {
+	if app.config.admin.password == "" {
+		return false
+	}
+
+	usernameHash := sha256.Sum256([]byte(username))
+	passwordHash := sha256.Sum256([]byte(password))
+	expectedUsernameHash := sha256.Sum256([]byte(app.config.admin.username))
+	expectedPasswordHash := sha256.Sum256([]byte(app.config.admin.password))
+
+	usernameMatch := subtle.ConstantTimeCompare(usernameHash[:], expectedUsernameHash[:]) == 1
+	passwordMatch := subtle.ConstantTimeCompare(passwordHash[:], expectedPasswordHash[:]) == 1
+
+	return usernameMatch && passwordMatch
+}
+
+// Тип cachedResponse описывает ответ, сохраненный в кеше ответов, вместе
+// с заголовками, необходимыми для проверки условных запросов.
+type cachedResponse struct {
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// Заголовки ответа, которые сохраняются в кеше ответов.
+var cachedResponseHeaders = []string{"Content-Type", "Cache-Control", "ETag", "Last-Modified", "Vary", "X-Total-Count", "X-Total-Count-Estimated", "Link"}
+
+// Ключ в Redis, хранящий текущее поколение кеша ответов. Поколение входит в ключи
+// всех сохраненных ответов, поэтому его увеличение делает их все недействительными.
+const responseCacheGenerationKey = "response:generation"
+
+// Middleware cacheResponse() сохраняет успешные ответы на GET-запросы в Redis
+// и отдает их из кеша, пока не истечет их время жизни или не изменятся данные.
+// Если кеш не настроен, запрос просто передается дальше.
+func (app *application) cacheResponse(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ответы администраторам не кешируются: они включают неопубликованные фильмы.
+		if app.cache == nil || app.config.cache.responseTTL <= 0 || r.Method != http.MethodGet || app.hasCredentials(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+
+		generation, err := app.cache.GetInt(ctx, responseCacheGenerationKey)
+		if err != nil {
+			app.logError(r, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Ответ зависит не только от URL, но и от арендатора и языков клиента (см.
+		// заголовок Vary).
+		key := fmt.Sprintf("response:%d:%s:%s:%s", generation, data.TenantFromContext(ctx), strings.Join(acceptedLanguages(r), ","), r.URL.RequestURI())
+
+		value, found, err := app.cache.Get(ctx, key)
+		if err != nil {
+			app.logError(r, err)
+		} else if found {
+			var cached cachedResponse
+			if json.Unmarshal(value, &cached) == nil {
+				// Значения Vary, добавленные внешними middleware (сжатие, CORS),
+				// дополняются сохраненными, а не заменяются ими.
+				for key, values := range cached.Header {
+					if key == "Vary" {
+						addVary(w.Header(), values...)
+						continue
+					}
+					w.Header()[key] = values
+				}
+				w.Header().Set("X-Cache", "HIT")
+
+				lastModified, _ := http.ParseTime(cached.Header.Get("Last-Modified"))
+				if notModified(r, cached.Header.Get("ETag"), lastModified) {
+					w.Header().Del("Content-Type")
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+				w.Write(cached.Body)
 				return
 			}
-			mu.Lock()
-			if _, found := clients[ip]; !found {
-				clients[ip] = &client{
-					// Используем значения количества запросов в секунду и burst из структуры config.
-					limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst),
-				}
+		}
+
+		w.Header().Set("X-Cache", "MISS")
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		// Потоковые ответы не кешируются (см. skipRecording()).
+		if rec.status != http.StatusOK || rec.skipped {
+			return
+		}
+
+		header := make(http.Header)
+		for _, key := range cachedResponseHeaders {
+			for _, value := range rec.Header().Values(key) {
+				header.Add(key, value)
 			}
-			clients[ip].lastSeen = time.Now()
-			if !clients[ip].limiter.Allow() {
-				mu.Unlock()
-				app.rateLimitExceededResponse(w, r)
+		}
+
+		value, err = json.Marshal(cachedResponse{
+			Header: header,
+			Body:   rec.body.Bytes(),
+		})
+		if err == nil {
+			err = app.cache.Set(ctx, key, value, app.config.cache.responseTTL)
+		}
+		if err != nil {
+			app.logError(r, err)
+		}
+	})
+}
+
+// Метод hasCredentials() сообщает, передал ли клиент учетные данные: заголовок
+// Authorization, клиентский сертификат администратора или подпись запроса. В отличие
+// от isAdmin(), учетные данные не проверяются, поэтому неудачные попытки входа не
+// учитываются повторно.
+func (app *application) hasCredentials(r *http.Request) bool {
+	return r.Header.Get("Authorization") != "" || app.clientCertIsAdmin(r) || app.contextGetSignatureKeyID(r) != ""
+}
+
+// Функция addVary() добавляет в заголовок Vary значения values, которых в нем еще нет.
+func addVary(h http.Header, values ...string) {
+	present := make(map[string]bool)
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			present[strings.ToLower(strings.TrimSpace(field))] = true
+		}
+	}
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if field != "" && !present[strings.ToLower(field)] {
+				h.Add("Vary", field)
+				present[strings.ToLower(field)] = true
+			}
+		}
+	}
+}
+
+// Middleware invalidateResponseCache() используется на маршрутах, изменяющих данные.
+// После успешного ответа оно увеличивает поколение кеша ответов, поэтому все ранее
+// сохраненные ответы перестают использоваться.
+func (app *application) invalidateResponseCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.cache == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		if rec.status < 300 {
+			_, err := app.cache.Incr(r.Context(), responseCacheGenerationKey)
+			if err != nil {
+				app.logError(r, err)
+			}
+		}
+	})
+}
+
+// Тип responseRecorder оборачивает http.ResponseWriter: ответ по-прежнему
+// отправляется клиенту, но код статуса и копия тела сохраняются (если копирование
+// не отменено, см. skipRecording()).
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	skipped     bool
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if !rr.wroteHeader {
+		rr.status = status
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	if !rr.skipped {
+		rr.body.Write(b)
+	}
+	return rr.ResponseWriter.Write(b)
+}
+
+// Функция skipRecording() отменяет копирование тела ответа, если w (или один из
+// обернутых им http.ResponseWriter) — это responseRecorder. Ее вызывают обработчики,
+// которые отправляют ответ потоком: иначе копия тела собрала бы в памяти весь
+// ответ. Такой ответ не попадает в кеш (см. cacheResponse()).
+func skipRecording(w http.ResponseWriter) {
+	for {
+		switch rw := w.(type) {
+		case *responseRecorder:
+			rw.skipped = true
+			rw.body = bytes.Buffer{}
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+// Метод Unwrap() позволяет http.ResponseController получить доступ
+// к исходному http.ResponseWriter.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+// Middleware logRequests() записывает в лог каждый обработанный запрос с кодом
+// статуса и временем обработки. Используется логгер запроса, поэтому записи
+// содержат идентификатор запроса (см. requestContext()).
+func (app *application) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		app.contextGetLogger(r).PrintInfo("request completed", map[string]string{
+			"status":      strconv.Itoa(sw.status),
+			"duration_ms": strconv.FormatInt(time.Since(start).Milliseconds(), 10),
+			"remote_addr": r.RemoteAddr,
+		})
+	})
+}
+
+// Заголовки запроса, которые браузерные клиенты с доверенных источников могут
+// отправлять, и заголовки ответа, которые им доступны.
+var (
+	corsAllowedHeaders = []string{"Authorization", "Content-Type", "Content-Encoding", "Accept-Language", "X-Request-ID", "X-Expected-Version",
+		tenantHeader, signatureKeyIDHeader, signatureTimestampHeader, signatureHeader}
+	corsExposedHeaders = []string{"Location", "ETag", "Last-Modified", "Link", "X-Total-Count", "X-Total-Count-Estimated", "X-Request-ID",
+		"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After", "API-Version", "Deprecation", "Sunset"}
+)
+
+// Middleware enableCORS() разрешает запросы из браузера с источников, перечисленных
+// во флаге -cors-trusted-origins. На предварительные (preflight) запросы с доверенных
+// источников сразу отвечает 200 OK со списком разрешенных методов и заголовков.
+func (app *application) enableCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+
+		origin := r.Header.Get("Origin")
+		if origin != "" && slices.Contains(app.config.cors.trustedOrigins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, HEAD, GET, POST, PUT, PATCH, DELETE")
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+				w.WriteHeader(http.StatusOK)
 				return
 			}
-			mu.Unlock()
 		}
+
 		next.ServeHTTP(w, r)
 	})
 }
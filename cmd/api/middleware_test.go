@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/jsonlog"
+)
+
+func TestCompress(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.middleware.compression = true
+	ts := newTestServer(t, app.routes())
+
+	// Транспорт http.Client сам распаковывает ответ, только если заголовок
+	// Accept-Encoding не задан явно, поэтому здесь тело распаковывается вручную.
+	code, header, body := ts.do(t, http.MethodGet, "/v1/schema/movies", "", http.Header{"Accept-Encoding": {"gzip, deflate"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Encoding"), "gzip")
+
+	zr, err := gzip.NewReader(bytes.NewReader([]byte(body)))
+	assert.NilError(t, err)
+	js, err := io.ReadAll(zr)
+	assert.NilError(t, err)
+	assert.StringContains(t, string(js), `"fields"`)
+
+	_, header, body = ts.do(t, http.MethodGet, "/v1/schema/movies", "", http.Header{"Accept-Encoding": {"gzip;q=0"}})
+	assert.Equal(t, header.Get("Content-Encoding"), "")
+	assert.StringContains(t, body, `"fields"`)
+
+	ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, adminHeader())
+	code, header, body = ts.do(t, http.MethodDelete, "/v2/movies/1", "", http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, code, http.StatusNoContent)
+	assert.Equal(t, header.Get("Content-Encoding"), "")
+	assert.Equal(t, body, "")
+}
+
+func TestEnableCORS(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.middleware.cors = true
+	app.config.cors.trustedOrigins = []string{"https://example.com"}
+	ts := newTestServer(t, app.routes())
+
+	_, header, _ := ts.do(t, http.MethodGet, "/v1/healthcheck", "", http.Header{"Origin": {"https://example.com"}})
+	assert.Equal(t, header.Get("Access-Control-Allow-Origin"), "https://example.com")
+	assert.StringContains(t, header.Get("Access-Control-Expose-Headers"), "X-Total-Count")
+
+	_, header, _ = ts.do(t, http.MethodGet, "/v1/healthcheck", "", http.Header{"Origin": {"https://evil.example"}})
+	assert.Equal(t, header.Get("Access-Control-Allow-Origin"), "")
+
+	code, header, _ := ts.do(t, http.MethodOptions, "/v1/movies/1", "", http.Header{
+		"Origin":                        {"https://example.com"},
+		"Access-Control-Request-Method": {http.MethodPatch},
+	})
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, header.Get("Access-Control-Allow-Methods"), http.MethodPatch)
+	assert.StringContains(t, header.Get("Access-Control-Allow-Headers"), "X-Expected-Version")
+}
+
+func TestMiddlewareToggles(t *testing.T) {
+	var buf bytes.Buffer
+	app := newTestApplication(t)
+	app.logger = jsonlog.New(&buf, jsonlog.LevelInfo)
+	app.config.middleware.logging = true
+	app.config.middleware.metrics = false
+	ts := newTestServer(t, app.routes())
+
+	// При запуске в лог выводится состав цепочек: отключенных middleware в нем нет.
+	assert.StringContains(t, buf.String(), `"global":"requestContext, logRequests, recoverPanic, timeout, tenant, apiVersion"`)
+	assert.StringContains(t, buf.String(), `"public":"verifySignature, authenticate"`)
+
+	received := totalRequestsReceived.Value()
+	ts.get(t, "/v1/unknown")
+	assert.Equal(t, totalRequestsReceived.Value(), received)
+	assert.StringContains(t, buf.String(), `"message":"request completed"`)
+	assert.StringContains(t, buf.String(), `"status":"404"`)
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{"Vary": {"Origin", "Accept-Encoding"}}
+	addVary(h, "Origin", "X-Tenant-ID, accept-encoding", "Accept-Language")
+	assert.DeepEqual(t, h.Values("Vary"), []string{"Origin", "Accept-Encoding", "X-Tenant-ID", "Accept-Language"})
+}
+
+func TestCacheControl(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.httpCache.maxAge = time.Minute
+	ts := newTestServer(t, app.routes())
+
+	ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, adminHeader())
+
+	_, header, _ := ts.get(t, "/v1/movies/1")
+	assert.Equal(t, header.Get("Cache-Control"), "public, max-age=60")
+	assert.Equal(t, header.Get("Last-Modified") != "", true)
+
+	// Ответы администратору могут содержать черновики и не должны попадать в общие кеши.
+	_, header, _ = ts.do(t, http.MethodGet, "/v1/movies/1", "", adminHeader())
+	assert.Equal(t, header.Get("Cache-Control"), "private, no-store")
+
+	// Для списков Last-Modified не отправляется: актуальность проверяется по ETag.
+	_, header, _ = ts.get(t, "/v1/movies")
+	assert.Equal(t, header.Get("Last-Modified"), "")
+	code, _, _ := ts.do(t, http.MethodGet, "/v1/movies", "", http.Header{"If-None-Match": {header.Get("ETag")}})
+	assert.Equal(t, code, http.StatusNotModified)
+	code, _, _ = ts.do(t, http.MethodGet, "/v1/movies", "", http.Header{"If-Modified-Since": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}})
+	assert.Equal(t, code, http.StatusOK)
+}
+
+// Устаревшие записи удаляются при добавлении новых, даже если планировщик задач
+// отключен.
+func TestAuthLockoutPrunesOnInsert(t *testing.T) {
+	l := newAuthLockout(3, time.Minute, time.Hour)
+	l.records["username:stale"] = &failureRecord{failures: 1, lastFailure: time.Now().Add(-25 * time.Hour)}
+	l.logins["admin@192.0.2.1"] = time.Now().Add(-2 * loginAuditInterval)
+
+	l.fail("ip:192.0.2.2")
+	assert.Equal(t, len(l.records), 1)
+	assert.Equal(t, len(l.logins), 0)
+
+	// Очистка выполняется не чаще раза в минуту.
+	l.records["username:stale"] = &failureRecord{failures: 1, lastFailure: time.Now().Add(-25 * time.Hour)}
+	assert.Equal(t, l.shouldAuditLogin("admin@192.0.2.2"), true)
+	assert.Equal(t, len(l.records), 2)
+}
+
+// Учетные данные проверяются один раз за запрос, даже если обработчик несколько раз
+// проверяет права клиента.
+func TestAuthenticateOncePerRequest(t *testing.T) {
+	app := newTestApplication(t)
+	audit := &recordingAuditEvents{}
+	app.models.AuditEvents = audit
+	ts := newTestServer(t, app.routes())
+
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth(testAdminUsername, "wrong")
+	for _, path := range []string{"/v1/movies", "/v1/movies/1"} {
+		ts.do(t, http.MethodGet, path, "", req.Header)
+	}
+	app.wg.Wait()
+
+	assert.Equal(t, app.authLockout.records["username:"+testAdminUsername].failures, 2)
+	assert.DeepEqual(t, audit.actions(), []string{data.AuditAdminLoginFailed, data.AuditAdminLoginFailed})
+
+	// Без учетных данных ничего не учитывается.
+	code, _, _ := ts.get(t, "/v1/movies")
+	assert.Equal(t, code, http.StatusOK)
+	app.wg.Wait()
+	assert.Equal(t, len(audit.actions()), 2)
+}
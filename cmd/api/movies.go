@@ -5,64 +5,139 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"greenlight.andreyklimov.net/internal/data"
 	"greenlight.andreyklimov.net/internal/validator"
 )
 
 func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
-	// Определяем структуру input для хранения входных данных JSON.
-	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
-	}
-
-	// Считываем JSON-запрос и записываем данные в структуру input.
-	err := app.readJSON(w, r, &input)
+	movie, err := app.readNewMovie(w, r)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	// Создаем структуру Movie и заполняем ее значениями из input.
-	// Обратите внимание, что переменная movie является указателем на структуру Movie.
-	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
-	}
-
-	// Создаем новый валидатор и проверяем корректность данных.
+	// Создаем новый валидатор и проверяем корректность данных. С dry_run=true
+	// фильм только проверяется и не сохраняется (см. validateMovieHandler()).
 	v := validator.New()
+	dryRun := app.readBool(r.URL.Query(), "dry_run", false, v)
 	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+	if dryRun {
+		app.validMovieResponse(w, r, movie)
 		return
 	}
 
+	// Перед вставкой ищем возможные дубликаты. Они не мешают созданию фильма, но
+	// возвращаются клиенту в поле possible_duplicates.
+	duplicates := app.possibleDuplicates(r, movie)
+
 	// Вызываем метод Insert() у модели movies, передавая указатель на валидированную структуру movie.
 	// Этот метод создаст запись в базе данных и обновит структуру movie сгенерированными значениями.
-	err = app.models.Movies.Insert(movie)
+	err = app.models.Movies.Insert(r.Context(), movie)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrCheckViolation):
+			app.constraintViolationResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateIMDbID), errors.Is(err, data.ErrDuplicateTMDBID):
+			app.duplicateExternalIDResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	// При отправке HTTP-ответа мы добавляем заголовок Location, указывая клиенту URL-адрес
 	// созданного ресурса. Для этого создаем пустой map http.Header и устанавливаем Location.
 	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	headers.Set("Location", fmt.Sprintf("/%s/movies/%d", app.contextGetAPIVersion(r), movie.ID))
+	app.setPosterURL(movie)
 
 	// Отправляем JSON-ответ с кодом 201 Created, включая в тело ответа данные о фильме
 	// и заголовок Location.
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	env := envelope{"movie": movie}
+	if len(duplicates) > 0 {
+		env["possible_duplicates"] = duplicates
+	}
+	err = app.writeJSON(w, http.StatusCreated, env, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// Метод readNewMovie() декодирует тело запроса на создание фильма. Используется
+// при создании фильма и при его проверке (см. validateMovieHandler()).
+func (app *application) readNewMovie(w http.ResponseWriter, r *http.Request) (*data.Movie, error) {
+	// Определяем структуру input для хранения входных данных JSON.
+	var input struct {
+		Title            string       `json:"title"`
+		Year             int32        `json:"year"`
+		Runtime          data.Runtime `json:"runtime"`
+		Genres           []string     `json:"genres"`
+		IMDbID           string       `json:"imdb_id"`
+		TMDBID           int64        `json:"tmdb_id"`
+		Overview         string       `json:"overview"`
+		Tagline          string       `json:"tagline"`
+		OriginalLanguage string       `json:"original_language"`
+		Country          string       `json:"country"`
+		Status           string       `json:"status"`
+		PublishAt        *time.Time   `json:"publish_at"`
+	}
+
+	// Считываем JSON-запрос и записываем данные в структуру input.
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		return nil, err
+	}
+	// Статус публикации меняет только администратор (см. setMovieStatusHandler()),
+	// иначе любой клиент мог бы сразу создать опубликованный или архивный фильм.
+	// По той же причине только администратор назначает время публикации.
+	if !app.isAdmin(r) {
+		switch {
+		case input.Status != "":
+			return nil, errAdminOnlyKey("status")
+		case input.PublishAt != nil:
+			return nil, errAdminOnlyKey("publish_at")
+		}
+	}
+
+	// Создаем структуру Movie и заполняем ее значениями из input.
+	// Обратите внимание, что переменная movie является указателем на структуру Movie.
+	movie := &data.Movie{
+		Title:            input.Title,
+		Year:             input.Year,
+		Runtime:          input.Runtime,
+		Genres:           input.Genres,
+		IMDbID:           input.IMDbID,
+		TMDBID:           input.TMDBID,
+		Overview:         input.Overview,
+		Tagline:          input.Tagline,
+		OriginalLanguage: input.OriginalLanguage,
+		Country:          input.Country,
+		Status:           input.Status,
+		PublishAt:        input.PublishAt,
+	}
+
+	// Если статус не указан, фильм сразу публикуется, как и до появления черновиков.
+	// Черновик с назначенным временем публикации опубликуется автоматически.
+	if movie.Status == "" {
+		movie.Status = data.MoviePublished
+		if movie.PublishAt != nil {
+			movie.Status = data.MovieDraft
+		}
+	}
+	return movie, nil
+}
+
+// Функция errAdminOnlyKey() возвращает ошибку для ключа тела запроса, который может
+// передавать только администратор.
+func errAdminOnlyKey(key string) error {
+	return fmt.Errorf("body contains admin-only key %q", key)
+}
+
 func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -72,7 +147,7 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 	// Call the Get() method to fetch the data for a specific movie. We also need to
 	// use the errors.Is() function to check if it returns a data.ErrRecordNotFound
 	// error, in which case we send a 404 Not Found response to the client.
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.models.Movies.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -82,7 +157,22 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	// Неопубликованные фильмы видны только администратору; для остальных их нет.
+	if !app.movieVisible(r, movie) {
+		app.notFoundResponse(w, r)
+		return
+	}
+	// Название возвращается на языке клиента, если для него есть перевод.
+	w.Header().Add("Vary", "Accept-Language")
+	err = app.models.Translations.Localize(r.Context(), []*data.Movie{movie}, acceptedLanguages(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.setPosterURL(movie)
+	// Отправляем ответ с валидаторами кеширования. Если у клиента уже есть актуальная
+	// версия фильма, он получит 304 Not Modified без тела.
+	err = app.writeCacheableJSON(w, r, envelope{"movie": movie}, movie.UpdatedAt)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -96,15 +186,10 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Получаем запись о фильме как обычно.
-	movie, err := app.models.Movies.Get(id)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
+	// Получаем запись о фильме как обычно. Черновики и архивные фильмы может
+	// изменять только администратор, для остальных их нет.
+	movie := app.visibleMovie(w, r, id)
+	if movie == nil {
 		return
 	}
 
@@ -119,10 +204,17 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 
 	// Используем указатели для полей Title, Year и Runtime.
 	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
+		Title            *string       `json:"title"`
+		Year             *int32        `json:"year"`
+		Runtime          *data.Runtime `json:"runtime"`
+		Genres           []string      `json:"genres"`
+		IMDbID           *string       `json:"imdb_id"`
+		TMDBID           *int64        `json:"tmdb_id"`
+		Overview         *string       `json:"overview"`
+		Tagline          *string       `json:"tagline"`
+		OriginalLanguage *string       `json:"original_language"`
+		Country          *string       `json:"country"`
+		PublishAt        *string       `json:"publish_at"`
 	}
 
 	// Декодируем JSON как обычно.
@@ -131,6 +223,12 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		app.badRequestResponse(w, r, err)
 		return
 	}
+	// Назначенную публикацию черновика выполняет планировщик, поэтому задавать и
+	// отменять ее, как и публиковать фильм, может только администратор.
+	if input.PublishAt != nil && !app.isAdmin(r) {
+		app.badRequestResponse(w, r, errAdminOnlyKey("publish_at"))
+		return
+	}
 
 	// Если значение input.Title равно nil, значит, в JSON-запросе не было передано
 	// соответствующей пары "ключ-значение" для "title". В этом случае оставляем
@@ -152,32 +250,157 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	if input.Genres != nil {
 		movie.Genres = input.Genres // Для срезов разыменование не требуется.
 	}
+	// Пустая строка или ноль удаляют внешний идентификатор.
+	if input.IMDbID != nil {
+		movie.IMDbID = *input.IMDbID
+	}
+	if input.TMDBID != nil {
+		movie.TMDBID = *input.TMDBID
+	}
+	if input.Overview != nil {
+		movie.Overview = *input.Overview
+	}
+	if input.Tagline != nil {
+		movie.Tagline = *input.Tagline
+	}
+	if input.OriginalLanguage != nil {
+		movie.OriginalLanguage = *input.OriginalLanguage
+	}
+	if input.Country != nil {
+		movie.Country = *input.Country
+	}
 
-	// Валидируем обновлённую запись фильма.
+	// Валидируем обновлённую запись фильма. С dry_run=true изменения только
+	// проверяются и не сохраняются.
 	v := validator.New()
+	dryRun := app.readBool(r.URL.Query(), "dry_run", false, v)
+	// Пустая строка отменяет запланированную публикацию.
+	if input.PublishAt != nil {
+		movie.PublishAt = nil
+		if *input.PublishAt != "" {
+			publishAt, err := time.Parse(time.RFC3339, *input.PublishAt)
+			if err != nil {
+				v.AddError("publish_at", "must be a valid RFC 3339 timestamp")
+			} else {
+				movie.PublishAt = &publishAt
+			}
+		}
+	}
 	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+	if dryRun {
+		app.validMovieResponse(w, r, movie)
 		return
 	}
 
 	// Перехватываем ошибку ErrEditConflict и вызываем новый вспомогательный метод
 	// editConflictResponse().
-	err = app.models.Movies.Update(movie)
+	err = app.models.Movies.Update(r.Context(), movie)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrCheckViolation):
+			app.constraintViolationResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateIMDbID), errors.Is(err, data.ErrDuplicateTMDBID):
+			app.duplicateExternalIDResponse(w, r, err)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
+	app.setPosterURL(movie)
 	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// Действия журнала аудита для смены статуса фильма администратором.
+var statusAuditActions = map[string]string{
+	data.MoviePublished: data.AuditMoviePublished,
+	data.MovieDraft:     data.AuditMovieUnpublished,
+	data.MovieArchived:  data.AuditMovieArchived,
+}
+
+// Метод setMovieStatusHandler() возвращает обработчик, переводящий фильм в статус
+// status. Допустимость перехода проверяет модель (см. data.StatusTransitionAllowed()).
+func (app *application) setMovieStatusHandler(status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.readIDParam(r)
+		if err != nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		movie, err := app.models.Movies.Get(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		from := movie.Status
+		err = app.models.Movies.SetStatus(r.Context(), movie, status)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrInvalidStatusTransition):
+				app.invalidStatusTransitionResponse(w, r, from, status)
+			case errors.Is(err, data.ErrEditConflict):
+				app.editConflictResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		app.recordAuditEvent(r, statusAuditActions[status], app.adminActor(r), map[string]string{
+			"movie_id": strconv.FormatInt(movie.ID, 10),
+			"title":    movie.Title,
+			"from":     from,
+		})
+
+		app.setPosterURL(movie)
+		err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+	}
+}
+
+// Метод movieVisible() сообщает, можно ли показать фильм клиенту: опубликованные
+// фильмы видны всем, а черновики и архивные фильмы — только администратору.
+func (app *application) movieVisible(r *http.Request, movie *data.Movie) bool {
+	return movie.Status == data.MoviePublished || app.isAdmin(r)
+}
+
+// Метод visibleMovie() получает фильм id и проверяет, что он виден клиенту (см.
+// movieVisible()). Если фильма нет или он скрыт, клиенту отправляется ответ
+// 404 Not Found и возвращается nil.
+func (app *application) visibleMovie(w http.ResponseWriter, r *http.Request, id int64) *data.Movie {
+	movie, err := app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+	if !app.movieVisible(r, movie) {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+	return movie
+}
+
 func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Request) {
 	// Извлекаем ID фильма из URL.
 	id, err := app.readIDParam(r)
@@ -186,9 +409,14 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Удалить черновик или архивный фильм может только администратор.
+	if app.visibleMovie(w, r, id) == nil {
+		return
+	}
+
 	// Удаляем фильм из базы данных, отправляя клиенту ответ 404 Not Found,
 	// если соответствующая запись не найдена.
-	err = app.models.Movies.Delete(id)
+	err = app.models.Movies.Delete(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -199,39 +427,111 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Возвращаем статус 200 OK вместе с сообщением об успешном удалении.
+	// Начиная с v2 ответ не содержит тела; в v1 возвращаем статус 200 OK вместе
+	// с сообщением об успешном удалении.
+	if app.contextGetAPIVersion(r) != "v1" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie deleted successfuly"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
-func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-	Title string
-	Genres []string
+// movieListInput содержит условия отбора и параметры пагинации списка фильмов.
+type movieListInput struct {
+	data.MovieFilter
 	data.Filters
-	}
+}
+
+// Метод readMovieListInput() читает параметры списка фильмов из строки запроса
+// (см. listMoviesHandler() и headMoviesHandler()) и проверяет их.
+func (app *application) readMovieListInput(r *http.Request) (movieListInput, *validator.Validator) {
+	var input movieListInput
 	v := validator.New()
 	qs := r.URL.Query()
 	input.Title = app.readString(qs, "title", "")
 	input.Genres = app.readCSV(qs, "genres", []string{})
+	// Поиск по внешним идентификаторам позволяет клиентам сверять свои каталоги с нашим.
+	input.IMDbID = app.readString(qs, "imdb_id", "")
+	input.TMDBID = int64(app.readInt(qs, "tmdb_id", 0, v))
+	input.YearMax = int32(app.readInt(qs, "year_max", 0, v))
+	// Администратор может отбирать фильмы по статусу (по умолчанию видны все), а
+	// остальным клиентам доступны только опубликованные фильмы.
+	if app.isAdmin(r) {
+		input.Status = app.readString(qs, "status", "")
+		v.Check(input.Status == "" || validator.PermittedValue(input.Status, data.MovieStatuses...), "status", "must be one of draft, published or archived")
+		// Параметр scheduled показывает предстоящие публикации.
+		input.Scheduled = app.readBool(qs, "scheduled", false, v)
+	} else {
+		input.Status = data.MoviePublished
+	}
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
-	if data.ValidateFilters(v, input.Filters); !v.Valid() {
-	app.failedValidationResponse(w, r, v.Errors)
-	return
+	input.Filters.SortSafelist = data.MovieSortSafelist
+	// На больших выборках точный подсчет total_records дорог; с exact_count=false
+	// возвращается оценка, отмеченная в метаданных как приблизительная.
+	input.Filters.EstimateCount = !app.readBool(qs, "exact_count", true, v)
+	// Глубокие страницы с большим OFFSET обходятся базе данных слишком дорого, поэтому
+	// вместо них предлагается обход по курсору: каждая полная страница содержит
+	// next_cursor, который передается в параметре cursor для получения следующей.
+	if cursor := app.readString(qs, "cursor", ""); cursor != "" {
+		c, err := data.DecodeCursor(cursor)
+		switch {
+		case err != nil:
+			v.AddError("cursor", "is invalid")
+		case c.Sort != input.Filters.Sort:
+			v.AddError("cursor", "was issued for a different sort order")
+		case qs.Has("page"):
+			v.AddError("page", "cannot be used together with cursor")
+		default:
+			input.Filters.Cursor = c
+		}
+	}
+	if input.Filters.Cursor == nil && (input.Filters.Page-1)*input.Filters.PageSize > data.MaxOffset {
+		if data.SupportsCursor(input.Filters.Sort) {
+			v.AddError("page", fmt.Sprintf("is too deep (more than %d records skipped): request pages sequentially using the cursor parameter with next_cursor from the metadata", data.MaxOffset))
+		} else {
+			v.AddError("page", fmt.Sprintf("is too deep (more than %d records skipped): narrow the results with filters or sort by id, title, year or runtime to use the cursor parameter", data.MaxOffset))
+		}
+	}
+	data.ValidateFilters(v, input.Filters)
+	return input, v
+}
+
+func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	input, v := app.readMovieListInput(r)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
 	}
 	// Accept the metadata struct as a return value.
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.models.Movies.GetAll(r.Context(), input.MovieFilter, input.Filters)
+	if err != nil {
+	app.serverErrorResponse(w, r, err)
+	return
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	err = app.models.Translations.Localize(r.Context(), movies, acceptedLanguages(r))
 	if err != nil {
 	app.serverErrorResponse(w, r, err)
 	return
 	}
-	// Include the metadata in the response envelope.
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	app.setPosterURL(movies...)
+	metadata.NextCursor = data.NextCursor(input.Filters, movies)
+	setPaginationHeaders(w, r, metadata)
+	// Большие страницы отправляются потоком, не собирая закодированный ответ в памяти
+	// целиком (сами фильмы страницы уже загружены).
+	if len(movies) > streamListThreshold && app.featureEnabled(r, featureStreamLists) {
+		app.streamJSONList(w, r, envelope{"metadata": metadata}, "movies", anyValues(movies))
+		return
+	}
+	// Include the metadata in the response envelope. Last-Modified для списка не
+	// отправляется: время изменения фильмов на странице не меняется, когда фильмы
+	// удаляются или страница сдвигается, поэтому актуальность проверяется по ETag.
+	err = app.writeCacheableJSON(w, r, envelope{"movies": movies, "metadata": metadata}, time.Time{})
 	if err != nil {
 	app.serverErrorResponse(w, r, err)
 	}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/data"
+)
+
+func TestCreateMovie(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	tests := []struct {
+		name     string
+		body     string
+		header   http.Header
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid",
+			body:     `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation","adventure"]}`,
+			header:   adminHeader(),
+			wantCode: http.StatusCreated,
+			wantBody: `"title": "Moana"`,
+		},
+		{
+			name:     "Missing title",
+			body:     `{"year":2016,"runtime":"107 mins","genres":["animation"]}`,
+			header:   adminHeader(),
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"title": "must be provided"`,
+		},
+		{
+			name:     "Badly formed JSON",
+			body:     `{"title":"Moana",}`,
+			header:   adminHeader(),
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.do(t, http.MethodPost, "/v1/movies", tt.body, tt.header)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestShowMovie(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, _, _ := ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, adminHeader())
+	assert.Equal(t, code, http.StatusCreated)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Valid ID", "/v1/movies/1", http.StatusOK},
+		{"Non-existent ID", "/v1/movies/2", http.StatusNotFound},
+		{"Negative ID", "/v1/movies/-1", http.StatusNotFound},
+		{"String ID", "/v1/movies/foo", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			if code == http.StatusOK {
+				resp := decodeJSON[struct{ Movie data.Movie }](t, body)
+				assert.Equal(t, resp.Movie.Title, "Moana")
+				assert.Equal(t, resp.Movie.Version, int32(1))
+			}
+		})
+	}
+}
+
+func TestUpdateMovieEditConflict(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, adminHeader())
+
+	header := adminHeader()
+	header.Set("X-Expected-Version", "1")
+	code, _, _ := ts.do(t, http.MethodPatch, "/v1/movies/1", `{"year":2017}`, header)
+	assert.Equal(t, code, http.StatusOK)
+
+	// Вторая правка с той же ожидаемой версией должна получить конфликт.
+	code, _, _ = ts.do(t, http.MethodPatch, "/v1/movies/1", `{"year":2018}`, header)
+	assert.Equal(t, code, http.StatusConflict)
+}
+
+func TestListMoviesExactCount(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, adminHeader())
+
+	// Модель в памяти всегда считает точно, поэтому признак оценки не выставляется.
+	code, _, body := ts.get(t, "/v1/movies?exact_count=false")
+	assert.Equal(t, code, http.StatusOK)
+	resp := decodeJSON[struct{ Metadata data.Metadata }](t, body)
+	assert.Equal(t, resp.Metadata.TotalRecords, 1)
+	assert.Equal(t, resp.Metadata.TotalRecordsEstimated, false)
+
+	code, _, body = ts.get(t, "/v1/movies?exact_count=maybe")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, `"exact_count": "must be a boolean value"`)
+}
+
+func TestListMoviesDeepPagination(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	for _, title := range []string{"Moana", "Deadpool", "Black Panther"} {
+		ts.do(t, http.MethodPost, "/v1/movies", `{"title":"`+title+`","year":2016,"runtime":"107 mins","genres":["animation"]}`, adminHeader())
+	}
+
+	code, _, body := ts.get(t, "/v1/movies?page=100000")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "next_cursor")
+
+	// Первая страница содержит курсор, по которому запрашивается следующая.
+	code, _, body = ts.get(t, "/v1/movies?sort=title&page_size=2")
+	assert.Equal(t, code, http.StatusOK)
+	resp := decodeJSON[struct {
+		Movies   []data.Movie
+		Metadata data.Metadata
+	}](t, body)
+	assert.Equal(t, resp.Movies[1].Title, "Deadpool")
+	assert.Equal(t, resp.Metadata.NextCursor != "", true)
+
+	code, _, body = ts.get(t, "/v1/movies?sort=title&page_size=2&cursor="+resp.Metadata.NextCursor)
+	assert.Equal(t, code, http.StatusOK)
+	resp = decodeJSON[struct {
+		Movies   []data.Movie
+		Metadata data.Metadata
+	}](t, body)
+	assert.Equal(t, len(resp.Movies), 1)
+	assert.Equal(t, resp.Movies[0].Title, "Moana")
+	assert.Equal(t, resp.Metadata.NextCursor, "")
+
+	code, _, body = ts.get(t, "/v1/movies?sort=-title&cursor=abc")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, `"cursor": "is invalid"`)
+}
+
+func TestHeadMovies(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, header, body := ts.do(t, http.MethodHead, "/v1/movies", "", nil)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("X-Total-Count"), "0")
+	assert.Equal(t, body, "")
+
+	for _, title := range []string{"Moana", "Deadpool", "Black Panther"} {
+		ts.do(t, http.MethodPost, "/v1/movies", `{"title":"`+title+`","year":2016,"runtime":"107 mins","genres":["animation"]}`, adminHeader())
+	}
+
+	code, header, body = ts.do(t, http.MethodHead, "/v1/movies?page=2&page_size=1&sort=title", "", nil)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("X-Total-Count"), "3")
+	assert.Equal(t, header.Get("Link"), `</v1/movies?page=1&page_size=1&sort=title>; rel="first", `+
+		`</v1/movies?page=1&page_size=1&sort=title>; rel="prev", `+
+		`</v1/movies?page=3&page_size=1&sort=title>; rel="next", `+
+		`</v1/movies?page=3&page_size=1&sort=title>; rel="last"`)
+	assert.Equal(t, body, "")
+
+	// GET возвращает те же заголовки вместе с телом.
+	_, getHeader, _ := ts.get(t, "/v1/movies?page=2&page_size=1&sort=title")
+	assert.Equal(t, getHeader.Get("X-Total-Count"), "3")
+	assert.Equal(t, getHeader.Get("Link"), header.Get("Link"))
+
+	code, _, _ = ts.do(t, http.MethodHead, "/v1/movies?page=0", "", nil)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+}
+
+func TestDraftVisibility(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, _, _ := ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"],"status":"draft"}`, adminHeader())
+	assert.Equal(t, code, http.StatusCreated)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"Show", http.MethodGet, "/v1/movies/1", ""},
+		{"Update", http.MethodPatch, "/v1/movies/1", `{"year":2017}`},
+		{"Update dry run", http.MethodPatch, "/v1/movies/1?dry_run=true", `{}`},
+		{"Put translation", http.MethodPut, "/v1/movies/1/translations/ru", `{"title":"Моана"}`},
+		{"Delete translation", http.MethodDelete, "/v1/movies/1/translations/ru", ""},
+		{"Delete", http.MethodDelete, "/v1/movies/1", ""},
+	}
+
+	// Для анонимного клиента черновика нет: ни прочитать, ни изменить его нельзя.
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.do(t, tt.method, tt.path, tt.body, nil)
+			assert.Equal(t, code, http.StatusNotFound)
+			assert.Equal(t, strings.Contains(body, "Moana"), false)
+		})
+	}
+
+	_, _, body := ts.get(t, "/v1/movies")
+	assert.Equal(t, strings.Contains(body, "Moana"), false)
+
+	// Администратор видит черновик в списке и может его изменить и удалить.
+	_, _, body = ts.do(t, http.MethodGet, "/v1/movies?status=draft", "", adminHeader())
+	assert.StringContains(t, body, `"title": "Moana"`)
+	code, _, _ = ts.do(t, http.MethodGet, "/v1/movies/1", "", adminHeader())
+	assert.Equal(t, code, http.StatusOK)
+	code, _, _ = ts.do(t, http.MethodPatch, "/v1/movies/1", `{"year":2017}`, adminHeader())
+	assert.Equal(t, code, http.StatusOK)
+	code, _, _ = ts.do(t, http.MethodDelete, "/v1/movies/1", "", adminHeader())
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestCreateMovieStatusAdminOnly(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	for _, status := range []string{data.MoviePublished, data.MovieArchived, data.MovieDraft} {
+		code, _, body := ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"],"status":"`+status+`"}`, nil)
+		assert.Equal(t, code, http.StatusBadRequest)
+		assert.StringContains(t, body, `admin-only key \"status\"`)
+	}
+
+	code, _, _ := ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"],"status":"archived"}`, adminHeader())
+	assert.Equal(t, code, http.StatusCreated)
+}
+
+// Переводы сохраняются и в моделях в памяти (в тестах и в режиме -demo).
+func TestMovieTranslations(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, _, _ := ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, nil)
+	assert.Equal(t, code, http.StatusCreated)
+	code, _, _ = ts.do(t, http.MethodPut, "/v1/movies/1/translations/ru", `{"title":"Моана"}`, nil)
+	assert.Equal(t, code, http.StatusOK)
+	code, _, _ = ts.do(t, http.MethodPut, "/v1/movies/2/translations/ru", `{"title":"Вверх"}`, nil)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, body := ts.get(t, "/v1/movies/1/translations")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"title": "Моана"`)
+
+	code, _, body = ts.do(t, http.MethodGet, "/v1/movies/1", "", http.Header{"Accept-Language": {"ru"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"title": "Моана"`)
+	assert.StringContains(t, body, `"original_title": "Moana"`)
+
+	code, _, _ = ts.do(t, http.MethodDelete, "/v1/movies/1/translations/ru", "", nil)
+	assert.Equal(t, code, http.StatusOK)
+	code, _, _ = ts.do(t, http.MethodDelete, "/v1/movies/1/translations/ru", "", nil)
+	assert.Equal(t, code, http.StatusNotFound)
+}
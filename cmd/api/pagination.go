@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"greenlight.andreyklimov.net/internal/data"
+)
+
+// Обработчик headMoviesHandler() отвечает на HEAD /v1/movies: принимает те же
+// параметры, что и listMoviesHandler(), но возвращает только заголовки пагинации
+// без тела. Фильмы при этом не выбираются, выполняется лишь подсчет, поэтому
+// клиенты могут дешево опрашивать размер коллекции.
+func (app *application) headMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	input, v := app.readMovieListInput(r)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	input.Filters.CountOnly = true
+	_, metadata, err := app.models.Movies.GetAll(r.Context(), input.MovieFilter, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Для пустой выборки GetAll() возвращает пустые метаданные, но количество
+	// известно и равно нулю.
+	w.Header().Set("X-Total-Count", strconv.Itoa(metadata.TotalRecords))
+	setPaginationHeaders(w, r, metadata)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Функция setPaginationHeaders() дублирует метаданные страницы в заголовках ответа:
+// X-Total-Count содержит общее количество записей (X-Total-Count-Estimated: true,
+// если это оценка), а Link — ссылки на первую, предыдущую, следующую и последнюю
+// страницы (RFC 8288). При обходе по курсору общее количество неизвестно, и
+// ссылка на следующую страницу строится по next_cursor.
+func setPaginationHeaders(w http.ResponseWriter, r *http.Request, metadata data.Metadata) {
+	var links []string
+	link := func(rel string, set func(qs url.Values)) {
+		u := *r.URL
+		qs := u.Query()
+		set(qs)
+		u.RawQuery = qs.Encode()
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel))
+	}
+	page := func(n int) func(qs url.Values) {
+		return func(qs url.Values) {
+			qs.Del("cursor")
+			qs.Set("page", strconv.Itoa(n))
+		}
+	}
+	// Страницы глубже data.MaxOffset запрашивать нельзя, и ссылки на них не даются.
+	reachable := func(n int) bool {
+		return (n-1)*metadata.PageSize <= data.MaxOffset
+	}
+
+	if metadata.CurrentPage > 0 {
+		w.Header().Set("X-Total-Count", strconv.Itoa(metadata.TotalRecords))
+		if metadata.TotalRecordsEstimated {
+			w.Header().Set("X-Total-Count-Estimated", "true")
+		}
+
+		link("first", page(metadata.FirstPage))
+		if metadata.CurrentPage > metadata.FirstPage {
+			link("prev", page(metadata.CurrentPage-1))
+		}
+		if metadata.CurrentPage < metadata.LastPage && reachable(metadata.CurrentPage+1) {
+			link("next", page(metadata.CurrentPage+1))
+		}
+		if reachable(metadata.LastPage) {
+			link("last", page(metadata.LastPage))
+		}
+	}
+	// Следующая страница за пределом data.MaxOffset доступна только по курсору.
+	if metadata.NextCursor != "" && (metadata.CurrentPage == 0 || !reachable(metadata.CurrentPage+1)) {
+		link("next", func(qs url.Values) {
+			qs.Del("page")
+			qs.Set("cursor", metadata.NextCursor)
+		})
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
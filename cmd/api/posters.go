@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/validator"
+)
+
+// Допустимые типы файлов постеров и соответствующие им расширения.
+var posterContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// Ограничения на размеры изображения постера в пикселях.
+const (
+	posterMinDimension = 100
+	posterMaxDimension = 6000
+)
+
+// Запас к размеру файла постера на заголовки и границы частей multipart.
+const posterFormOverhead = 64 * 1024
+
+// Метод setPosterURL() заполняет поле PosterURL фильмов по ключу постера.
+func (app *application) setPosterURL(movies ...*data.Movie) {
+	for _, movie := range movies {
+		if movie.PosterKey != "" {
+			movie.PosterURL = app.storage.URL(movie.PosterKey)
+		}
+	}
+}
+
+func (app *application) uploadMoviePosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie := app.visibleMovie(w, r, id)
+	if movie == nil {
+		return
+	}
+
+	// Ограничиваем размер всего тела запроса: к размеру файла добавляем запас на
+	// заголовки и границы частей multipart.
+	maxSize := app.config.storage.posterMaxSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+posterFormOverhead)
+
+	file, _, err := r.FormFile("poster")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		v := validator.New()
+		switch {
+		case errors.As(err, &maxBytesError):
+			v.AddFieldError(validator.FieldError{Field: "poster", Rule: validator.RuleMaxBytes(int(maxSize)), Message: fmt.Sprintf("must not be larger than %d bytes", maxSize)})
+			app.failedValidationResponse(w, r, v)
+		case errors.Is(err, http.ErrMissingFile):
+			v.AddFieldError(validator.FieldError{Field: "poster", Rule: validator.RuleRequired(), Message: "must be provided"})
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	// Читаем на байт больше допустимого, чтобы обнаружить слишком большой файл.
+	content, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Тип файла определяется по содержимому, а не по заголовку Content-Type,
+	// переданному клиентом.
+	contentType := http.DetectContentType(content)
+	ext, allowed := posterContentTypes[contentType]
+
+	v := validator.New()
+	v.CheckRule(int64(len(content)) <= maxSize, "poster", fmt.Sprintf("must not be larger than %d bytes", maxSize), validator.RuleMaxBytes(int(maxSize)), len(content))
+	v.Check(allowed, "poster", "must be a JPEG or PNG image")
+	if v.Valid() {
+		config, _, err := image.DecodeConfig(bytes.NewReader(content))
+		v.Check(err == nil, "poster", "must be a valid image")
+		if err == nil {
+			v.Check(config.Width >= posterMinDimension && config.Height >= posterMinDimension, "poster", fmt.Sprintf("must be at least %dx%d pixels", posterMinDimension, posterMinDimension))
+			v.Check(config.Width <= posterMaxDimension && config.Height <= posterMaxDimension, "poster", fmt.Sprintf("must be at most %dx%d pixels", posterMaxDimension, posterMaxDimension))
+		}
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// Каждая загрузка сохраняется под новым ключом, поэтому закешированные клиентами
+	// и CDN старые постеры не нужно инвалидировать.
+	key := fmt.Sprintf("posters/%d-%s%s", movie.ID, generateRequestID()[:16], ext)
+
+	err = app.storage.Put(r.Context(), key, contentType, bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	oldKey := movie.PosterKey
+	movie.PosterKey = key
+
+	err = app.models.Movies.SetPoster(r.Context(), movie)
+	if err != nil {
+		// Запись не обновилась, поэтому загруженный файл больше не нужен.
+		app.deletePoster(r, key)
+
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if oldKey != "" {
+		app.deletePoster(r, oldKey)
+	}
+
+	app.setPosterURL(movie)
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Метод deletePoster() удаляет файл постера в фоне. Ошибка удаления только
+// записывается в лог: оставшийся файл ни на что не влияет.
+func (app *application) deletePoster(r *http.Request, key string) {
+	logger := app.contextGetLogger(r)
+
+	app.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		err := app.storage.Delete(ctx, key)
+		if err != nil {
+			logger.PrintError(err, map[string]string{"poster_key": key})
+		}
+	})
+}
+
+// Метод posterFileHandler() отдает постеры из локального хранилища. Листинг
+// каталогов и скрытые (временные) файлы не выдаются.
+func (app *application) posterFileHandler(dir string) http.Handler {
+	fileServer := http.StripPrefix("/v1/posters", http.FileServer(http.Dir(dir)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Base(r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") || strings.HasPrefix(name, ".") {
+			app.notFoundResponse(w, r)
+			return
+		}
+		// Отсутствующий файл проверяется заранее: http.FileServer ответил бы на него
+		// текстом вместо JSON с описанием ошибки.
+		f, err := http.Dir(dir).Open(strings.TrimPrefix(r.URL.Path, "/v1/posters"))
+		if err != nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+		info, err := f.Stat()
+		f.Close()
+		if err != nil || info.IsDir() {
+			app.notFoundResponse(w, r)
+			return
+		}
+		fileServer.ServeHTTP(w, r)
+	})
+}
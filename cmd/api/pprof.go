@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// Метод pprofHandler() возвращает обработчик для всех эндпоинтов net/http/pprof.
+// Маршрутизатор httprouter не позволяет совмещать универсальный параметр *item
+// со статическими маршрутами на одном уровне, поэтому внутри используем отдельный
+// http.ServeMux, который сам распределяет запросы по обработчикам pprof.
+func (app *application) pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	// Доступ только для администратора обеспечивается в routes().
+	return mux
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// poolMetric описывает метрику пула соединений в формате Prometheus.
+type poolMetric struct {
+	name  string
+	kind  string
+	help  string
+	value func(sql.DBStats) float64
+}
+
+// Метрики пулов соединений с базой данных (см. sql.DBStats).
+var poolMetrics = []poolMetric{
+	{"greenlight_db_max_open_connections", "gauge", "Maximum number of open connections to the database.",
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+	{"greenlight_db_open_connections", "gauge", "Number of established connections, both in use and idle.",
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+	{"greenlight_db_in_use_connections", "gauge", "Number of connections currently in use.",
+		func(s sql.DBStats) float64 { return float64(s.InUse) }},
+	{"greenlight_db_idle_connections", "gauge", "Number of idle connections.",
+		func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	{"greenlight_db_wait_count_total", "counter", "Total number of connections waited for.",
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+	{"greenlight_db_wait_duration_seconds_total", "counter", "Total time blocked waiting for a new connection.",
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+	{"greenlight_db_max_idle_closed_total", "counter", "Total number of connections closed due to the idle connection limit.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+	{"greenlight_db_max_idle_time_closed_total", "counter", "Total number of connections closed due to the maximum idle time.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+	{"greenlight_db_max_lifetime_closed_total", "counter", "Total number of connections closed due to the maximum connection lifetime.",
+		func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+}
+
+// Обработчик prometheusMetricsHandler() отдает метрики в текстовом формате
+// Prometheus. Статистика пулов соединений считывается при каждом запросе.
+func (app *application) prometheusMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writePoolMetrics(w, app.models.PoolStats())
+}
+
+// Функция writePoolMetrics() записывает метрики пулов соединений stats в формате
+// Prometheus. Пул указывается в метке pool.
+func writePoolMetrics(w io.Writer, stats map[string]sql.DBStats) {
+	pools := make([]string, 0, len(stats))
+	for pool := range stats {
+		pools = append(pools, pool)
+	}
+	slices.Sort(pools)
+
+	for _, m := range poolMetrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, pool := range pools {
+			fmt.Fprintf(w, "%s{pool=%q} %s\n", m.name, pool, strconv.FormatFloat(m.value(stats[pool]), 'g', -1, 64))
+		}
+	}
+}
@@ -1,22 +1,192 @@
 package main
 
 import (
+	"expvar"
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/storage"
 )
 
+// middleware оборачивает обработчик дополнительной логикой.
+type middleware func(http.Handler) http.Handler
+
+// Функция chain() объединяет несколько middleware в одно. Первое в списке
+// оказывается внешним, то есть первым получает запрос.
+func chain(mws ...middleware) middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// namedMiddleware — middleware с именем, под которым оно выводится в лог при запуске.
+// Отключенное middleware имеет пустое значение mw.
+type namedMiddleware struct {
+	name string
+	mw   middleware
+}
+
+func use(name string, mw middleware) namedMiddleware {
+	return namedMiddleware{name: name, mw: mw}
+}
+
+func useIf(enabled bool, name string, mw middleware) namedMiddleware {
+	if !enabled {
+		return namedMiddleware{}
+	}
+	return use(name, mw)
+}
+
+// Функция chainNamed() объединяет включенные middleware, как chain(), и записывает
+// их имена в chains под именем группы group.
+func chainNamed(chains map[string]string, group string, mws ...namedMiddleware) middleware {
+	var (
+		names []string
+		list  []middleware
+	)
+	for _, m := range mws {
+		if m.mw == nil {
+			continue
+		}
+		names = append(names, m.name)
+		list = append(list, m.mw)
+	}
+	chains[group] = strings.Join(names, ", ")
+	return chain(list...)
+}
+
 func (app *application) routes() http.Handler {
+	// Состав всех цепочек middleware выводится в лог, чтобы было видно, какие
+	// middleware действуют при текущих флагах.
+	chains := make(map[string]string)
+
+	// У каждого класса маршрутов свой ограничитель частоты запросов (см. limiterConfig).
+	rateLimits := make(map[string]namedMiddleware)
+	for _, class := range limiterClasses {
+		rateLimits[class] = useIf(app.config.limiter.enabled, "rateLimit("+class+")", app.rateLimit(class))
+	}
+
+	// Группы маршрутов со своими цепочками middleware. Поиск по каталогу, изменяющие
+	// запросы и маршруты администратора ограничиваются по частоте запросов отдельно от
+	// остальных маршрутов; для маршрутов администратора ограничитель срабатывает до
+	// проверки учетных данных, что замедляет их перебор. Подпись проверяется после
+	// ограничения частоты, чтобы не хешировать тела лишних запросов, а учетные данные
+	// администратора — один раз, сразу после подписи (см. authenticate()).
+	public := chainNamed(chains, "public", rateLimits[limiterDefaultClass], use("verifySignature", app.verifySignature), use("authenticate", app.authenticate))
+	admin := chainNamed(chains, "admin", rateLimits[limiterAuthClass], use("verifySignature", app.verifySignature), use("authenticate", app.authenticate), use("requireAdmin", app.requireAdmin))
+	read := chainNamed(chains, "read", use("public", public), use("catalogRead", app.catalogRead))
+	search := chainNamed(chains, "search", rateLimits[limiterSearchClass], use("verifySignature", app.verifySignature), use("authenticate", app.authenticate), use("catalogRead", app.catalogRead))
+	// Ответы на GET-запросы к фильмам кешируются в Redis (если он настроен), а любые
+	// изменения фильмов делают кеш недействительным.
+	cachedRead := chainNamed(chains, "cached_read", use("read", read), useIf(app.cache != nil, "cacheResponse", app.cacheResponse))
+	cachedSearch := chainNamed(chains, "cached_search", use("search", search), useIf(app.cache != nil, "cacheResponse", app.cacheResponse))
+	// В закрытом каталоге изменения тоже требуют аутентификации: иначе, например,
+	// PATCH с dry_run=true возвращал бы любой фильм анонимному клиенту.
+	// Проверка фильма без сохранения (validate) доступна тем же клиентам, что и
+	// создание, но ничего не меняет и поэтому не сбрасывает кеш ответов.
+	validate := chainNamed(chains, "validate", rateLimits[limiterWriteClass], use("verifySignature", app.verifySignature), use("authenticate", app.authenticate), useIf(!app.config.catalog.public, "requireAdmin", app.requireAdmin))
+	write := chainNamed(chains, "write", use("validate", validate), useIf(app.cache != nil, "invalidateResponseCache", app.invalidateResponseCache))
+	// Загрузка постера отличается от write только допустимым размером подписанного
+	// тела: оно ограничивается так же, как в uploadMoviePosterHandler().
+	upload := chainNamed(chains, "upload", rateLimits[limiterWriteClass], use("verifySignature", app.verifySignatureLimit(app.config.storage.posterMaxSize+posterFormOverhead)), use("authenticate", app.authenticate), useIf(!app.config.catalog.public, "requireAdmin", app.requireAdmin), useIf(app.cache != nil, "invalidateResponseCache", app.invalidateResponseCache))
+	adminWrite := chainNamed(chains, "admin_write", use("admin", admin), useIf(app.cache != nil, "invalidateResponseCache", app.invalidateResponseCache))
+
 	router := httprouter.New()
-	router.NotFound = http.HandlerFunc(app.notFoundResponse)
-	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listMoviesHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.createMovieHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
-	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.updateMovieHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.deleteMovieHandler)
-
-	// Оборачиваем роутер в middleware rateLimit().
-	return app.recoverPanic(app.rateLimit(router))
+	router.NotFound = public(http.HandlerFunc(app.notFoundResponse))
+	router.MethodNotAllowed = public(http.HandlerFunc(app.methodNotAllowedResponse))
+	// Вместо перенаправления (с HTML в теле) на путь без завершающего слеша или с
+	// исправленным регистром клиент получает 404 в обычном JSON-формате. Заголовок
+	// Allow для ответов 405 выставляет сам httprouter.
+	router.RedirectTrailingSlash = false
+	router.RedirectFixedPath = false
+	// Маршруты API регистрируются для каждой версии с префиксом /v1, /v2 и т.д.
+	// Маршруты, измененные в более поздней версии, отмечаются как устаревшие.
+	for _, version := range apiVersions {
+		prefix := "/" + version
+		handle := func(method, route string, group middleware, h http.Handler) {
+			router.Handler(method, prefix+route, app.deprecation(version, method, route, group(h)))
+		}
+
+		handle(http.MethodGet, "/healthcheck", public, http.HandlerFunc(app.healthcheckHandler))
+		handle(http.MethodGet, "/movies", cachedSearch, http.HandlerFunc(app.listMoviesHandler))
+		handle(http.MethodHead, "/movies", search, http.HandlerFunc(app.headMoviesHandler))
+		handle(http.MethodPost, "/movies", write, http.HandlerFunc(app.createMovieHandler))
+		// Удаление фильмов по фильтру (по умолчанию в режиме пробного запуска).
+		handle(http.MethodDelete, "/movies", adminWrite, http.HandlerFunc(app.deleteMoviesHandler))
+		handle(http.MethodGet, "/movies/:id", cachedRead, http.HandlerFunc(app.showMovieHandler))
+		handle(http.MethodPatch, "/movies/:id", write, http.HandlerFunc(app.updateMovieHandler))
+		handle(http.MethodDelete, "/movies/:id", write, http.HandlerFunc(app.deleteMovieHandler))
+		handle(http.MethodGet, "/movies/:id/similar", search, http.HandlerFunc(app.similarMoviesHandler))
+		handle(http.MethodGet, "/movies/:id/translations", read, http.HandlerFunc(app.listMovieTranslationsHandler))
+		handle(http.MethodPut, "/movies/:id/translations/:language", write, http.HandlerFunc(app.putMovieTranslationHandler))
+		handle(http.MethodDelete, "/movies/:id/translations/:language", write, http.HandlerFunc(app.deleteMovieTranslationHandler))
+		// Смена статуса публикации доступна только администратору.
+		handle(http.MethodPost, "/movies/:id/publish", adminWrite, app.setMovieStatusHandler(data.MoviePublished))
+		handle(http.MethodPost, "/movies/:id/unpublish", adminWrite, app.setMovieStatusHandler(data.MovieDraft))
+		handle(http.MethodPost, "/movies/:id/archive", adminWrite, app.setMovieStatusHandler(data.MovieArchived))
+		handle(http.MethodPost, "/movies/:id/merge", adminWrite, http.HandlerFunc(app.mergeMoviesHandler))
+		handle(http.MethodPost, "/movies/:id/poster", upload, http.HandlerFunc(app.uploadMoviePosterHandler))
+
+		// Проверка фильма без сохранения. Маршрут /movies/validate нельзя
+		// зарегистрировать в httprouter: статический сегмент конфликтует с /movies/:id.
+		handle(http.MethodPost, "/movie-validations", validate, http.HandlerFunc(app.validateMovieHandler))
+
+		// Описание полей фильма и параметров списка для клиентов.
+		handle(http.MethodGet, "/schema/movies", public, http.HandlerFunc(app.movieSchemaHandler))
+
+		// Импорт фильмов из внешнего каталога доступен, только если настроен провайдер.
+		// Маршрут /movies/import-external нельзя зарегистрировать в httprouter:
+		// статический сегмент конфликтует с /movies/:id.
+		if app.metadata != nil {
+			handle(http.MethodPost, "/movie-imports", write, http.HandlerFunc(app.importMovieHandler))
+		}
+
+		// Журнал аудита событий безопасности.
+		handle(http.MethodGet, "/admin/audit-events", admin, http.HandlerFunc(app.listAuditEventsHandler))
+
+		// Версии API и журнал их несовместимых изменений.
+		handle(http.MethodGet, "/changelog", public, http.HandlerFunc(app.changelogHandler))
+	}
+
+	// Постеры из локального хранилища отдает само приложение. Их адреса не зависят
+	// от версии API.
+	if local, ok := app.storage.(*storage.Local); ok {
+		router.Handler(http.MethodGet, "/v1/posters/*filepath", read(app.posterFileHandler(local.Dir())))
+	}
+
+	// Метрики приложения, опубликованные через expvar. Среди них есть и аргументы
+	// командной строки (включая DSN), поэтому эндпоинт доступен только администратору.
+	router.Handler(http.MethodGet, "/debug/vars", admin(expvar.Handler()))
+	// Те же сведения о пулах соединений с базой данных в формате Prometheus.
+	router.Handler(http.MethodGet, "/metrics", admin(http.HandlerFunc(app.prometheusMetricsHandler)))
+
+	// Эндпоинты профилирования регистрируются только если они включены в конфигурации.
+	if app.config.pprof.enabled {
+		router.Handler(http.MethodGet, "/debug/pprof/*item", admin(app.pprofHandler()))
+		router.Handler(http.MethodPost, "/debug/pprof/*item", admin(app.pprofHandler()))
+	}
+
+	// Middleware, общие для всех запросов (включая ответы 404 и 405). Метрики
+	// учитывают все запросы, а requestContext() идет раньше остальных, чтобы даже
+	// записи о панике и журнал запросов содержали идентификатор запроса. Middleware
+	// apiVersion() может изменить путь запроса, поэтому выполняется непосредственно
+	// перед роутером. Метрики, журнал запросов, CORS и сжатие можно отключить флагами.
+	standard := chainNamed(chains, "global",
+		useIf(app.config.middleware.metrics, "metrics", app.metrics),
+		use("requestContext", app.requestContext),
+		useIf(app.config.middleware.logging, "logRequests", app.logRequests),
+		use("recoverPanic", app.recoverPanic),
+		useIf(app.config.middleware.cors, "enableCORS", app.enableCORS),
+		useIf(app.config.middleware.compression, "compress", app.compress),
+		useIf(app.config.requestTimeout > 0, "timeout", app.timeout),
+		use("tenant", app.tenant),
+		use("apiVersion", app.apiVersion),
+	)
+	app.logger.PrintInfo("middleware chain", chains)
+	return standard(router)
 }
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/jsonlog"
+)
+
+// Маршруты тестового приложения и допустимые для них методы. Импорт фильмов и
+// профилирование в тестовом приложении не настроены. Маршруты /v1 (кроме постеров)
+// проверяются и для остальных версий API.
+var testRoutes = []struct {
+	path    string
+	methods []string
+}{
+	{"/v1/healthcheck", []string{http.MethodGet}},
+	{"/v1/movies", []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodDelete}},
+	{"/v1/movies/1", []string{http.MethodGet, http.MethodPatch, http.MethodDelete}},
+	{"/v1/movies/1/similar", []string{http.MethodGet}},
+	{"/v1/movies/1/translations", []string{http.MethodGet}},
+	{"/v1/movies/1/translations/fr", []string{http.MethodPut, http.MethodDelete}},
+	{"/v1/movies/1/publish", []string{http.MethodPost}},
+	{"/v1/movies/1/unpublish", []string{http.MethodPost}},
+	{"/v1/movies/1/archive", []string{http.MethodPost}},
+	{"/v1/movies/1/merge", []string{http.MethodPost}},
+	{"/v1/movies/1/poster", []string{http.MethodPost}},
+	{"/v1/movie-validations", []string{http.MethodPost}},
+	{"/v1/schema/movies", []string{http.MethodGet}},
+	{"/v1/posters/1.jpg", []string{http.MethodGet}},
+	{"/v1/admin/audit-events", []string{http.MethodGet}},
+	{"/v1/changelog", []string{http.MethodGet}},
+	{"/debug/vars", []string{http.MethodGet}},
+	{"/metrics", []string{http.MethodGet}},
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	for _, route := range testRoutes {
+		allow := append([]string{http.MethodOptions}, route.methods...)
+		slices.Sort(allow)
+
+		paths := []string{route.path}
+		if rest, ok := strings.CutPrefix(route.path, "/v1"); ok && !strings.HasPrefix(rest, "/posters/") {
+			for _, version := range apiVersions[1:] {
+				paths = append(paths, "/"+version+rest)
+			}
+		}
+
+		for _, path := range paths {
+			for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+				if slices.Contains(route.methods, method) {
+					continue
+				}
+				t.Run(method+" "+path, func(t *testing.T) {
+					code, header, body := ts.do(t, method, path, "", adminHeader())
+					assert.Equal(t, code, http.StatusMethodNotAllowed)
+					assert.Equal(t, header.Get("Allow"), strings.Join(allow, ", "))
+					assert.Equal(t, header.Get("Content-Type"), "application/json")
+					// У ответа на HEAD нет тела.
+					if method != http.MethodHead {
+						assert.StringContains(t, body, `"error": "the `+method+` method is not supported for this resource"`)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	for _, path := range []string{
+		"/",
+		"/v1",
+		"/v3/movies",
+		"/movies",
+		"/v2/posters/1.jpg",
+		"/v1/movies/",
+		"/V1/MOVIES",
+		"/v1/movies/abc",
+		"/v1/movies/0",
+		"/v1/movies/-1",
+		"/v1/movies/999",
+		"/v1/movies/1/unknown",
+		"/v1/movies/abc/similar",
+		"/v1/movies/abc/translations",
+		"/v1/posters/missing.jpg",
+		"/v1/posters/",
+		"/debug/pprof/",
+	} {
+		t.Run(path, func(t *testing.T) {
+			code, header, body := ts.do(t, http.MethodGet, path, "", adminHeader())
+			assert.Equal(t, code, http.StatusNotFound)
+			assert.Equal(t, header.Get("Content-Type"), "application/json")
+			assert.StringContains(t, body, `"error": "the requested resource could not be found"`)
+		})
+	}
+
+	// Изменяющие запросы к несуществующим фильмам тоже получают 404.
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPatch, "/v1/movies/abc", `{}`},
+		{http.MethodDelete, "/v1/movies/999", ""},
+		{http.MethodPost, "/v1/movies/999/publish", ""},
+		{http.MethodPut, "/v1/movies/abc/translations/fr", `{"title":"Vaiana"}`},
+	} {
+		t.Run(req.method+" "+req.path, func(t *testing.T) {
+			code, _, body := ts.do(t, req.method, req.path, req.body, adminHeader())
+			assert.Equal(t, code, http.StatusNotFound)
+			assert.StringContains(t, body, `"error": "the requested resource could not be found"`)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	mark := func(name string) middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	h := chain(mark("a"), chain(mark("b"), mark("c")))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.DeepEqual(t, calls, []string{"a", "b", "c", "handler"})
+}
+
+// В закрытом каталоге анонимный клиент не может ни читать, ни изменять фильмы.
+func TestPrivateCatalog(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.catalog.public = false
+	ts := newTestServer(t, app.routes())
+
+	code, _, _ := ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, adminHeader())
+	assert.Equal(t, code, http.StatusCreated)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/v1/movies", ""},
+		{http.MethodGet, "/v1/movies/1", ""},
+		{http.MethodPost, "/v1/movies", `{"title":"Up","year":2009,"runtime":"96 mins","genres":["animation"]}`},
+		{http.MethodPost, "/v1/movie-validations", `{"title":"Up","year":2009,"runtime":"96 mins","genres":["animation"]}`},
+		{http.MethodPatch, "/v1/movies/1?dry_run=true", `{}`},
+		{http.MethodPatch, "/v1/movies/1", `{"year":2017}`},
+		{http.MethodPut, "/v1/movies/1/translations/ru", `{"title":"Моана"}`},
+		{http.MethodDelete, "/v1/movies/1", ""},
+	}
+	for _, tt := range tests {
+		code, _, body := ts.do(t, tt.method, tt.path, tt.body, nil)
+		assert.Equal(t, code, http.StatusUnauthorized)
+		assert.Equal(t, strings.Contains(body, "Moana"), false)
+	}
+
+	code, _, _ = ts.do(t, http.MethodPatch, "/v1/movies/1", `{"year":2017}`, adminHeader())
+	assert.Equal(t, code, http.StatusOK)
+	code, _, _ = ts.do(t, http.MethodDelete, "/v1/movies/1", "", adminHeader())
+	assert.Equal(t, code, http.StatusOK)
+}
+
+// У каждого класса маршрутов свой ограничитель; классы без собственных настроек
+// используют лимит по умолчанию.
+func TestRateLimitClasses(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.limiter.enabled = true
+	app.config.limiter.rps = 0.001
+	app.config.limiter.burst = 2
+	for _, class := range []string{"search=0.001:1", "auth=0.001:1"} {
+		assert.NilError(t, app.config.limiter.setClass(class))
+	}
+	ts := newTestServer(t, app.routes())
+
+	code, header, _ := ts.get(t, "/v1/movies")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("X-RateLimit-Limit"), "1")
+	code, _, _ = ts.do(t, http.MethodHead, "/v1/movies", "", nil)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+
+	code, header, _ = ts.get(t, "/v1/healthcheck")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("X-RateLimit-Limit"), "2")
+	code, _, _ = ts.get(t, "/v1/schema/movies")
+	assert.Equal(t, code, http.StatusOK)
+	code, _, _ = ts.get(t, "/v1/unknown")
+	assert.Equal(t, code, http.StatusTooManyRequests)
+
+	for _, want := range []int{http.StatusNotFound, http.StatusNotFound, http.StatusTooManyRequests} {
+		code, _, _ = ts.do(t, http.MethodPatch, "/v1/movies/999", `{}`, nil)
+		assert.Equal(t, code, want)
+	}
+
+	// Лимит маршрутов администратора проверяется до учетных данных.
+	code, _, _ = ts.do(t, http.MethodGet, "/v1/admin/audit-events", "", nil)
+	assert.Equal(t, code, http.StatusUnauthorized)
+	code, _, _ = ts.do(t, http.MethodGet, "/v1/admin/audit-events", "", adminHeader())
+	assert.Equal(t, code, http.StatusTooManyRequests)
+}
+
+func TestLimiterConfigSetClass(t *testing.T) {
+	var c limiterConfig
+	c.rps, c.burst = 2, 4
+
+	assert.NilError(t, c.setClass("write=0.5:1"))
+	assert.Equal(t, c.limitFor(limiterWriteClass), rateLimitSettings{rps: 0.5, burst: 1})
+	assert.Equal(t, c.limitFor(limiterSearchClass), rateLimitSettings{rps: 2, burst: 4})
+
+	for _, s := range []string{"write", "login=1:1", "write=1", "write=fast:1", "write=1:0"} {
+		if c.setClass(s) == nil {
+			t.Errorf("setClass(%q): expected an error", s)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	received, before := totalRequestsReceived.Value(), expvarMapValue(totalResponsesSentByStatus, "404")
+	ts.get(t, "/v1/unknown")
+
+	code, _, body := ts.do(t, http.MethodGet, "/debug/vars", "", adminHeader())
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"total_processing_time_μs"`)
+	assert.Equal(t, totalRequestsReceived.Value(), received+2)
+	assert.Equal(t, expvarMapValue(totalResponsesSentByStatus, "404"), before+1)
+}
+
+func TestRateLimitMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	app := newTestApplication(t)
+	app.logger = jsonlog.New(&buf, jsonlog.LevelInfo)
+	app.config.limiter.enabled = true
+	app.config.limiter.rps = 0.001
+	app.config.limiter.burst = 1
+	ts := newTestServer(t, app.routes())
+
+	allowed, rejected := expvarMapValue(rateLimiterAllowed, "search"), expvarMapValue(rateLimiterRejected, "search")
+	for range 3 {
+		ts.get(t, "/v1/movies")
+	}
+	assert.Equal(t, expvarMapValue(rateLimiterAllowed, "search"), allowed+1)
+	assert.Equal(t, expvarMapValue(rateLimiterRejected, "search"), rejected+2)
+
+	assert.StringContains(t, buf.String(), `"message":"request rate limited"`)
+	assert.StringContains(t, buf.String(), `"limiter_class":"search"`)
+	assert.StringContains(t, buf.String(), `"client_ip":"127.0.0.1"`)
+	assert.StringContains(t, buf.String(), `"request_path":"/v1/movies"`)
+}
+
+// Функция expvarMapValue() возвращает значение счетчика key из m (0, если его нет).
+func expvarMapValue(m *expvar.Map, key string) int64 {
+	v, _ := m.Get(key).(*expvar.Int)
+	if v == nil {
+		return 0
+	}
+	return v.Value()
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+
+	"greenlight.andreyklimov.net/internal/data"
+)
+
+// Обработчик movieSchemaHandler() возвращает описание полей фильма и параметров
+// списка фильмов. Описание строится из тех же ограничений, что проверяет
+// data.ValidateMovie(), поэтому интерфейсы могут проверять формы заранее, не
+// повторяя правила у себя.
+func (app *application) movieSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	schema := envelope{
+		"resource":        "movie",
+		"fields":          data.MovieSchema(),
+		"list_parameters": data.MovieListParameters(),
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"schema": schema}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
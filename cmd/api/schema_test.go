@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/data"
+)
+
+// Ограничения из схемы должны совпадать с тем, что на самом деле проверяет API.
+func TestMovieSchema(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, _, body := ts.get(t, "/v1/schema/movies")
+	assert.Equal(t, code, http.StatusOK)
+	resp := decodeJSON[struct {
+		Schema struct {
+			Fields []data.FieldSchema
+		}
+	}](t, body)
+
+	var title data.FieldSchema
+	for _, field := range resp.Schema.Fields {
+		if field.Name == "title" {
+			title = field
+		}
+	}
+	assert.Equal(t, title.Required, true)
+
+	validate := func(title string) int {
+		code, _, _ := ts.do(t, http.MethodPost, "/v1/movie-validations", fmt.Sprintf(`{"title":%q,"year":2016,"runtime":"107 mins","genres":["animation"]}`, title), nil)
+		return code
+	}
+	assert.Equal(t, validate(strings.Repeat("a", title.MaxBytes)), http.StatusOK)
+	assert.Equal(t, validate(strings.Repeat("a", title.MaxBytes+1)), http.StatusUnprocessableEntity)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/scheduler"
+)
+
+// Метод serve() создает HTTP-сервер и запускает его. Если в конфигурации заданы
+// сертификат и ключ, сервер работает по HTTPS, а если задан еще и CA клиентских
+// сертификатов — требует от каждого клиента сертификат, подписанный этим CA.
+//
+// Получив сигнал SIGINT или SIGTERM, сервер перестает принимать новые соединения,
+// дожидается завершения текущих запросов и фоновых задач (не дольше
+// -shutdown-timeout) и возвращает nil.
+func (app *application) serve() error {
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", app.config.port),
+		Handler: app.routes(),
+		// Создается новый экземпляр Go log.Logger с помощью log.New(),
+		// передавая кастомный Logger в качестве первого параметра.
+		// Пустая строка и 0 указывают, что экземпляр log.Logger
+		// не должен использовать префикс или какие-либо флаги.
+		ErrorLog:     log.New(app.logger, "", 0),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	tlsConfig, err := app.tlsConfig()
+	if err != nil {
+		return err
+	}
+	srv.TLSConfig = tlsConfig
+
+	// Периодические задачи обслуживания выполняются, пока работает сервер.
+	sched := scheduler.New(app.logger)
+	if app.config.scheduler.enabled {
+		app.scheduleJobs(sched)
+		sched.Start()
+	} else {
+		app.logger.PrintWarn("scheduler disabled: scheduled publishing, feature flag reloads and audit event pruning will not run", nil)
+	}
+
+	// Канал shutdownError получает результат корректного завершения работы сервера.
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.logger.PrintInfo("shutting down server", map[string]string{
+			"signal": s.String(),
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdownTimeout)
+		defer cancel()
+
+		// Метод Shutdown() возвращает nil, если все текущие запросы завершились
+		// до истечения контекста.
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+
+		app.logger.PrintInfo("completing background tasks", nil)
+
+		// Ждем завершения фоновых задач, но не дольше оставшегося времени.
+		done := make(chan struct{})
+		go func() {
+			sched.Stop()
+			app.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			shutdownError <- nil
+		case <-ctx.Done():
+			shutdownError <- fmt.Errorf("background tasks did not finish: %w", ctx.Err())
+		}
+	}()
+
+	// Снова используем метод PrintInfo() для записи сообщения "starting server"
+	// на уровне INFO. Но на этот раз передаем карту с дополнительными параметрами
+	// (операционная среда и адрес сервера) в качестве последнего параметра.
+	ln, err := listener(srv.Addr)
+	if err != nil {
+		return err
+	}
+
+	app.logger.PrintInfo("starting server", map[string]string{
+		"addr": ln.Addr().String(),
+		"env":  app.config.env,
+		"tls":  fmt.Sprint(tlsConfig != nil),
+	})
+
+	if tlsConfig != nil {
+		err = srv.ServeTLS(ln, app.config.tls.certFile, app.config.tls.keyFile)
+	} else {
+		err = srv.Serve(ln)
+	}
+	// После вызова Shutdown() метод Serve() сразу возвращает
+	// http.ErrServerClosed, поэтому только эта ошибка означает корректное завершение.
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	app.logger.PrintInfo("stopped server", map[string]string{
+		"addr": srv.Addr,
+	})
+	return nil
+}
+
+// Первый дескриптор, который передается процессу при активации через сокет
+// (SD_LISTEN_FDS_START в systemd).
+const listenFDsStart = 3
+
+// Функция listener() возвращает сокет, на котором сервер принимает соединения.
+// Если процесс запущен с активацией через сокет (systemd или родительский процесс
+// передал открытый сокет и установил LISTEN_FDS), используется унаследованный
+// сокет: новая версия приложения начинает принимать соединения, пока старая
+// завершает текущие запросы, и ни одно соединение не теряется. Иначе открывается
+// новый сокет на addr.
+func listener(addr string) (net.Listener, error) {
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return net.Listen("tcp", addr)
+	}
+
+	// systemd указывает в LISTEN_PID процесс, которому предназначены сокеты. Другие
+	// инструменты могут его не устанавливать.
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return net.Listen("tcp", addr)
+	}
+
+	// Убираем переменные окружения, чтобы их не унаследовали дочерние процессы.
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(uintptr(listenFDsStart), "listener")
+	defer f.Close()
+
+	// Функция net.FileListener() дублирует дескриптор, поэтому исходный файл
+	// можно закрыть.
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("inherited socket: %w", err)
+	}
+	return ln, nil
+}
+
+// Метод tlsConfig() возвращает настройки TLS сервера или nil, если сервер должен
+// работать по обычному HTTP.
+func (app *application) tlsConfig() (*tls.Config, error) {
+	cfg := app.config.tls
+
+	if cfg.certFile == "" && cfg.keyFile == "" {
+		if cfg.clientCAFile != "" {
+			return nil, errors.New("-tls-client-ca requires -tls-cert and -tls-key")
+		}
+		return nil, nil
+	}
+	if cfg.certFile == "" || cfg.keyFile == "" {
+		return nil, errors.New("both -tls-cert and -tls-key must be set")
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.clientCAFile != "" {
+		pem, err := os.ReadFile(cfg.clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.clientCAFile)
+		}
+
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// Метод clientCertIsAdmin() сообщает, предъявил ли клиент проверенный сертификат,
+// Common Name которого входит в список -tls-admin-subjects.
+func (app *application) clientCertIsAdmin(r *http.Request) bool {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return false
+	}
+	subject := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	return subject != "" && slices.Contains(app.config.tls.adminSubjects, subject)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Заголовки схемы подписи запросов для межсерверных клиентов.
+const (
+	signatureKeyIDHeader     = "X-Signature-Key-Id"
+	signatureTimestampHeader = "X-Signature-Timestamp"
+	signatureHeader          = "X-Signature"
+)
+
+var errInvalidSignature = errors.New("invalid request signature")
+
+// Функция parseSigningKeys() разбирает список ключей подписи в формате
+// "id1:secret1,id2:secret2".
+func parseSigningKeys(s string) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	if s == "" {
+		return keys, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("invalid signing key %q: expected id:secret", pair)
+		}
+		keys[id] = []byte(secret)
+	}
+	return keys, nil
+}
+
+// Тип signatureVerifier проверяет подписи запросов. Подпись — это HMAC-SHA256
+// (в шестнадцатеричной записи) от строки
+//
+//	METHOD\nPATH?QUERY\nTIMESTAMP\nhex(sha256(BODY))
+//
+// где TIMESTAMP — время подписи в секундах Unix. Чтобы подписанный запрос нельзя
+// было повторить, временная метка должна отличаться от текущего времени не больше
+// чем на maxSkew, а каждая подпись принимается только один раз.
+type signatureVerifier struct {
+	keys    map[string][]byte
+	maxSkew time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// Время последней очистки seen (см. prune()).
+	prunedAt time.Time
+}
+
+func newSignatureVerifier(keys map[string][]byte, maxSkew time.Duration) *signatureVerifier {
+	return &signatureVerifier{
+		keys:    keys,
+		maxSkew: maxSkew,
+		seen:    make(map[string]time.Time),
+	}
+}
+
+// Метод prune() удаляет запомненные подписи, временная метка которых вышла за
+// окно maxSkew: такие подписи и так отклоняются, поэтому хранить их не нужно.
+// Он вызывается при добавлении подписи не чаще раза в минуту и не зависит от
+// планировщика задач. Вызывающий код должен удерживать sv.mu.
+func (sv *signatureVerifier) prune() {
+	if time.Since(sv.prunedAt) < time.Minute {
+		return
+	}
+	sv.prunedAt = time.Now()
+
+	for signature, expires := range sv.seen {
+		if time.Now().After(expires) {
+			delete(sv.seen, signature)
+		}
+	}
+}
+
+// Метод verify() проверяет подпись запроса с телом body и возвращает
+// идентификатор ключа, которым запрос подписан.
+func (sv *signatureVerifier) verify(r *http.Request, body []byte) (string, error) {
+	keyID := r.Header.Get(signatureKeyIDHeader)
+	secret, ok := sv.keys[keyID]
+	if !ok {
+		return "", errInvalidSignature
+	}
+
+	timestamp := r.Header.Get(signatureTimestampHeader)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", errInvalidSignature
+	}
+	skew := time.Since(time.Unix(unix, 0))
+	if skew > sv.maxSkew || skew < -sv.maxSkew {
+		return "", errInvalidSignature
+	}
+
+	signature, err := hex.DecodeString(r.Header.Get(signatureHeader))
+	if err != nil {
+		return "", errInvalidSignature
+	}
+
+	// Подписывается исходная цель запроса: middleware apiVersion() может добавить
+	// к пути префикс версии до проверки подписи.
+	target := r.RequestURI
+	if target == "" {
+		target = r.URL.RequestURI()
+	}
+
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", r.Method, target, timestamp, hex.EncodeToString(bodyHash[:]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return "", errInvalidSignature
+	}
+
+	// Запоминаем подпись до истечения окна, в котором ее временная метка действительна.
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	sv.prune()
+
+	seenKey := keyID + ":" + hex.EncodeToString(signature)
+	if _, replayed := sv.seen[seenKey]; replayed {
+		return "", errInvalidSignature
+	}
+	sv.seen[seenKey] = time.Unix(unix, 0).Add(sv.maxSkew)
+
+	return keyID, nil
+}
+
+// Middleware verifySignature() проверяет подпись запросов, в которых есть заголовок
+// X-Signature, с ограничением размера тела как в readJSON().
+func (app *application) verifySignature(next http.Handler) http.Handler {
+	return app.verifySignatureLimit(1_048_576)(next)
+}
+
+// Метод verifySignatureLimit() возвращает middleware, которое проверяет подпись
+// запросов с телом не больше maxBytes. Запросы без подписи обрабатываются как обычно,
+// а запросы с неверной, просроченной или повторной подписью отклоняются с кодом 401.
+// Запрос с верной подписью считается запросом администратора (см. authenticateAdmin()):
+// ключи подписи выдаются только доверенным межсерверным клиентам.
+func (app *application) verifySignatureLimit(maxBytes int64) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(signatureHeader) == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Тело ограничивается тем же размером, что и в обработчике маршрута, и после
+			// проверки подписи подставляется обратно, чтобы обработчик мог его прочитать.
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+			if err != nil {
+				app.badRequestResponse(w, r, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			keyID, err := app.signatures.verify(r, body)
+			if err != nil {
+				app.contextGetLogger(r).PrintWarn("request signature rejected", map[string]string{
+					"key_id": r.Header.Get(signatureKeyIDHeader),
+				})
+				app.invalidSignatureResponse(w, r)
+				return
+			}
+
+			// Идентификатор ключа попадает в контекст и во все записи лога этого запроса.
+			r = app.contextSetSignatureKeyID(r, keyID)
+			r = app.contextSetLogger(r, app.contextGetLogger(r).With(map[string]string{
+				"signature_key_id": keyID,
+			}))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/png"
+	"math/rand/v2"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/assert"
+)
+
+// Функция signedHeader() возвращает заголовки подписи запроса ключом keyID
+// с секретом secret.
+func signedHeader(keyID, secret, method, target string, body []byte) http.Header {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, target, timestamp, hex.EncodeToString(bodyHash[:]))
+
+	return http.Header{
+		signatureKeyIDHeader:     {keyID},
+		signatureTimestampHeader: {timestamp},
+		signatureHeader:          {hex.EncodeToString(mac.Sum(nil))},
+	}
+}
+
+func TestSignedRequestsAuthenticate(t *testing.T) {
+	app := newTestApplication(t)
+	app.signatures = newSignatureVerifier(map[string][]byte{"billing": []byte("s3cret")}, time.Minute)
+	ts := newTestServer(t, app.routes())
+
+	body := `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"],"status":"draft"}`
+	header := signedHeader("billing", "s3cret", http.MethodPost, "/v1/movies", []byte(body))
+	code, _, _ := ts.do(t, http.MethodPost, "/v1/movies", body, header)
+	assert.Equal(t, code, http.StatusCreated)
+
+	// Повторная отправка той же подписи отклоняется.
+	code, _, _ = ts.do(t, http.MethodPost, "/v1/movies", body, header)
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	// Подписанный запрос видит черновик и проходит проверку администратора.
+	code, _, _ = ts.do(t, http.MethodGet, "/v1/movies/1", "", signedHeader("billing", "s3cret", http.MethodGet, "/v1/movies/1", nil))
+	assert.Equal(t, code, http.StatusOK)
+	code, _, _ = ts.do(t, http.MethodPost, "/v1/movies/1/publish", "", signedHeader("billing", "s3cret", http.MethodPost, "/v1/movies/1/publish", nil))
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _, _ = ts.do(t, http.MethodPost, "/v1/movies/1/archive", "", signedHeader("billing", "wrong", http.MethodPost, "/v1/movies/1/archive", nil))
+	assert.Equal(t, code, http.StatusUnauthorized)
+	code, _, _ = ts.do(t, http.MethodPost, "/v1/movies/1/archive", "", nil)
+	assert.Equal(t, code, http.StatusUnauthorized)
+}
+
+// Подписи с истекшей временной меткой удаляются при проверке новых подписей.
+func TestSignatureVerifierPrunesOnInsert(t *testing.T) {
+	sv := newSignatureVerifier(map[string][]byte{"billing": []byte("s3cret")}, time.Minute)
+	sv.seen["billing:expired"] = time.Now().Add(-time.Second)
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+	r.Header = signedHeader("billing", "s3cret", http.MethodGet, "/v1/movies", nil)
+	keyID, err := sv.verify(r, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, keyID, "billing")
+	assert.Equal(t, len(sv.seen), 1)
+}
+
+func TestSignedPosterUpload(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.storage.posterMaxSize = 4 << 20
+	app.signatures = newSignatureVerifier(map[string][]byte{"cms": []byte("s3cret")}, time.Minute)
+	ts := newTestServer(t, app.routes())
+
+	code, _, _ := ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, adminHeader())
+	assert.Equal(t, code, http.StatusCreated)
+
+	// Изображение из случайного шума почти не сжимается, поэтому тело запроса
+	// больше лимита JSON-запросов (1 МБ).
+	img := image.NewRGBA(image.Rect(0, 0, 800, 800))
+	for i := range img.Pix {
+		img.Pix[i] = byte(rand.IntN(256))
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("poster", "poster.png")
+	assert.NilError(t, err)
+	assert.NilError(t, png.Encode(part, img))
+	assert.NilError(t, mw.Close())
+	if body.Len() <= 1<<20 {
+		t.Fatalf("poster body is only %d bytes", body.Len())
+	}
+
+	header := signedHeader("cms", "s3cret", http.MethodPost, "/v1/movies/1/poster", body.Bytes())
+	header.Set("Content-Type", mw.FormDataContentType())
+	code, _, resp := ts.do(t, http.MethodPost, "/v1/movies/1/poster", body.String(), header)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, resp, `"poster_url"`)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/validator"
+)
+
+// Обработчик similarMoviesHandler() возвращает фильмы, похожие на фильм из URL, по
+// сходству названия и общим жанрам. Клиентам без прав администратора показываются
+// только опубликованные фильмы.
+func (app *application) similarMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	limit := app.readInt(r.URL.Query(), "limit", 10, v)
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 50, "limit", "must be a maximum of 50")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	status := ""
+	if !app.isAdmin(r) {
+		if movie.Status != data.MoviePublished {
+			app.notFoundResponse(w, r)
+			return
+		}
+		status = data.MoviePublished
+	}
+
+	similar, err := app.models.Movies.GetSimilar(r.Context(), movie, status, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	for _, s := range similar {
+		app.setPosterURL(s.Movie)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": similar}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Метод possibleDuplicates() ищет фильмы, которые, вероятно, дублируют movie. Поиск
+// носит рекомендательный характер, поэтому его ошибка только записывается в лог.
+func (app *application) possibleDuplicates(r *http.Request, movie *data.Movie) []*data.Movie {
+	duplicates, err := app.models.Movies.FindDuplicates(r.Context(), movie)
+	if err != nil {
+		app.logError(r, err)
+		return nil
+	}
+	app.setPosterURL(duplicates...)
+	return duplicates
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"iter"
+	"net/http"
+	"slices"
+)
+
+// Страницы списков, в которых больше элементов, отправляются потоком (см.
+// streamJSONList()).
+const streamListThreshold = 50
+
+// Флаг, включающий потоковую отправку больших страниц (см. пакет featureflags).
+// Пока флаг выключен, все страницы собираются в памяти и получают ETag.
+const featureStreamLists = "stream_movie_lists"
+
+// Метод streamJSONList() отправляет ответ 200 OK с конвертом, в котором поле key
+// содержит элементы items, а остальные поля берутся из fields. В отличие от
+// writeJSON(), закодированный ответ не собирается в памяти целиком: элементы
+// кодируются и записываются по одному, поэтому буфер кодирования ограничен размером
+// одного элемента. Сами элементы при этом уже загружены обработчиком (например,
+// страница фильмов из GetAll()), так что экономится только копия ответа в виде
+// байтов, а не память на выборку. Результат побайтно совпадает с тем, что вернул
+// бы writeJSON().
+//
+// Поскольку тело ответа заранее неизвестно, ETag не вычисляется, условные запросы
+// не поддерживаются, а ответ не сохраняется в кеше ответов (см. skipRecording()).
+// Заголовки отправляются до кодирования, поэтому ошибку, возникшую в процессе, уже
+// нельзя вернуть клиенту: она логируется, а ответ обрывается.
+func (app *application) streamJSONList(w http.ResponseWriter, r *http.Request, fields envelope, key string, items iter.Seq[any]) {
+	skipRecording(w)
+	w.Header().Set("Cache-Control", app.cacheControl(r))
+
+	buf := jsonBufferPool.Get().(*jsonBuffer)
+	buf.Reset()
+	defer releaseJSONBuffer(buf)
+
+	// Поля конверта выводятся в порядке сортировки ключей, как при кодировании карты.
+	keys := []string{key}
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	s := jsonStream{w: w, buf: buf, compact: app.config.json.compact}
+	s.write("{")
+	for i, k := range keys {
+		if i > 0 {
+			s.write(",")
+		}
+		s.newline(1)
+		s.encode(k, 1)
+		s.write(":")
+		if !s.compact {
+			s.write(" ")
+		}
+
+		if k != key {
+			s.encode(fields[k], 1)
+			continue
+		}
+
+		s.write("[")
+		empty := true
+		for item := range items {
+			if !empty {
+				s.write(",")
+			}
+			empty = false
+			s.newline(2)
+			s.encode(item, 2)
+		}
+		if !empty {
+			s.newline(1)
+		}
+		s.write("]")
+	}
+	s.newline(0)
+	s.write("}\n")
+
+	if s.err != nil {
+		app.logError(r, s.err)
+	}
+}
+
+// Функция anyValues() возвращает итератор по элементам items для streamJSONList().
+func anyValues[T any](items []T) iter.Seq[any] {
+	return func(yield func(any) bool) {
+		for _, item := range items {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// jsonStream записывает JSON в http.ResponseWriter по частям, повторяя форматирование
+// json.MarshalIndent() (с отступом из табуляций) или компактный вывод. После первой
+// ошибки все последующие записи пропускаются.
+type jsonStream struct {
+	w       http.ResponseWriter
+	buf     *jsonBuffer
+	compact bool
+	err     error
+}
+
+func (s *jsonStream) write(str string) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = io.WriteString(s.w, str)
+}
+
+// Строка с запасом табуляций для отступов: вложенность в конверте не больше двух.
+const jsonIndent = "\n\t\t"
+
+// Метод newline() переводит строку и добавляет depth табуляций (в компактном
+// режиме ничего не делает).
+func (s *jsonStream) newline(depth int) {
+	if s.compact {
+		return
+	}
+	s.write(jsonIndent[:depth+1])
+}
+
+// Метод encode() кодирует значение, вложенное на глубину depth.
+func (s *jsonStream) encode(v any, depth int) {
+	if s.err != nil {
+		return
+	}
+
+	s.buf.Reset()
+	if s.compact {
+		s.buf.enc.SetIndent("", "")
+	} else {
+		s.buf.enc.SetIndent(jsonIndent[1:depth+1], "\t")
+	}
+	s.err = s.buf.enc.Encode(v)
+	if s.err != nil {
+		return
+	}
+
+	// Encode() добавляет перевод строки, который здесь не нужен.
+	_, s.err = s.w.Write(bytes.TrimSuffix(s.buf.Bytes(), []byte("\n")))
+}
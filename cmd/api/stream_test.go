@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/fixtures"
+)
+
+func TestStreamJSONListMatchesWriteJSON(t *testing.T) {
+	metadata := data.Metadata{CurrentPage: 1, PageSize: 3, FirstPage: 1, LastPage: 1, TotalRecords: 3}
+
+	tests := []struct {
+		name    string
+		movies  []*data.Movie
+		compact bool
+	}{
+		{"Indented", testMovies(3), false},
+		{"Compact", testMovies(3), true},
+		{"Empty indented", []*data.Movie{}, false},
+		{"Empty compact", []*data.Movie{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.json.compact = tt.compact
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+
+			want := httptest.NewRecorder()
+			err := app.writeJSON(want, http.StatusOK, envelope{"movies": tt.movies, "metadata": metadata}, nil)
+			assert.NilError(t, err)
+
+			got := httptest.NewRecorder()
+			app.streamJSONList(got, r, envelope{"metadata": metadata}, "movies", anyValues(tt.movies))
+
+			assert.Equal(t, got.Code, http.StatusOK)
+			assert.Equal(t, got.Body.String(), want.Body.String())
+		})
+	}
+}
+
+func TestListMoviesStreamsLargePages(t *testing.T) {
+	newServer := func(t *testing.T, features ...string) *testServer {
+		app := newTestApplication(t)
+		enableFeatures(t, app, features...)
+		err := fixtures.Generate(context.Background(), app.models, fixtures.NewGenerator(1), 60, 60, nil)
+		assert.NilError(t, err)
+		return newTestServer(t, app.routes())
+	}
+
+	// Пока флаг выключен, большие страницы собираются в памяти.
+	ts := newServer(t)
+	code, header, _ := ts.get(t, "/v1/movies?page_size=100")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("ETag") != "", true)
+
+	ts = newServer(t, featureStreamLists)
+
+	// Небольшие страницы по-прежнему получают ETag, а потоковые — нет.
+	code, header, _ = ts.get(t, "/v1/movies?page_size=10")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("ETag") != "", true)
+
+	code, header, body := ts.get(t, "/v1/movies?page_size=100")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("ETag"), "")
+
+	resp := decodeJSON[struct {
+		Movies   []data.Movie  `json:"movies"`
+		Metadata data.Metadata `json:"metadata"`
+	}](t, body)
+	assert.Equal(t, len(resp.Movies), 60)
+	assert.Equal(t, resp.Metadata.TotalRecords, 60)
+}
+
+// Кеш ответов не копирует тело потокового ответа, даже если между ним и
+// обработчиком есть другие обертки http.ResponseWriter.
+func TestStreamJSONListSkipsRecording(t *testing.T) {
+	app := newTestApplication(t)
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+
+	w := httptest.NewRecorder()
+	rec := newResponseRecorder(w)
+	app.streamJSONList(&statusResponseWriter{ResponseWriter: rec, status: http.StatusOK}, r, envelope{}, "movies", anyValues(testMovies(60)))
+
+	assert.Equal(t, rec.skipped, true)
+	assert.Equal(t, rec.body.Len(), 0)
+	assert.StringContains(t, w.Body.String(), `"movies": [`)
+}
+
+// BenchmarkStreamJSONList сравнивает потоковую отправку страницы из 100 фильмов
+// с writeJSON().
+func BenchmarkStreamJSONList(b *testing.B) {
+	movies := testMovies(100)
+	metadata := data.Metadata{CurrentPage: 1, PageSize: 100}
+	w := &discardResponseWriter{header: make(http.Header)}
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+	app := newTestApplication(b)
+
+	b.Run("WriteJSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			err := app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Stream", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			app.streamJSONList(w, r, envelope{"metadata": metadata}, "movies", anyValues(movies))
+		}
+	})
+}
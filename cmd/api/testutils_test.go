@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/featureflags"
+	"greenlight.andreyklimov.net/internal/jsonlog"
+	"greenlight.andreyklimov.net/internal/storage"
+)
+
+// Учетные данные администратора в тестовом приложении.
+const (
+	testAdminUsername = "admin"
+	testAdminPassword = "pa55word"
+)
+
+// Функция newTestApplication() возвращает приложение с моделями в памяти
+// (см. data.NewMockModels()), локальным хранилищем постеров во временном каталоге
+// и логгером, который ничего не пишет. Каталог, как и по умолчанию, публичный. Ограничение частоты запросов,
+// журнал запросов, CORS и сжатие ответов отключены, а Redis не используется.
+func newTestApplication(t testing.TB) *application {
+	t.Helper()
+
+	var cfg config
+	cfg.env = "testing"
+	cfg.requestTimeout = 5 * time.Second
+	cfg.catalog.public = true
+	cfg.admin.username = testAdminUsername
+	cfg.admin.password = testAdminPassword
+	cfg.auth.maxFailures = 5
+	cfg.auth.lockout = time.Minute
+	cfg.auth.maxLockout = time.Hour
+	cfg.signing.maxSkew = 5 * time.Minute
+	cfg.storage.posterMaxSize = 1 << 20
+	cfg.middleware.metrics = true
+
+	posters, err := storage.NewLocal(t.TempDir(), "/v1/posters")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	features, err := featureflags.Load("", cfg.env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		config:      cfg,
+		logger:      jsonlog.New(io.Discard, jsonlog.LevelOff),
+		models:      data.NewMockModels(),
+		authLockout: newAuthLockout(cfg.auth.maxFailures, cfg.auth.lockout, cfg.auth.maxLockout),
+		signatures:  newSignatureVerifier(nil, cfg.signing.maxSkew),
+		storage:     posters,
+		features:    features,
+	}
+	// Дожидаемся фоновых задач, чтобы они не пережили тест.
+	t.Cleanup(app.wg.Wait)
+
+	return app
+}
+
+// Функция enableFeatures() включает флаги names для всех клиентов тестового
+// приложения. Ее нужно вызывать до запуска тестового сервера.
+func enableFeatures(t testing.TB, app *application, names ...string) {
+	t.Helper()
+
+	flags := make(map[string]featureflags.Flag, len(names))
+	for _, name := range names {
+		flags[name] = featureflags.Flag{Enabled: true}
+	}
+	content, err := json.Marshal(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "features.json")
+	err = os.WriteFile(path, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.features, err = featureflags.Load(path, app.config.env)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testServer оборачивает httptest.Server и добавляет методы для выполнения
+// запросов к нему.
+type testServer struct {
+	*httptest.Server
+}
+
+// Функция newTestServer() запускает тестовый сервер с обработчиком h и
+// останавливает его по завершении теста.
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return &testServer{ts}
+}
+
+// Метод do() выполняет запрос с телом body (может быть пустым) и заголовками
+// header (может быть nil) и возвращает код состояния, заголовки и тело ответа.
+func (ts *testServer) do(t *testing.T, method, urlPath, body string, header http.Header) (int, http.Header, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	respBody, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(respBody))
+}
+
+// Метод get() выполняет GET-запрос без дополнительных заголовков.
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	t.Helper()
+	return ts.do(t, http.MethodGet, urlPath, "", nil)
+}
+
+// Функция adminHeader() возвращает заголовки с учетными данными администратора
+// тестового приложения.
+func adminHeader() http.Header {
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth(testAdminUsername, testAdminPassword)
+	return req.Header
+}
+
+// Функция decodeJSON() разбирает JSON-ответ в значение типа T.
+func decodeJSON[T any](t *testing.T, body string) T {
+	t.Helper()
+
+	var v T
+	err := json.Unmarshal([]byte(body), &v)
+	if err != nil {
+		t.Fatalf("cannot decode %q: %v", body, err)
+	}
+	return v
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/validator"
+)
+
+// Обработчик validateMovieHandler() проверяет фильм так же, как createMovieHandler(),
+// но не сохраняет его и не обращается к базе данных, поэтому интерфейсы могут
+// проверять форму по мере ввода. Ошибки возвращаются как при создании фильма
+// (422 с полем error), а корректный фильм — в поле movie вместе с "valid": true.
+// Эндпоинт зарегистрирован как POST /v1/movie-validations, а не /v1/movies/validate:
+// httprouter не допускает статический сегмент рядом с параметром /v1/movies/:id.
+func (app *application) validateMovieHandler(w http.ResponseWriter, r *http.Request) {
+	movie, err := app.readNewMovie(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	app.validMovieResponse(w, r, movie)
+}
+
+// Метод validMovieResponse() отвечает на пробный запрос (dry_run=true), в котором
+// фильм прошел проверку: фильм возвращается таким, каким он был бы сохранен.
+func (app *application) validMovieResponse(w http.ResponseWriter, r *http.Request, movie *data.Movie) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"valid": true, "movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/data"
+)
+
+func TestValidateMovie(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, _, body := ts.do(t, http.MethodPost, "/v1/movie-validations", `{"title":"","year":2016,"runtime":"107 mins","genres":["animation"]}`, nil)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, `"title": "must be provided"`)
+
+	code, _, body = ts.do(t, http.MethodPost, "/v1/movie-validations", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, nil)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"valid": true`)
+
+	// Пробные создание и правка ничего не сохраняют.
+	code, _, _ = ts.do(t, http.MethodPost, "/v1/movies?dry_run=true", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, nil)
+	assert.Equal(t, code, http.StatusOK)
+	_, _, body = ts.get(t, "/v1/movies")
+	assert.Equal(t, len(decodeJSON[struct{ Movies []data.Movie }](t, body).Movies), 0)
+
+	code, _, _ = ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, nil)
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, _, body = ts.do(t, http.MethodPatch, "/v1/movies/1?dry_run=true", `{"year":1066}`, nil)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, `"year"`)
+
+	code, _, body = ts.do(t, http.MethodPatch, "/v1/movies/1?dry_run=true", `{"title":"Moana 2"}`, nil)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"title": "Moana 2"`)
+
+	_, _, body = ts.get(t, "/v1/movies/1")
+	assert.StringContains(t, body, `"title": "Moana"`)
+	assert.StringContains(t, body, `"version": 1`)
+}
+
+func TestValidationErrorDetails(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, _, body := ts.do(t, http.MethodPost, "/v1/movie-validations", `{"title":"Moana","year":3016,"runtime":"107 mins","genres":["animation","animation"]}`, nil)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	resp := decodeJSON[struct {
+		Error        map[string]string
+		ErrorDetails []map[string]any `json:"error_details"`
+	}](t, body)
+	assert.Equal(t, resp.Error["year"], "must not be in the future")
+	assert.Equal(t, len(resp.ErrorDetails), 2)
+	assert.Equal(t, resp.ErrorDetails[0]["field"], any("year"))
+	assert.Equal(t, resp.ErrorDetails[0]["code"], any("max"))
+	assert.Equal(t, resp.ErrorDetails[0]["value"], any(float64(3016)))
+	assert.Equal(t, resp.ErrorDetails[1]["code"], any("unique"))
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	header := http.Header{"Accept-Language": {"ru-RU, ru;q=0.9, en;q=0.8"}}
+	code, h, body := ts.do(t, http.MethodPost, "/v1/movie-validations", `{"title":"","year":2016,"runtime":"107 mins","genres":["animation"],"tagline":"`+strings.Repeat("a", 301)+`"}`, header)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.Equal(t, h.Get("Content-Language"), "ru")
+	assert.StringContains(t, body, `"title": "обязательное поле"`)
+	assert.StringContains(t, body, `"tagline": "должно быть не длиннее 300 байт"`)
+	assert.StringContains(t, body, `"message": "обязательное поле"`)
+
+	code, h, body = ts.do(t, http.MethodGet, "/v1/movies/42", "", header)
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.StringContains(t, body, "запрошенный ресурс не найден")
+
+	// Без перевода на запрошенный язык сообщения остаются на английском.
+	code, h, body = ts.do(t, http.MethodGet, "/v1/movies/42", "", http.Header{"Accept-Language": {"de"}})
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, h.Get("Content-Language"), "en")
+	assert.StringContains(t, body, "the requested resource could not be found")
+}
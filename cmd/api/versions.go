@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Поддерживаемые версии API по возрастанию. Маршруты всех версий обслуживаются
+// одними и теми же обработчиками; там, где поведение версий различается, обработчик
+// проверяет версию запроса (см. contextGetAPIVersion()).
+var apiVersions = []string{"v1", "v2"}
+
+// Медиатип, которым клиент может выбрать версию API в заголовке Accept, например
+// application/vnd.greenlight.v2+json.
+var apiVersionMediaTypeRX = regexp.MustCompile(`^application/vnd\.greenlight\.(v[0-9]+)\+json$`)
+
+// apiChange описывает несовместимое изменение маршрута в версии Version. Тот же
+// маршрут в предыдущих версиях считается устаревшим с даты Date и отключается
+// после даты Sunset.
+type apiChange struct {
+	Version     string    `json:"version"`
+	Date        time.Time `json:"date"`
+	Sunset      time.Time `json:"sunset"`
+	Method      string    `json:"method"`
+	Route       string    `json:"route"`
+	Description string    `json:"description"`
+}
+
+// Журнал несовместимых изменений API, который отдает changelogHandler().
+var apiChangelog = []apiChange{
+	{
+		Version:     "v2",
+		Date:        time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC),
+		Sunset:      time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC),
+		Method:      http.MethodDelete,
+		Route:       "/movies/:id",
+		Description: "responds with 204 No Content without a body instead of 200 OK with a message",
+	},
+}
+
+// Middleware apiVersion() определяет версию API запроса по префиксу пути (/v1, /v2)
+// и сохраняет ее в контексте запроса. Запросы без префикса версии обслуживаются,
+// если версия указана в заголовке Accept: к пути добавляется соответствующий
+// префикс. Если версия указана и в пути, и в Accept, выигрывает путь.
+func (app *application) apiVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if !slices.Contains(apiVersions, version) {
+			accepted, ok := acceptedAPIVersion(r)
+			switch {
+			case !ok || strings.HasPrefix(r.URL.Path, "/debug/"):
+				next.ServeHTTP(w, r)
+				return
+			case !slices.Contains(apiVersions, accepted):
+				app.unsupportedAPIVersionResponse(w, r, accepted)
+				return
+			}
+
+			version = accepted
+			w.Header().Add("Vary", "Accept")
+			r.URL.Path = "/" + version + r.URL.Path
+			if r.URL.RawPath != "" {
+				r.URL.RawPath = "/" + version + r.URL.RawPath
+			}
+		}
+
+		w.Header().Set("API-Version", version)
+		next.ServeHTTP(w, app.contextSetAPIVersion(r, version))
+	})
+}
+
+// Функция acceptedAPIVersion() возвращает версию API из заголовка Accept и true,
+// если клиент запросил медиатип конкретной версии.
+func acceptedAPIVersion(r *http.Request) (string, bool) {
+	for _, header := range r.Header.Values("Accept") {
+		for _, value := range strings.Split(header, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(value))
+			if err != nil {
+				continue
+			}
+			if m := apiVersionMediaTypeRX.FindStringSubmatch(mediaType); m != nil {
+				return m[1], true
+			}
+		}
+	}
+	return "", false
+}
+
+// Middleware deprecation() добавляет к ответам маршрута route версии version
+// заголовки Deprecation (RFC 9745) и Sunset (RFC 8594), если в более поздней
+// версии этот маршрут изменился. Заголовок Link указывает на журнал изменений
+// и на тот же ресурс в новой версии.
+func (app *application) deprecation(version, method, route string, next http.Handler) http.Handler {
+	for _, change := range apiChangelog {
+		if change.Method != method || change.Route != route || slices.Index(apiVersions, change.Version) <= slices.Index(apiVersions, version) {
+			continue
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			successor := "/" + change.Version + strings.TrimPrefix(r.URL.Path, "/"+version)
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", change.Date.Unix()))
+			w.Header().Set("Sunset", change.Sunset.Format(http.TimeFormat))
+			w.Header().Add("Link", fmt.Sprintf(`</%s/changelog>; rel="deprecation"`, change.Version))
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+			next.ServeHTTP(w, r)
+		})
+	}
+	return next
+}
+
+// Обработчик changelogHandler() возвращает список версий API и журнал их
+// несовместимых изменений.
+func (app *application) changelogHandler(w http.ResponseWriter, r *http.Request) {
+	env := envelope{
+		"versions":       apiVersions,
+		"latest_version": apiVersions[len(apiVersions)-1],
+		"changes":        apiChangelog,
+	}
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
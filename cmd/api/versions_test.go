@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/assert"
+)
+
+func TestAPIVersionNegotiation(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, header, _ := ts.do(t, http.MethodPost, "/v2/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, adminHeader())
+	assert.Equal(t, code, http.StatusCreated)
+	assert.Equal(t, header.Get("API-Version"), "v2")
+	assert.Equal(t, header.Get("Location"), "/v2/movies/1")
+
+	// Без префикса версии в пути версия выбирается заголовком Accept.
+	code, header, body := ts.do(t, http.MethodGet, "/movies/1", "", http.Header{"Accept": {"application/vnd.greenlight.v1+json"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("API-Version"), "v1")
+	assert.Equal(t, slices.Contains(header.Values("Vary"), "Accept"), true)
+	assert.StringContains(t, body, `"title": "Moana"`)
+
+	// Префикс пути важнее заголовка Accept.
+	_, header, _ = ts.do(t, http.MethodGet, "/v2/movies/1", "", http.Header{"Accept": {"application/vnd.greenlight.v1+json"}})
+	assert.Equal(t, header.Get("API-Version"), "v2")
+
+	code, _, body = ts.do(t, http.MethodGet, "/movies/1", "", http.Header{"Accept": {"application/json, application/vnd.greenlight.v9+json"}})
+	assert.Equal(t, code, http.StatusNotAcceptable)
+	assert.StringContains(t, body, `"error": "API version v9 is not supported"`)
+
+	code, _, _ = ts.get(t, "/movies/1")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestAPIVersionDeprecation(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	for range 2 {
+		ts.do(t, http.MethodPost, "/v1/movies", `{"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation"]}`, adminHeader())
+	}
+
+	// Неизмененные маршруты v1 не устарели.
+	_, header, _ := ts.get(t, "/v1/movies/1")
+	assert.Equal(t, header.Get("Deprecation"), "")
+
+	code, header, body := ts.do(t, http.MethodDelete, "/v1/movies/1", "", nil)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"message"`)
+	assert.Equal(t, header.Get("Deprecation"), "@1792108800")
+	assert.Equal(t, header.Get("Sunset"), "Fri, 30 Apr 2027 00:00:00 GMT")
+	assert.DeepEqual(t, header.Values("Link"), []string{`</v2/changelog>; rel="deprecation"`, `</v2/movies/1>; rel="successor-version"`})
+
+	code, header, body = ts.do(t, http.MethodDelete, "/v2/movies/2", "", nil)
+	assert.Equal(t, code, http.StatusNoContent)
+	assert.Equal(t, header.Get("Deprecation"), "")
+	assert.Equal(t, body, "")
+}
+
+func TestChangelog(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, _, body := ts.get(t, "/v1/changelog")
+	assert.Equal(t, code, http.StatusOK)
+
+	resp := decodeJSON[struct {
+		Versions      []string
+		LatestVersion string `json:"latest_version"`
+		Changes       []apiChange
+	}](t, body)
+	assert.DeepEqual(t, resp.Versions, apiVersions)
+	assert.Equal(t, resp.LatestVersion, "v2")
+	assert.Equal(t, len(resp.Changes), len(apiChangelog))
+	assert.Equal(t, resp.Changes[0].Route, "/movies/:id")
+	assert.Equal(t, resp.Changes[0].Sunset.After(resp.Changes[0].Date), true)
+	assert.Equal(t, resp.Changes[0].Date.Equal(time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)), true)
+}
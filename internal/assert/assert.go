@@ -0,0 +1,47 @@
+// Пакет assert содержит вспомогательные функции проверок для тестов. В отличие
+// от t.Fatal(), проверки не останавливают тест, чтобы за один запуск были видны
+// все расхождения.
+package assert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Функция Equal() проверяет, что фактическое значение равно ожидаемому.
+func Equal[T comparable](t *testing.T, actual, expected T) {
+	t.Helper()
+
+	if actual != expected {
+		t.Errorf("got: %v; want: %v", actual, expected)
+	}
+}
+
+// Функция DeepEqual() аналогична Equal(), но сравнивает значения с помощью
+// reflect.DeepEqual(), поэтому подходит для срезов и карт.
+func DeepEqual(t *testing.T, actual, expected any) {
+	t.Helper()
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("got: %v; want: %v", actual, expected)
+	}
+}
+
+// Функция StringContains() проверяет, что строка содержит подстроку.
+func StringContains(t *testing.T, actual, expectedSubstring string) {
+	t.Helper()
+
+	if !strings.Contains(actual, expectedSubstring) {
+		t.Errorf("got: %q; expected to contain: %q", actual, expectedSubstring)
+	}
+}
+
+// Функция NilError() проверяет, что ошибки нет.
+func NilError(t *testing.T, actual error) {
+	t.Helper()
+
+	if actual != nil {
+		t.Errorf("got: %v; expected: nil", actual)
+	}
+}
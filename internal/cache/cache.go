@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Client — это обертка над клиентом Redis, которая добавляет ко всем ключам общий
+// префикс, чтобы несколько приложений могли использовать один экземпляр Redis.
+type Client struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+// New создает клиент Redis и проверяет соединение с сервером.
+func New(addr, password string, db int, prefix string) (*Client, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := rdb.Ping(ctx).Err()
+	if err != nil {
+		rdb.Close()
+		return nil, err
+	}
+
+	return &Client{rdb: rdb, prefix: prefix}, nil
+}
+
+// Get возвращает значение по ключу. Второе возвращаемое значение равно false,
+// если ключ не найден.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.rdb.Get(ctx, c.prefix+key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set сохраняет значение по ключу с указанным временем жизни.
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.rdb.Set(ctx, c.prefix+key, value, ttl).Err()
+}
+
+// Incr атомарно увеличивает целочисленное значение ключа на единицу и возвращает
+// новое значение.
+func (c *Client) Incr(ctx context.Context, key string) (int64, error) {
+	return c.rdb.Incr(ctx, c.prefix+key).Result()
+}
+
+// GetInt возвращает целочисленное значение ключа или 0, если ключ не найден.
+func (c *Client) GetInt(ctx context.Context, key string) (int64, error) {
+	value, found, err := c.Get(ctx, key)
+	if err != nil || !found {
+		return 0, err
+	}
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+// RateLimitResult описывает результат проверки ограничения частоты запросов.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Время до начала следующего окна, когда счетчик будет сброшен.
+	Reset time.Duration
+}
+
+// Allow реализует ограничение частоты запросов с фиксированным окном, общее для
+// всех экземпляров приложения: в каждом окне длительностью window по ключу key
+// разрешается не более limit запросов.
+func (c *Client) Allow(ctx context.Context, key string, limit int, window time.Duration) (RateLimitResult, error) {
+	now := time.Now()
+	windowIndex := now.UnixNano() / int64(window)
+	windowEnd := time.Unix(0, (windowIndex+1)*int64(window))
+
+	// Номер текущего окна входит в ключ, поэтому счетчики разных окон независимы.
+	windowKey := c.prefix + "ratelimit:" + key + ":" + strconv.FormatInt(windowIndex, 10)
+
+	// INCR и PEXPIRE выполняются в одной транзакции MULTI/EXEC.
+	pipe := c.rdb.TxPipeline()
+	count := pipe.Incr(ctx, windowKey)
+	pipe.PExpire(ctx, windowKey, window)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return RateLimitResult{
+		Allowed:   count.Val() <= int64(limit),
+		Remaining: max(limit-int(count.Val()), 0),
+		Reset:     windowEnd.Sub(now),
+	}, nil
+}
+
+// Close закрывает соединения с Redis.
+func (c *Client) Close() error {
+	return c.rdb.Close()
+}
@@ -0,0 +1,188 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Действия, которые записываются в журнал аудита.
+const (
+	AuditAdminLogin       = "admin.login"
+	AuditAdminLoginFailed = "admin.login_failed"
+	AuditAdminLockedOut   = "admin.locked_out"
+	AuditMoviePublished   = "movie.published"
+	AuditMovieUnpublished = "movie.unpublished"
+	AuditMovieArchived    = "movie.archived"
+	AuditMovieMerged      = "movie.merged"
+	AuditMoviesDeleted    = "movies.deleted"
+)
+
+// AuditEvent — запись журнала аудита о событии, связанном с безопасностью, или о
+// значимом изменении каталога (например, автоматической публикации фильма).
+type AuditEvent struct {
+	ID        int64             `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	Action    string            `json:"action"`
+	Actor     string            `json:"actor,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// AuditEventFilter содержит необязательные условия отбора записей журнала аудита.
+// Пустые строки и нулевое время означают отсутствие условия.
+type AuditEventFilter struct {
+	Action string
+	Actor  string
+	IP     string
+	Since  time.Time
+	Until  time.Time
+}
+
+// AuditEventStore описывает методы, которые должна поддерживать модель журнала аудита.
+type AuditEventStore interface {
+	Insert(ctx context.Context, event *AuditEvent) error
+	GetAll(ctx context.Context, filter AuditEventFilter, filters Filters) ([]*AuditEvent, Metadata, error)
+	DeleteBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error)
+}
+
+type AuditEventModel struct {
+	DB           Querier
+	QueryTimeout time.Duration
+}
+
+func (m AuditEventModel) Insert(ctx context.Context, event *AuditEvent) error {
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return err
+	}
+	if event.Details == nil {
+		details = []byte("{}")
+	}
+
+	query := `
+    INSERT INTO audit_events (action, actor, ip, user_agent, details)
+    VALUES ($1, $2, $3, $4, $5)
+    RETURNING id, created_at`
+	args := []any{event.Action, event.Actor, event.IP, event.UserAgent, details}
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&event.ID, &event.CreatedAt)
+}
+
+func (m AuditEventModel) GetAll(ctx context.Context, filter AuditEventFilter, filters Filters) ([]*AuditEvent, Metadata, error) {
+	// Нулевое время передается как NULL, чтобы условие по нему не применялось.
+	var since, until *time.Time
+	if !filter.Since.IsZero() {
+		since = &filter.Since
+	}
+	if !filter.Until.IsZero() {
+		until = &filter.Until
+	}
+
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), id, created_at, action, actor, ip, user_agent, details
+        FROM audit_events
+        WHERE (action = $1 OR $1 = '')
+        AND (actor = $2 OR $2 = '')
+        AND (ip = $3 OR $3 = '')
+        AND (created_at >= $4 OR $4 IS NULL)
+        AND (created_at < $5 OR $5 IS NULL)
+        ORDER BY %s %s, id DESC
+        LIMIT $6 OFFSET $7`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	args := []any{filter.Action, filter.Actor, filter.IP, since, until, filters.limit(), filters.offset()}
+	rows, err := m.DB.ReadQueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	events := []*AuditEvent{}
+
+	for rows.Next() {
+		var event AuditEvent
+		var details []byte
+		err := rows.Scan(
+			&totalRecords,
+			&event.ID,
+			&event.CreatedAt,
+			&event.Action,
+			&event.Actor,
+			&event.IP,
+			&event.UserAgent,
+			&details,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		if err := json.Unmarshal(details, &event.Details); err != nil {
+			return nil, Metadata{}, err
+		}
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return events, metadata, nil
+}
+
+// Метод DeleteBefore() удаляет записи, созданные раньше cutoff. Записи удаляются
+// порциями по batchSize в отдельных запросах, чтобы не блокировать таблицу надолго.
+// Возвращается общее количество удаленных записей.
+func (m AuditEventModel) DeleteBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	query := `
+    DELETE FROM audit_events
+    WHERE id IN (
+        SELECT id FROM audit_events
+        WHERE created_at < $1
+        LIMIT $2
+    )`
+
+	var total int64
+	for {
+		deleted, err := func() (int64, error) {
+			ctx, cancel := queryContext(ctx, m.QueryTimeout)
+			defer cancel()
+
+			result, err := m.DB.ExecContext(ctx, query, cutoff, batchSize)
+			if err != nil {
+				return 0, err
+			}
+			return result.RowsAffected()
+		}()
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+		if deleted < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+type MockAuditEventModel struct{}
+
+func (m MockAuditEventModel) Insert(ctx context.Context, event *AuditEvent) error {
+	return nil
+}
+
+func (m MockAuditEventModel) GetAll(ctx context.Context, filter AuditEventFilter, filters Filters) ([]*AuditEvent, Metadata, error) {
+	return nil, Metadata{}, nil
+}
+
+func (m MockAuditEventModel) DeleteBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	return 0, nil
+}
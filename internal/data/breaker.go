@@ -0,0 +1,263 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ошибка, возвращаемая моделями без обращения к базе данных, пока автоматический
+// выключатель разомкнут.
+var ErrCircuitOpen = errors.New("database circuit breaker is open")
+
+// Состояния автоматического выключателя.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// Метрики автоматического выключателя, публикуемые через expvar.
+var (
+	circuitBreakerState = expvar.NewString("db_circuit_breaker_state")
+	circuitBreakerTrips = expvar.NewInt("db_circuit_breaker_trips_total")
+)
+
+// circuitBreaker — автоматический выключатель для запросов к базе данных. После
+// threshold ошибок подряд он размыкается, и в течение cooldown запросы сразу
+// завершаются ошибкой ErrCircuitOpen, не дожидаясь тайм-аута. По истечении cooldown
+// выключатель пропускает один пробный запрос: если тот успешен, выключатель
+// замыкается, иначе снова размыкается на cooldown.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	circuitBreakerState.Set(CircuitClosed)
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     CircuitClosed,
+	}
+}
+
+// Метод allow() сообщает, можно ли выполнить запрос. Если нельзя, он возвращает
+// ErrCircuitOpen.
+func (cb *circuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return ErrCircuitOpen
+		}
+		cb.setState(CircuitHalfOpen)
+		cb.probing = true
+		return nil
+	case CircuitHalfOpen:
+		// Пока выполняется пробный запрос, остальные запросы отклоняются.
+		if cb.probing {
+			return ErrCircuitOpen
+		}
+		cb.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Метод record() учитывает результат выполненного запроса.
+func (cb *circuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+
+	if !isCircuitFailure(err) {
+		cb.failures = 0
+		cb.setState(CircuitClosed)
+		return
+	}
+
+	cb.failures++
+	if cb.state == CircuitHalfOpen || cb.failures >= cb.threshold {
+		if cb.state != CircuitOpen {
+			circuitBreakerTrips.Add(1)
+		}
+		cb.openedAt = time.Now()
+		cb.setState(CircuitOpen)
+	}
+}
+
+// Метод status() возвращает текущее состояние выключателя и время, через которое
+// имеет смысл повторить запрос, если выключатель разомкнут.
+func (cb *circuitBreaker) status() (string, time.Duration) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != CircuitOpen {
+		return cb.state, 0
+	}
+	return cb.state, max(cb.cooldown-time.Since(cb.openedAt), 0)
+}
+
+func (cb *circuitBreaker) setState(state string) {
+	if cb.state != state {
+		cb.state = state
+		circuitBreakerState.Set(state)
+	}
+}
+
+// Функция isCircuitFailure() сообщает, указывает ли ошибка на проблемы с самой базой
+// данных. Ожидаемые ошибки моделей (запись не найдена, конфликт версий, нарушение
+// ограничения) и отмена запроса клиентом означают, что база работает нормально.
+func isCircuitFailure(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrRecordNotFound),
+		errors.Is(err, ErrEditConflict),
+		errors.Is(err, ErrInvalidStatusTransition),
+		errors.Is(err, ErrCheckViolation),
+		errors.Is(err, ErrDuplicateIMDbID),
+		errors.Is(err, ErrDuplicateTMDBID),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+
+	// Из ошибок PostgreSQL учитываются только классы 08 (ошибки соединения),
+	// 53 (нехватка ресурсов) и 57 (вмешательство оператора, например, остановка
+	// сервера). Остальные ошибки означают, что сервер отвечает.
+	if code := pgErrorCode(err); code != "" {
+		return strings.HasPrefix(code, "08") || strings.HasPrefix(code, "53") || strings.HasPrefix(code, "57")
+	}
+
+	return true
+}
+
+// breakerMovieModel оборачивает MovieStore и пропускает каждый вызов через
+// автоматический выключатель.
+type breakerMovieModel struct {
+	MovieStore
+	breaker *circuitBreaker
+}
+
+func (m breakerMovieModel) Insert(ctx context.Context, movie *Movie) error {
+	if err := m.breaker.allow(); err != nil {
+		return err
+	}
+	err := m.MovieStore.Insert(ctx, movie)
+	m.breaker.record(err)
+	return err
+}
+
+func (m breakerMovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
+	if err := m.breaker.allow(); err != nil {
+		return nil, err
+	}
+	movie, err := m.MovieStore.Get(ctx, id)
+	m.breaker.record(err)
+	return movie, err
+}
+
+func (m breakerMovieModel) Update(ctx context.Context, movie *Movie) error {
+	if err := m.breaker.allow(); err != nil {
+		return err
+	}
+	err := m.MovieStore.Update(ctx, movie)
+	m.breaker.record(err)
+	return err
+}
+
+func (m breakerMovieModel) SetPoster(ctx context.Context, movie *Movie) error {
+	if err := m.breaker.allow(); err != nil {
+		return err
+	}
+	err := m.MovieStore.SetPoster(ctx, movie)
+	m.breaker.record(err)
+	return err
+}
+
+func (m breakerMovieModel) SetStatus(ctx context.Context, movie *Movie, status string) error {
+	if err := m.breaker.allow(); err != nil {
+		return err
+	}
+	err := m.MovieStore.SetStatus(ctx, movie, status)
+	m.breaker.record(err)
+	return err
+}
+
+func (m breakerMovieModel) PublishDue(ctx context.Context, now time.Time) ([]*Movie, error) {
+	if err := m.breaker.allow(); err != nil {
+		return nil, err
+	}
+	movies, err := m.MovieStore.PublishDue(ctx, now)
+	m.breaker.record(err)
+	return movies, err
+}
+
+func (m breakerMovieModel) GetSimilar(ctx context.Context, movie *Movie, status string, limit int) ([]*SimilarMovie, error) {
+	if err := m.breaker.allow(); err != nil {
+		return nil, err
+	}
+	similar, err := m.MovieStore.GetSimilar(ctx, movie, status, limit)
+	m.breaker.record(err)
+	return similar, err
+}
+
+func (m breakerMovieModel) FindDuplicates(ctx context.Context, movie *Movie) ([]*Movie, error) {
+	if err := m.breaker.allow(); err != nil {
+		return nil, err
+	}
+	duplicates, err := m.MovieStore.FindDuplicates(ctx, movie)
+	m.breaker.record(err)
+	return duplicates, err
+}
+
+// Ошибки, которые вернула сама fn, не относятся к базе данных и не учитываются
+// выключателем.
+func (m breakerMovieModel) Iterate(ctx context.Context, filter MovieFilter, filters Filters, fn func(*Movie) error) error {
+	if err := m.breaker.allow(); err != nil {
+		return err
+	}
+	var fnErr error
+	err := m.MovieStore.Iterate(ctx, filter, filters, func(movie *Movie) error {
+		fnErr = fn(movie)
+		return fnErr
+	})
+	if err != nil && err == fnErr {
+		m.breaker.record(nil)
+	} else {
+		m.breaker.record(err)
+	}
+	return err
+}
+
+func (m breakerMovieModel) Delete(ctx context.Context, id int64) error {
+	if err := m.breaker.allow(); err != nil {
+		return err
+	}
+	err := m.MovieStore.Delete(ctx, id)
+	m.breaker.record(err)
+	return err
+}
+
+func (m breakerMovieModel) GetAll(ctx context.Context, filter MovieFilter, filters Filters) ([]*Movie, Metadata, error) {
+	if err := m.breaker.allow(); err != nil {
+		return nil, Metadata{}, err
+	}
+	movies, metadata, err := m.MovieStore.GetAll(ctx, filter, filters)
+	m.breaker.record(err)
+	return movies, metadata, err
+}
@@ -0,0 +1,207 @@
+package data
+
+import (
+	"container/list"
+	"context"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// Метрики кеша фильмов, публикуемые через expvar.
+var (
+	movieCacheHits   = expvar.NewInt("movie_cache_hits_total")
+	movieCacheMisses = expvar.NewInt("movie_cache_misses_total")
+)
+
+// movieCache — это потокобезопасный LRU-кеш фильмов с ограничением по количеству
+// записей и времени жизни каждой записи.
+type movieCache struct {
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	order *list.List
+	items map[int64]*list.Element
+}
+
+type movieCacheEntry struct {
+	movie     Movie
+	expiresAt time.Time
+}
+
+func newMovieCache(size int, ttl time.Duration) *movieCache {
+	return &movieCache{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[int64]*list.Element),
+	}
+}
+
+// Метод get() возвращает копию фильма из кеша. Устаревшие записи удаляются.
+func (c *movieCache) get(id int64) (*Movie, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[id]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*movieCacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expiresAt) {
+		c.removeElement(element)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return copyMovie(&entry.movie), true
+}
+
+// Метод set() сохраняет копию фильма в кеше, вытесняя наиболее давно
+// использованную запись, если кеш заполнен.
+func (c *movieCache) set(movie *Movie) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &movieCacheEntry{
+		movie:     *copyMovie(movie),
+		expiresAt: time.Now().Add(c.ttl),
+	}
+
+	if element, ok := c.items[movie.ID]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[movie.ID] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// Метод delete() удаляет фильм из кеша.
+func (c *movieCache) delete(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[id]; ok {
+		c.removeElement(element)
+	}
+}
+
+func (c *movieCache) removeElement(element *list.Element) {
+	entry := element.Value.(*movieCacheEntry)
+	delete(c.items, entry.movie.ID)
+	c.order.Remove(element)
+}
+
+// Функция copyMovie() возвращает глубокую копию фильма. Обработчики изменяют
+// полученные из модели фильмы (например, при обновлении), поэтому кеш никогда
+// не отдает и не хранит указатели, доступные вызывающему коду.
+func copyMovie(movie *Movie) *Movie {
+	movieCopy := *movie
+	if movie.Genres != nil {
+		movieCopy.Genres = append([]string(nil), movie.Genres...)
+	}
+	if movie.PublishAt != nil {
+		publishAt := *movie.PublishAt
+		movieCopy.PublishAt = &publishAt
+	}
+	return &movieCopy
+}
+
+// cachedMovieModel оборачивает MovieStore и добавляет кеширование для метода Get().
+// Остальные методы делегируются обернутой модели, а изменяющие методы также
+// удаляют соответствующую запись из кеша. Запись удаляется после изменения: если
+// удалить ее раньше, параллельный Get() успел бы снова закешировать старую версию.
+type cachedMovieModel struct {
+	MovieStore
+	cache *movieCache
+}
+
+func (m cachedMovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
+	// Идентификаторы фильмов общие для всех арендаторов, поэтому фильм из кеша
+	// отдается только своему арендатору.
+	if movie, ok := m.cache.get(id); ok && movie.TenantID == TenantFromContext(ctx) {
+		movieCacheHits.Add(1)
+		return movie, nil
+	}
+	movieCacheMisses.Add(1)
+
+	movie, err := m.MovieStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	m.cache.set(movie)
+	return movie, nil
+}
+
+func (m cachedMovieModel) Update(ctx context.Context, movie *Movie) error {
+	defer m.cache.delete(movie.ID)
+	return m.MovieStore.Update(ctx, movie)
+}
+
+func (m cachedMovieModel) SetPoster(ctx context.Context, movie *Movie) error {
+	defer m.cache.delete(movie.ID)
+	return m.MovieStore.SetPoster(ctx, movie)
+}
+
+func (m cachedMovieModel) SetStatus(ctx context.Context, movie *Movie, status string) error {
+	defer m.cache.delete(movie.ID)
+	return m.MovieStore.SetStatus(ctx, movie, status)
+}
+
+func (m cachedMovieModel) PublishDue(ctx context.Context, now time.Time) ([]*Movie, error) {
+	movies, err := m.MovieStore.PublishDue(ctx, now)
+	for _, movie := range movies {
+		m.cache.delete(movie.ID)
+	}
+	return movies, err
+}
+
+func (m cachedMovieModel) Delete(ctx context.Context, id int64) error {
+	defer m.cache.delete(id)
+	return m.MovieStore.Delete(ctx, id)
+}
+
+// txMovieModel используется вместо cachedMovieModel внутри транзакции. Кеш в ней
+// не читается и не заполняется: транзакция может быть откачена. Идентификаторы
+// измененных фильмов запоминаются в транзакции, и записи удаляются из кеша после
+// ее фиксации (см. Models.WithTx()).
+type txMovieModel struct {
+	MovieStore
+	tx *Tx
+}
+
+func (m txMovieModel) Update(ctx context.Context, movie *Movie) error {
+	m.tx.evict(movie.ID)
+	return m.MovieStore.Update(ctx, movie)
+}
+
+func (m txMovieModel) SetPoster(ctx context.Context, movie *Movie) error {
+	m.tx.evict(movie.ID)
+	return m.MovieStore.SetPoster(ctx, movie)
+}
+
+func (m txMovieModel) SetStatus(ctx context.Context, movie *Movie, status string) error {
+	m.tx.evict(movie.ID)
+	return m.MovieStore.SetStatus(ctx, movie, status)
+}
+
+func (m txMovieModel) PublishDue(ctx context.Context, now time.Time) ([]*Movie, error) {
+	movies, err := m.MovieStore.PublishDue(ctx, now)
+	for _, movie := range movies {
+		m.tx.evict(movie.ID)
+	}
+	return movies, err
+}
+
+func (m txMovieModel) Delete(ctx context.Context, id int64) error {
+	m.tx.evict(id)
+	return m.MovieStore.Delete(ctx, id)
+}
@@ -0,0 +1,89 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/jsonlog"
+)
+
+// racingMovieStore вызывает hook перед каждым изменением, имитируя параллельный
+// запрос, который читает фильм, пока изменение еще не записано.
+type racingMovieStore struct {
+	MovieStore
+	hook func()
+}
+
+func (m racingMovieStore) Update(ctx context.Context, movie *Movie) error {
+	m.hook()
+	return m.MovieStore.Update(ctx, movie)
+}
+
+func TestMovieCacheEvictsAfterWrite(t *testing.T) {
+	ctx := context.Background()
+	store := &racingMovieStore{MovieStore: NewMemoryMovieModel()}
+	cached := cachedMovieModel{MovieStore: store, cache: newMovieCache(10, time.Minute)}
+	store.hook = func() { cached.Get(ctx, 1) }
+
+	movie := &Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: MoviePublished}
+	assert.NilError(t, cached.Insert(ctx, movie))
+
+	movie.Year = 2017
+	assert.NilError(t, cached.Update(ctx, movie))
+
+	got, err := cached.Get(ctx, movie.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, got.Year, int32(2017))
+}
+
+func TestMovieCacheTransactions(t *testing.T) {
+	models := NewSQLiteModels(NewDB(openTestSQLite(t), jsonlog.New(io.Discard, jsonlog.LevelOff), 0), 0).WithMovieCache(10, time.Minute)
+	ctx := context.Background()
+
+	movie := &Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: MoviePublished}
+	assert.NilError(t, models.Movies.Insert(ctx, movie))
+	_, err := models.Movies.Get(ctx, movie.ID)
+	assert.NilError(t, err)
+
+	// Изменения откаченной транзакции не попадают в кеш.
+	errRollback := errors.New("rollback")
+	err = models.WithTx(ctx, func(models Models) error {
+		movie, err := models.Movies.Get(ctx, movie.ID)
+		if err != nil {
+			return err
+		}
+		movie.Year = 1999
+		if err := models.Movies.Update(ctx, movie); err != nil {
+			return err
+		}
+		_, err = models.Movies.Get(ctx, movie.ID)
+		if err != nil {
+			return err
+		}
+		return errRollback
+	})
+	assert.Equal(t, errors.Is(err, errRollback), true)
+
+	got, err := models.Movies.Get(ctx, movie.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, got.Year, int32(2016))
+
+	// После фиксации измененный фильм удаляется из кеша.
+	err = models.WithTx(ctx, func(models Models) error {
+		movie, err := models.Movies.Get(ctx, movie.ID)
+		if err != nil {
+			return err
+		}
+		movie.Year = 2017
+		return models.Movies.Update(ctx, movie)
+	})
+	assert.NilError(t, err)
+
+	got, err = models.Movies.Get(ctx, movie.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, got.Year, int32(2017))
+}
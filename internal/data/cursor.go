@@ -0,0 +1,158 @@
+package data
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Клиентам, которым нужно пропустить больше записей, предлагается постраничный
+// обход по курсору: большой OFFSET заставляет базу данных читать и отбрасывать
+// все пропущенные строки.
+const MaxOffset = 10_000
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor указывает на последний фильм предыдущей страницы при постраничном обходе
+// по ключу (keyset pagination): следующая страница начинается сразу после него
+// в порядке сортировки Sort. Клиенты получают курсор в next_cursor метаданных
+// и передают его обратно без изменений.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// Столбцы, по которым возможен обход по курсору. Столбец publish_at не
+// поддерживается: он может быть NULL.
+var keysetColumns = []string{"id", "title", "year", "runtime"}
+
+// Функция DecodeCursor() разбирает курсор из параметра запроса.
+func DecodeCursor(s string) (*Cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	column := strings.TrimPrefix(c.Sort, "-")
+	switch column {
+	case "title":
+	case "id", "year", "runtime":
+		if _, err := strconv.ParseInt(c.Value, 10, 64); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	default:
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// Метод Encode() возвращает курсор в виде строки для параметра запроса.
+func (c Cursor) Encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// Функция SupportsCursor() сообщает, возможен ли обход по курсору при сортировке sort.
+func SupportsCursor(sort string) bool {
+	for _, column := range keysetColumns {
+		if strings.TrimPrefix(sort, "-") == column {
+			return true
+		}
+	}
+	return false
+}
+
+// Функция NextCursor() возвращает курсор следующей страницы или пустую строку,
+// если страница неполная (то есть последняя) или сортировка не поддерживает курсор.
+func NextCursor(filters Filters, movies []*Movie) string {
+	if len(movies) == 0 || len(movies) < filters.limit() || !SupportsCursor(filters.Sort) {
+		return ""
+	}
+
+	last := movies[len(movies)-1]
+	c := Cursor{Sort: filters.Sort, ID: last.ID}
+	switch filters.sortColumn() {
+	case "id":
+		c.Value = strconv.FormatInt(last.ID, 10)
+	case "title":
+		c.Value = last.Title
+	case "year":
+		c.Value = strconv.FormatInt(int64(last.Year), 10)
+	case "runtime":
+		c.Value = strconv.FormatInt(int64(last.Runtime), 10)
+	}
+	return c.Encode()
+}
+
+// Функция cursorMetadata() возвращает метаданные страницы, выбранной по курсору:
+// номера страниц и общее количество записей в этом режиме неизвестны.
+func cursorMetadata(movies []*Movie, filters Filters) Metadata {
+	if len(movies) == 0 {
+		return Metadata{}
+	}
+	return Metadata{PageSize: filters.PageSize}
+}
+
+// Метод keyset() возвращает условие SQL, отбирающее фильмы после курсора, и его
+// параметры. Номера параметров начинаются с n. Фильмы упорядочены по столбцу
+// сортировки в направлении sortDirection() и затем по id по возрастанию.
+func (f Filters) keyset(n int) (string, []any) {
+	if f.Cursor == nil {
+		return "TRUE", nil
+	}
+
+	column := f.sortColumn()
+	op := ">"
+	if f.sortDirection() == "DESC" {
+		op = "<"
+	}
+
+	var value any = f.Cursor.Value
+	if column != "title" {
+		value, _ = strconv.ParseInt(f.Cursor.Value, 10, 64)
+	}
+
+	condition := fmt.Sprintf("(%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND id > $%[4]d))", column, op, n, n+1)
+	return condition, []any{value, f.Cursor.ID}
+}
+
+// Метод after() сообщает, находится ли фильм после курсора (аналог keyset() для
+// моделей, которые отбирают фильмы в приложении).
+func (f Filters) after(movie *Movie) bool {
+	if f.Cursor == nil {
+		return true
+	}
+
+	var c int
+	switch f.sortColumn() {
+	case "title":
+		c = strings.Compare(movie.Title, f.Cursor.Value)
+	default:
+		value, _ := strconv.ParseInt(f.Cursor.Value, 10, 64)
+		var v int64
+		switch f.sortColumn() {
+		case "id":
+			v = movie.ID
+		case "year":
+			v = int64(movie.Year)
+		case "runtime":
+			v = int64(movie.Runtime)
+		}
+		c = cmp.Compare(v, value)
+	}
+	if f.sortDirection() == "DESC" {
+		c = -c
+	}
+	return c > 0 || (c == 0 && movie.ID > f.Cursor.ID)
+}
@@ -0,0 +1,83 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+)
+
+func TestGetAllCursor(t *testing.T) {
+	for name, store := range newIterateTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			insertIterateTestMovies(t, store)
+			ctx := context.Background()
+
+			// Обход по курсору должен вернуть те же фильмы в том же порядке, что и
+			// обычная пагинация, включая фильмы с одинаковым годом.
+			for _, sort := range []string{"id", "-year", "title", "-runtime"} {
+				filters := Filters{Page: 1, PageSize: 2, Sort: sort, SortSafelist: []string{sort}}
+				all, _, err := store.GetAll(ctx, MovieFilter{}, Filters{Page: 1, PageSize: 10, Sort: sort, SortSafelist: []string{sort}})
+				assert.NilError(t, err)
+
+				var titles []string
+				for {
+					movies, metadata, err := store.GetAll(ctx, MovieFilter{}, filters)
+					assert.NilError(t, err)
+					for _, movie := range movies {
+						titles = append(titles, movie.Title)
+					}
+					// Общее количество считается только для первой страницы.
+					if filters.Cursor != nil {
+						assert.Equal(t, metadata.TotalRecords, 0)
+					}
+
+					next := NextCursor(filters, movies)
+					if next == "" {
+						break
+					}
+					filters.Cursor, err = DecodeCursor(next)
+					assert.NilError(t, err)
+				}
+
+				assert.Equal(t, len(titles), len(all))
+				for i, movie := range all {
+					assert.Equal(t, titles[i], movie.Title)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	c := Cursor{Sort: "-year", Value: "2016", ID: 3}
+	got, err := DecodeCursor(c.Encode())
+	assert.NilError(t, err)
+	assert.Equal(t, *got, c)
+
+	for _, s := range []string{
+		"not base64!",
+		Cursor{Sort: "-year", Value: "abc", ID: 3}.Encode(),
+		Cursor{Sort: "publish_at", Value: "2016", ID: 3}.Encode(),
+		Cursor{Sort: "id", Value: "1", ID: 0}.Encode(),
+	} {
+		_, err := DecodeCursor(s)
+		assert.Equal(t, err, ErrInvalidCursor)
+	}
+}
+
+func TestGetAllCountOnly(t *testing.T) {
+	for name, store := range newIterateTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			insertIterateTestMovies(t, store)
+
+			filters := Filters{Page: 2, PageSize: 1, Sort: "id", SortSafelist: []string{"id"}, CountOnly: true}
+			movies, metadata, err := store.GetAll(context.Background(), MovieFilter{Genres: []string{"action"}}, filters)
+			assert.NilError(t, err)
+			assert.Equal(t, len(movies), 0)
+			assert.Equal(t, metadata.TotalRecords, 2)
+			assert.Equal(t, metadata.CurrentPage, 2)
+			assert.Equal(t, metadata.LastPage, 2)
+		})
+	}
+}
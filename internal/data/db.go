@@ -0,0 +1,207 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/jsonlog"
+)
+
+// Метрики запросов к базе данных, публикуемые через expvar. Ключом во всех картах
+// является отпечаток (fingerprint) запроса.
+var (
+	queriesTotal       = expvar.NewMap("db_queries_total")
+	queryDurationTotal = expvar.NewMap("db_query_duration_μs_total")
+	slowQueriesTotal   = expvar.NewMap("db_slow_queries_total")
+)
+
+// Регулярные выражения, используемые при построении отпечатка запроса. Числовой
+// литерал не должен быть частью идентификатора или плейсхолдера вида $1.
+var (
+	whitespaceRX     = regexp.MustCompile(`\s+`)
+	numericLiteralRX = regexp.MustCompile(`(^|[^$\w.])\d+(?:\.\d+)?\b`)
+	stringLiteralRX  = regexp.MustCompile(`'(?:[^']|'')*'`)
+)
+
+// Время, в течение которого реплика считается недоступной после ошибки. Все это
+// время запросы на чтение выполняются на основной базе данных.
+const replicaRetryInterval = 30 * time.Second
+
+// DB оборачивает пул соединений *sql.DB и измеряет время выполнения каждого запроса.
+// Все модели выполняют запросы через DB, поэтому медленные запросы логируются
+// и учитываются в метриках в одном месте.
+//
+// Если задана реплика для чтения (см. SetReplica()), методы ReadQueryRowContext()
+// и ReadQueryContext() выполняют запросы на ней, а при ее недоступности
+// автоматически переключаются на основную базу данных. Отдельный фильм
+// (MovieModel.Get()) всегда читается с основной базы.
+//
+// Запросы выполняются через подготовленные выражения из кеша (отдельного для
+// основной базы и для реплики), размер которого задается SetStatementCacheSize().
+type DB struct {
+	*sql.DB
+	replica       *sql.DB
+	logger        *jsonlog.Logger
+	slowThreshold time.Duration
+	stmts         *stmtCache
+	replicaStmts  *stmtCache
+	// Время (в наносекундах Unix), до которого реплика считается недоступной.
+	replicaDownUntil atomic.Int64
+}
+
+// NewDB возвращает новый экземпляр DB. Запросы, выполняющиеся дольше slowThreshold,
+// записываются в лог с уровнем WARN. Нулевое значение slowThreshold отключает
+// логирование медленных запросов (метрики при этом продолжают собираться).
+func NewDB(db *sql.DB, logger *jsonlog.Logger, slowThreshold time.Duration) *DB {
+	return &DB{
+		DB:            db,
+		logger:        logger,
+		slowThreshold: slowThreshold,
+	}
+}
+
+// Метод SetReplica() задает пул соединений с репликой для запросов на чтение.
+func (db *DB) SetReplica(replica *sql.DB) {
+	db.replica = replica
+}
+
+// Метод PoolStats() возвращает текущую статистику пулов соединений: основного
+// ("primary") и, если она настроена, реплики для чтения ("replica").
+func (db *DB) PoolStats() map[string]sql.DBStats {
+	stats := map[string]sql.DBStats{"primary": db.DB.Stats()}
+	if db.replica != nil {
+		stats["replica"] = db.replica.Stats()
+	}
+	return stats
+}
+
+// Метод SetStatementCacheSize() включает кеш подготовленных выражений, который
+// хранит не более size выражений для каждого пула. Нулевое значение отключает кеш.
+// Метод должен вызываться до начала выполнения запросов.
+func (db *DB) SetStatementCacheSize(size int) {
+	db.stmts = newStmtCache(db.DB, size)
+	if db.replica != nil {
+		db.replicaStmts = newStmtCache(db.replica, size)
+	}
+}
+
+// Метод readPool() возвращает пул реплики, если она задана и не помечена как
+// недоступная, иначе nil.
+func (db *DB) readPool() *sql.DB {
+	if db.replica == nil {
+		return nil
+	}
+	if time.Now().UnixNano() < db.replicaDownUntil.Load() {
+		return nil
+	}
+	return db.replica
+}
+
+// Метод markReplicaDown() помечает реплику недоступной на replicaRetryInterval.
+// В лог записывается только переход в недоступное состояние, а не каждая ошибка.
+func (db *DB) markReplicaDown(err error) {
+	until := time.Now().Add(replicaRetryInterval).UnixNano()
+	previous := db.replicaDownUntil.Swap(until)
+
+	if previous < time.Now().UnixNano() && db.logger != nil {
+		db.logger.PrintWarn("read replica query failed, falling back to the primary", map[string]string{
+			"error":       err.Error(),
+			"retry_after": replicaRetryInterval.String(),
+		})
+	}
+}
+
+// Метод ReadQueryRowContext() выполняет запрос на чтение на реплике. Если запрос
+// завершился ошибкой, не связанной с отменой контекста, реплика помечается как
+// недоступная, и запрос повторяется на основной базе данных.
+func (db *DB) ReadQueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if replica := db.readPool(); replica != nil {
+		start := time.Now()
+		row := db.replicaStmts.queryRow(ctx, replica, query, args...)
+		db.observe(query, len(args), time.Since(start))
+
+		// Метод Err() возвращает ошибку выполнения запроса (но не sql.ErrNoRows,
+		// которая появляется только при вызове Scan()).
+		err := row.Err()
+		if err == nil || ctx.Err() != nil {
+			return row
+		}
+		db.markReplicaDown(err)
+	}
+	return db.QueryRowContext(ctx, query, args...)
+}
+
+// Метод ReadQueryContext() аналогичен ReadQueryRowContext(), но возвращает *sql.Rows.
+func (db *DB) ReadQueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if replica := db.readPool(); replica != nil {
+		start := time.Now()
+		rows, err := db.replicaStmts.query(ctx, replica, query, args...)
+		db.observe(query, len(args), time.Since(start))
+
+		if err == nil || ctx.Err() != nil {
+			return rows, err
+		}
+		db.markReplicaDown(err)
+	}
+	return db.QueryContext(ctx, query, args...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := db.stmts.queryRow(ctx, db.DB, query, args...)
+	db.observe(query, len(args), time.Since(start))
+	return row
+}
+
+// Обратите внимание, что для QueryContext() измеряется время до получения первых
+// строк результата, а не время их полного чтения вызывающим кодом.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := db.stmts.query(ctx, db.DB, query, args...)
+	db.observe(query, len(args), time.Since(start))
+	return rows, err
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := db.stmts.exec(ctx, db.DB, query, args...)
+	db.observe(query, len(args), time.Since(start))
+	return result, err
+}
+
+// Метод observe() обновляет метрики для запроса и, если запрос выполнялся дольше
+// порогового значения, записывает в лог предупреждение.
+func (db *DB) observe(query string, argsCount int, duration time.Duration) {
+	fingerprint := queryFingerprint(query)
+
+	queriesTotal.Add(fingerprint, 1)
+	queryDurationTotal.Add(fingerprint, duration.Microseconds())
+
+	if db.slowThreshold > 0 && duration >= db.slowThreshold {
+		slowQueriesTotal.Add(fingerprint, 1)
+
+		if db.logger != nil {
+			db.logger.PrintWarn("slow database query", map[string]string{
+				"query":      fingerprint,
+				"duration":   duration.String(),
+				"args_count": strconv.Itoa(argsCount),
+			})
+		}
+	}
+}
+
+// Функция queryFingerprint() нормализует текст запроса: схлопывает пробельные
+// символы и заменяет строковые и числовые литералы на "?", чтобы одинаковые по
+// структуре запросы давали одинаковый отпечаток.
+func queryFingerprint(query string) string {
+	fingerprint := stringLiteralRX.ReplaceAllString(query, "?")
+	fingerprint = numericLiteralRX.ReplaceAllString(fingerprint, "${1}?")
+	fingerprint = whitespaceRX.ReplaceAllString(fingerprint, " ")
+	return strings.TrimSpace(fingerprint)
+}
@@ -0,0 +1,50 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/jsonlog"
+)
+
+// Функция openTestSQLite() открывает пустую базу SQLite в памяти процесса со схемой.
+func openTestSQLite(t testing.TB) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:?_pragma=foreign_keys(1)&_time_format=sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	err = ApplySQLiteSchema(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Реплика в тесте — отдельная пустая база, то есть реплика с бесконечной задержкой.
+// Get() должен видеть только что записанный фильм, а списки читаются с реплики.
+func TestGetReadsPrimary(t *testing.T) {
+	db := NewDB(openTestSQLite(t), jsonlog.New(io.Discard, jsonlog.LevelOff), 0)
+	db.SetReplica(openTestSQLite(t))
+	models := NewSQLiteModels(db, 0)
+	ctx := context.Background()
+
+	movie := &Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: MoviePublished}
+	assert.NilError(t, models.Movies.Insert(ctx, movie))
+
+	got, err := models.Movies.Get(ctx, movie.ID)
+	assert.NilError(t, err)
+	got.Year = 2017
+	assert.NilError(t, models.Movies.Update(ctx, got))
+
+	movies, _, err := models.Movies.GetAll(ctx, MovieFilter{}, Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}})
+	assert.NilError(t, err)
+	assert.Equal(t, len(movies), 0)
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"fmt"
 	"greenlight.andreyklimov.net/internal/validator"
 	"math"
 	"strings"
@@ -11,6 +12,17 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	// Если EstimateCount равно true, вместо точного подсчета общего количества
+	// записей (дорогого на больших таблицах) используется оценка планировщика.
+	// Поддерживается не всеми моделями: остальные считают точно.
+	EstimateCount bool
+	// Если Cursor задан, страница начинается сразу после фильма, на который он
+	// указывает, а Page не используется (см. Cursor). Общее количество записей
+	// в этом режиме не считается.
+	Cursor *Cursor
+	// Если CountOnly равно true, GetAll() не выбирает записи, а возвращает только
+	// метаданные пагинации (с учетом EstimateCount). Cursor при этом не учитывается.
+	CountOnly bool
 }
 
 func (f Filters) limit() int {
@@ -40,15 +52,22 @@ func (f Filters) sortDirection() string {
 	return "ASC"
 }
 
+// Наибольшие допустимые значения параметров page и page_size.
+const (
+	MaxPage     = 10_000_000
+	MaxPageSize = 100
+)
+
 func ValidateFilters(v *validator.Validator, f Filters) {
 	// Проверяем, что параметры page и page_size содержат допустимые значения.
-	v.Check(f.Page > 0, "page", "must be greater than zero")
-	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
-	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
-	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
+	v.CheckRule(f.Page > 0, "page", "must be greater than zero", validator.RuleMin(1), f.Page)
+	v.CheckRule(f.Page <= MaxPage, "page", "must be a maximum of 10 million", validator.RuleMax(MaxPage), f.Page)
+	v.Check(f.Cursor != nil || f.offset() <= MaxOffset, "page", fmt.Sprintf("must not skip more than %d records", MaxOffset))
+	v.CheckRule(f.PageSize > 0, "page_size", "must be greater than zero", validator.RuleMin(1), f.PageSize)
+	v.CheckRule(f.PageSize <= MaxPageSize, "page_size", fmt.Sprintf("must be a maximum of %d", MaxPageSize), validator.RuleMax(MaxPageSize), f.PageSize)
 
 	// Проверяем, что параметр sort соответствует значению из safelist.
-	v.Check(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+	v.CheckRule(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid sort value", validator.RuleOneOf(f.SortSafelist...), f.Sort)
 }
 
 // Определяем новую структуру Metadata для хранения метаданных пагинации.
@@ -58,6 +77,11 @@ type Metadata struct {
 	FirstPage    int `json:"first_page,omitempty"`
 	LastPage     int `json:"last_page,omitempty"`
 	TotalRecords int `json:"total_records,omitempty"`
+	// TotalRecordsEstimated показывает, что TotalRecords (и LastPage) — приблизительная
+	// оценка, а не точный подсчет.
+	TotalRecordsEstimated bool `json:"total_records_estimated,omitempty"`
+	// NextCursor — курсор следующей страницы для постраничного обхода по курсору.
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // Функция calculateMetadata() вычисляет соответствующие метаданные пагинации
@@ -0,0 +1,383 @@
+//go:build integration
+
+// Интеграционные тесты слоя данных работают с настоящим PostgreSQL, который
+// запускается в Docker с помощью testcontainers. Для запуска нужен Docker:
+//
+//	go test -tags integration ./internal/data/...
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/jsonlog"
+)
+
+// Пул соединений с тестовой базой данных, общий для всех тестов пакета.
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	os.Exit(runIntegrationTests(m))
+}
+
+// Функция runIntegrationTests() запускает контейнер с PostgreSQL, применяет
+// миграции и выполняет тесты. Контейнер удаляется по завершении.
+func runIntegrationTests(m *testing.M) int {
+	ctx := context.Background()
+
+	container, err := postgres.Run(ctx, "postgres:16-alpine",
+		postgres.WithDatabase("greenlight"),
+		postgres.WithUsername("greenlight"),
+		postgres.WithPassword("pa55word"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(time.Minute),
+		),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer container.Terminate(ctx)
+
+	dsn, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		panic(err)
+	}
+
+	testDB, err = sql.Open("postgres", dsn)
+	if err != nil {
+		panic(err)
+	}
+	defer testDB.Close()
+
+	err = applyMigrations(ctx, testDB)
+	if err != nil {
+		panic(err)
+	}
+
+	return m.Run()
+}
+
+// Функция applyMigrations() по порядку применяет up-миграции из каталога migrations.
+func applyMigrations(ctx context.Context, db *sql.DB) error {
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.up.sql"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		_, err = db.ExecContext(ctx, string(migration))
+		if err != nil {
+			return errors.Join(errors.New(filepath.Base(file)), err)
+		}
+	}
+	return nil
+}
+
+// Функция newTestModels() очищает таблицы и возвращает модели, работающие
+// с тестовой базой данных.
+func newTestModels(t *testing.T) Models {
+	t.Helper()
+
+	_, err := testDB.Exec("TRUNCATE movies, audit_events RESTART IDENTITY CASCADE")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := NewDB(testDB, jsonlog.New(io.Discard, jsonlog.LevelOff), 0)
+	return NewModels(db, 5*time.Second)
+}
+
+func newTestMovie(title string, year int32) *Movie {
+	return &Movie{
+		Title:   title,
+		Year:    year,
+		Runtime: 100,
+		Genres:  []string{"drama"},
+		Status:  MoviePublished,
+	}
+}
+
+func TestMovieModelCRUD(t *testing.T) {
+	models := newTestModels(t)
+	ctx := context.Background()
+
+	movie := newTestMovie("Moana", 2016)
+	movie.IMDbID = "tt3521164"
+	err := models.Movies.Insert(ctx, movie)
+	assert.NilError(t, err)
+	assert.Equal(t, movie.ID, int64(1))
+	assert.Equal(t, movie.Version, int32(1))
+
+	got, err := models.Movies.Get(ctx, movie.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, got.Title, "Moana")
+	assert.Equal(t, got.IMDbID, "tt3521164")
+	assert.DeepEqual(t, got.Genres, []string{"drama"})
+
+	got.Title = "Moana 2"
+	err = models.Movies.Update(ctx, got)
+	assert.NilError(t, err)
+	assert.Equal(t, got.Version, int32(2))
+
+	err = models.Movies.Insert(ctx, &Movie{Title: "Copy", Year: 2016, Runtime: 1, Genres: []string{"drama"}, IMDbID: "tt3521164", Status: MoviePublished})
+	assert.Equal(t, err, ErrDuplicateIMDbID)
+
+	err = models.Movies.Delete(ctx, movie.ID)
+	assert.NilError(t, err)
+
+	_, err = models.Movies.Get(ctx, movie.ID)
+	assert.Equal(t, err, ErrRecordNotFound)
+
+	err = models.Movies.Delete(ctx, movie.ID)
+	assert.Equal(t, err, ErrRecordNotFound)
+}
+
+func TestMovieModelGetAll(t *testing.T) {
+	models := newTestModels(t)
+	ctx := context.Background()
+
+	for i, title := range []string{"Black Panther", "Deadpool", "The Breakfast Club", "The Dark Knight"} {
+		err := models.Movies.Insert(ctx, newTestMovie(title, int32(2000+i)))
+		assert.NilError(t, err)
+	}
+
+	filters := Filters{Page: 1, PageSize: 2, Sort: "-year", SortSafelist: []string{"-year", "title"}}
+	movies, metadata, err := models.Movies.GetAll(ctx, MovieFilter{}, filters)
+	assert.NilError(t, err)
+	assert.Equal(t, len(movies), 2)
+	assert.Equal(t, movies[0].Title, "The Dark Knight")
+	assert.Equal(t, metadata.TotalRecords, 4)
+	assert.Equal(t, metadata.LastPage, 2)
+
+	filters.Sort = "title"
+	movies, metadata, err = models.Movies.GetAll(ctx, MovieFilter{Title: "the"}, filters)
+	assert.NilError(t, err)
+	assert.Equal(t, metadata.TotalRecords, 2)
+	assert.Equal(t, movies[0].Title, "The Breakfast Club")
+}
+
+// Одновременные правки одной и той же версии фильма: успешной должна быть
+// ровно одна, остальные получают ErrEditConflict.
+func TestMovieModelConcurrentUpdate(t *testing.T) {
+	models := newTestModels(t)
+	ctx := context.Background()
+
+	movie := newTestMovie("Moana", 2016)
+	err := models.Movies.Insert(ctx, movie)
+	assert.NilError(t, err)
+
+	const writers = 10
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		updated   int
+		conflicts int
+	)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			m := *movie
+			m.Year = int32(2017 + i)
+			err := models.Movies.Update(ctx, &m)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				updated++
+			case errors.Is(err, ErrEditConflict):
+				conflicts++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, updated, 1)
+	assert.Equal(t, conflicts, writers-1)
+
+	got, err := models.Movies.Get(ctx, movie.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, got.Version, int32(2))
+}
+
+func TestMovieModelSetStatus(t *testing.T) {
+	models := newTestModels(t)
+	ctx := context.Background()
+
+	movie := newTestMovie("Moana", 2016)
+	movie.Status = MovieDraft
+	err := models.Movies.Insert(ctx, movie)
+	assert.NilError(t, err)
+
+	err = models.Movies.SetStatus(ctx, movie, MoviePublished)
+	assert.NilError(t, err)
+	assert.Equal(t, movie.Status, MoviePublished)
+
+	stale := *movie
+	stale.Version--
+	err = models.Movies.SetStatus(ctx, &stale, MovieArchived)
+	assert.Equal(t, err, ErrEditConflict)
+
+	err = models.Movies.SetStatus(ctx, movie, MovieArchived)
+	assert.NilError(t, err)
+
+	err = models.Movies.SetStatus(ctx, movie, MoviePublished)
+	assert.Equal(t, err, ErrInvalidStatusTransition)
+}
+
+func TestMovieModelPublishDue(t *testing.T) {
+	models := newTestModels(t)
+	ctx := context.Background()
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	due := newTestMovie("Moana", 2016)
+	due.Status = MovieDraft
+	due.PublishAt = &past
+	assert.NilError(t, models.Movies.Insert(ctx, due))
+
+	later := newTestMovie("Deadpool", 2016)
+	later.Status = MovieDraft
+	later.PublishAt = &future
+	assert.NilError(t, models.Movies.Insert(ctx, later))
+
+	published, err := models.Movies.PublishDue(ctx, now)
+	assert.NilError(t, err)
+	assert.Equal(t, len(published), 1)
+	assert.Equal(t, published[0].ID, due.ID)
+	assert.Equal(t, published[0].Version, due.Version+1)
+
+	movie, err := models.Movies.Get(ctx, due.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, movie.Status, MoviePublished)
+	assert.Equal(t, movie.PublishAt == nil, true)
+
+	movie, err = models.Movies.Get(ctx, later.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, movie.Status, MovieDraft)
+}
+
+func TestMovieModelTenantIsolation(t *testing.T) {
+	models := newTestModels(t)
+	acme := WithTenant(context.Background(), "acme")
+
+	movie := newTestMovie("Moana", 2016)
+	err := models.Movies.Insert(acme, movie)
+	assert.NilError(t, err)
+
+	_, err = models.Movies.Get(context.Background(), movie.ID)
+	assert.Equal(t, err, ErrRecordNotFound)
+
+	got, err := models.Movies.Get(acme, movie.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, got.TenantID, "acme")
+}
+
+func TestMovieModelEstimatedCount(t *testing.T) {
+	models := newTestModels(t)
+	ctx := context.Background()
+
+	for i := range 30 {
+		err := models.Movies.Insert(ctx, newTestMovie("Movie", int32(1990+i)))
+		assert.NilError(t, err)
+	}
+	_, err := testDB.Exec("ANALYZE movies")
+	assert.NilError(t, err)
+
+	filters := Filters{Page: 1, PageSize: 10, Sort: "id", SortSafelist: []string{"id"}, EstimateCount: true}
+	movies, metadata, err := models.Movies.GetAll(ctx, MovieFilter{}, filters)
+	assert.NilError(t, err)
+	assert.Equal(t, len(movies), 10)
+	assert.Equal(t, metadata.TotalRecordsEstimated, true)
+	assert.Equal(t, metadata.TotalRecords >= 10, true)
+
+	// Неполная страница — последняя, поэтому количество известно точно.
+	filters.Page = 3
+	filters.PageSize = 12
+	movies, metadata, err = models.Movies.GetAll(ctx, MovieFilter{}, filters)
+	assert.NilError(t, err)
+	assert.Equal(t, len(movies), 6)
+	assert.Equal(t, metadata.TotalRecords, 30)
+	assert.Equal(t, metadata.TotalRecordsEstimated, false)
+}
+
+func TestMovieModelCursor(t *testing.T) {
+	models := newTestModels(t)
+	ctx := context.Background()
+
+	for i, title := range []string{"Black Panther", "Deadpool", "Moana", "The Dark Knight", "Up"} {
+		err := models.Movies.Insert(ctx, newTestMovie(title, int32(2000+i%2)))
+		assert.NilError(t, err)
+	}
+
+	filters := Filters{Page: 1, PageSize: 2, Sort: "-year", SortSafelist: []string{"-year"}}
+	var ids []int64
+	for {
+		movies, _, err := models.Movies.GetAll(ctx, MovieFilter{}, filters)
+		assert.NilError(t, err)
+		for _, movie := range movies {
+			ids = append(ids, movie.ID)
+		}
+		next := NextCursor(filters, movies)
+		if next == "" {
+			break
+		}
+		filters.Cursor, err = DecodeCursor(next)
+		assert.NilError(t, err)
+	}
+	assert.DeepEqual(t, ids, []int64{2, 4, 1, 3, 5})
+}
+
+func TestMovieModelCountOnly(t *testing.T) {
+	models := newTestModels(t)
+	ctx := context.Background()
+
+	for i := range 5 {
+		err := models.Movies.Insert(ctx, newTestMovie("Movie", int32(2000+i)))
+		assert.NilError(t, err)
+	}
+
+	filters := Filters{Page: 1, PageSize: 2, Sort: "id", SortSafelist: []string{"id"}, CountOnly: true}
+	movies, metadata, err := models.Movies.GetAll(ctx, MovieFilter{YearMax: 2003}, filters)
+	assert.NilError(t, err)
+	assert.Equal(t, len(movies), 0)
+	assert.Equal(t, metadata.TotalRecords, 4)
+	assert.Equal(t, metadata.LastPage, 2)
+}
+
+func BenchmarkStatementCachePostgres(b *testing.B) {
+	benchmarkStatementCache(b, func(b *testing.B, size int) Models {
+		_, err := testDB.Exec("TRUNCATE movies, audit_events RESTART IDENTITY CASCADE")
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		db := NewDB(testDB, jsonlog.New(io.Discard, jsonlog.LevelOff), 0)
+		db.SetStatementCacheSize(size)
+		return NewModels(db, 5*time.Second)
+	})
+}
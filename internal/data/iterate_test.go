@@ -0,0 +1,145 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/jsonlog"
+)
+
+// Функция newIterateTestStores() возвращает модели фильмов в памяти и в SQLite
+// (в памяти процесса), чтобы одни и те же проверки выполнялись для обеих.
+func newIterateTestStores(t *testing.T) map[string]MovieStore {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:?_pragma=foreign_keys(1)&_time_format=sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// У каждого соединения с :memory: своя база данных.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	err = ApplySQLiteSchema(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]MovieStore{
+		"Memory": NewMemoryMovieModel(),
+		"SQLite": NewSQLiteModels(NewDB(db, jsonlog.New(io.Discard, jsonlog.LevelOff), 0), 0).Movies,
+	}
+}
+
+func insertIterateTestMovies(t *testing.T, store MovieStore) {
+	t.Helper()
+
+	movies := []*Movie{
+		{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: MoviePublished},
+		{Title: "Black Panther", Year: 2018, Runtime: 134, Genres: []string{"action"}, Status: MoviePublished},
+		{Title: "Deadpool", Year: 2016, Runtime: 108, Genres: []string{"action", "comedy"}, Status: MoviePublished},
+		{Title: "The Breakfast Club", Year: 1986, Runtime: 96, Genres: []string{"drama"}, Status: MovieDraft},
+	}
+	for _, movie := range movies {
+		err := store.Insert(context.Background(), movie)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var iterateByYear = Filters{Sort: "-year", SortSafelist: []string{"-year"}}
+
+func TestIterate(t *testing.T) {
+	for name, store := range newIterateTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			insertIterateTestMovies(t, store)
+
+			var titles []string
+			err := store.Iterate(context.Background(), MovieFilter{Status: MoviePublished}, iterateByYear, func(movie *Movie) error {
+				titles = append(titles, movie.Title)
+				return nil
+			})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, titles, []string{"Black Panther", "Moana", "Deadpool"})
+
+			// Фильмы другого арендатора не видны.
+			count := 0
+			err = store.Iterate(WithTenant(context.Background(), "acme"), MovieFilter{}, iterateByYear, func(*Movie) error {
+				count++
+				return nil
+			})
+			assert.NilError(t, err)
+			assert.Equal(t, count, 0)
+		})
+	}
+}
+
+func TestIterateStopsEarly(t *testing.T) {
+	for name, store := range newIterateTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			insertIterateTestMovies(t, store)
+
+			var titles []string
+			err := store.Iterate(context.Background(), MovieFilter{}, iterateByYear, func(movie *Movie) error {
+				titles = append(titles, movie.Title)
+				if len(titles) == 2 {
+					return ErrStopIteration
+				}
+				return nil
+			})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, titles, []string{"Black Panther", "Moana"})
+		})
+	}
+}
+
+func TestIteratePropagatesErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	for name, store := range newIterateTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			insertIterateTestMovies(t, store)
+
+			calls := 0
+			err := store.Iterate(context.Background(), MovieFilter{}, iterateByYear, func(*Movie) error {
+				calls++
+				return errBoom
+			})
+			assert.Equal(t, err, errBoom)
+			assert.Equal(t, calls, 1)
+
+			// Отмененный контекст прерывает обход.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err = store.Iterate(ctx, MovieFilter{}, iterateByYear, func(*Movie) error {
+				return nil
+			})
+			assert.Equal(t, errors.Is(err, context.Canceled), true)
+		})
+	}
+}
+
+func TestBreakerIgnoresIterateCallbackErrors(t *testing.T) {
+	breaker := newCircuitBreaker(1, time.Minute)
+	store := breakerMovieModel{MovieStore: NewMemoryMovieModel(), breaker: breaker}
+	insertIterateTestMovies(t, store)
+
+	errBoom := errors.New("boom")
+	for range 3 {
+		err := store.Iterate(context.Background(), MovieFilter{}, iterateByYear, func(*Movie) error {
+			return errBoom
+		})
+		assert.Equal(t, err, errBoom)
+	}
+
+	state, _ := breaker.status()
+	assert.Equal(t, state, CircuitClosed)
+}
@@ -0,0 +1,372 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryMovieModel — реализация MovieStore, которая хранит фильмы в памяти процесса.
+// Она используется в тестах и в демонстрационном режиме (-demo) и ведет себя так же,
+// как MovieModel: проверяет версии записей, уникальность внешних идентификаторов
+// в пределах арендатора, а также поддерживает фильтрацию, сортировку и пагинацию.
+type MemoryMovieModel struct {
+	mu     sync.Mutex
+	movies map[int64]*Movie
+	nextID int64
+}
+
+// Функция NewMemoryMovieModel() возвращает пустой MemoryMovieModel.
+func NewMemoryMovieModel() *MemoryMovieModel {
+	return &MemoryMovieModel{
+		movies: make(map[int64]*Movie),
+		nextID: 1,
+	}
+}
+
+// Метод checkUnique() проверяет, что IMDb ID и TMDB ID фильма не заняты другим
+// фильмом того же арендатора. Вызывается с захваченным мьютексом.
+func (m *MemoryMovieModel) checkUnique(movie *Movie, tenantID string) error {
+	for _, other := range m.movies {
+		if other.ID == movie.ID || other.TenantID != tenantID {
+			continue
+		}
+		if movie.IMDbID != "" && other.IMDbID == movie.IMDbID {
+			return ErrDuplicateIMDbID
+		}
+		if movie.TMDBID != 0 && other.TMDBID == movie.TMDBID {
+			return ErrDuplicateTMDBID
+		}
+	}
+	return nil
+}
+
+// Метод lookup() возвращает фильм арендатора из контекста или ErrRecordNotFound.
+// Вызывается с захваченным мьютексом.
+func (m *MemoryMovieModel) lookup(ctx context.Context, id int64) (*Movie, error) {
+	movie, ok := m.movies[id]
+	if !ok || movie.TenantID != TenantFromContext(ctx) {
+		return nil, ErrRecordNotFound
+	}
+	return movie, nil
+}
+
+func (m *MemoryMovieModel) Insert(ctx context.Context, movie *Movie) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tenantID := TenantFromContext(ctx)
+	if err := m.checkUnique(movie, tenantID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	movie.ID = m.nextID
+	movie.CreatedAt = now
+	movie.UpdatedAt = now
+	movie.Version = 1
+	movie.TenantID = tenantID
+	if movie.Genres == nil {
+		movie.Genres = []string{}
+	}
+	m.nextID++
+
+	m.movies[movie.ID] = copyMovie(movie)
+	return nil
+}
+
+func (m *MemoryMovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	movie, err := m.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return copyMovie(movie), nil
+}
+
+// Метод write() применяет изменение apply к сохраненной копии фильма, если версия
+// совпадает с movie.Version, увеличивает версию и копирует ее обратно в movie.
+// Как и в MovieModel, отсутствующий фильм и устаревшая версия дают ErrEditConflict.
+func (m *MemoryMovieModel) write(ctx context.Context, movie *Movie, apply func(stored *Movie) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, err := m.lookup(ctx, movie.ID)
+	if err != nil || stored.Version != movie.Version {
+		return ErrEditConflict
+	}
+
+	updated := copyMovie(stored)
+	if err := apply(updated); err != nil {
+		return err
+	}
+	updated.Version++
+	updated.UpdatedAt = time.Now()
+	m.movies[movie.ID] = updated
+
+	movie.Version = updated.Version
+	movie.UpdatedAt = updated.UpdatedAt
+	return nil
+}
+
+func (m *MemoryMovieModel) Update(ctx context.Context, movie *Movie) error {
+	return m.write(ctx, movie, func(stored *Movie) error {
+		if err := m.checkUnique(movie, stored.TenantID); err != nil {
+			return err
+		}
+		stored.Title = movie.Title
+		stored.Year = movie.Year
+		stored.Runtime = movie.Runtime
+		stored.Genres = append([]string{}, movie.Genres...)
+		stored.IMDbID = movie.IMDbID
+		stored.TMDBID = movie.TMDBID
+		stored.Overview = movie.Overview
+		stored.Tagline = movie.Tagline
+		stored.OriginalLanguage = movie.OriginalLanguage
+		stored.Country = movie.Country
+		stored.PublishAt = nil
+		if movie.PublishAt != nil {
+			publishAt := *movie.PublishAt
+			stored.PublishAt = &publishAt
+		}
+		return nil
+	})
+}
+
+func (m *MemoryMovieModel) SetPoster(ctx context.Context, movie *Movie) error {
+	return m.write(ctx, movie, func(stored *Movie) error {
+		stored.PosterKey = movie.PosterKey
+		return nil
+	})
+}
+
+func (m *MemoryMovieModel) SetStatus(ctx context.Context, movie *Movie, status string) error {
+	if !StatusTransitionAllowed(movie.Status, status) {
+		return ErrInvalidStatusTransition
+	}
+
+	err := m.write(ctx, movie, func(stored *Movie) error {
+		if stored.Status != movie.Status {
+			return ErrEditConflict
+		}
+		stored.Status = status
+		stored.PublishAt = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	movie.Status = status
+	movie.PublishAt = nil
+	return nil
+}
+
+func (m *MemoryMovieModel) PublishDue(ctx context.Context, now time.Time) ([]*Movie, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	published := []*Movie{}
+	for _, movie := range m.movies {
+		if movie.Status != MovieDraft || movie.PublishAt == nil || movie.PublishAt.After(now) {
+			continue
+		}
+		movie.Status = MoviePublished
+		movie.PublishAt = nil
+		movie.Version++
+		movie.UpdatedAt = time.Now()
+		published = append(published, &Movie{
+			ID:      movie.ID,
+			Title:   movie.Title,
+			Status:  movie.Status,
+			Version: movie.Version,
+		})
+	}
+
+	slices.SortFunc(published, func(a, b *Movie) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return published, nil
+}
+
+func (m *MemoryMovieModel) Delete(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.lookup(ctx, id); err != nil {
+		return err
+	}
+	delete(m.movies, id)
+	return nil
+}
+
+// Метод GetAll() отбирает фильмы по тем же условиям, что и MovieModel.GetAll().
+// Поиск по названию и описанию, как и plainto_tsquery(), требует наличия всех слов
+// запроса без учета регистра.
+func (m *MemoryMovieModel) GetAll(ctx context.Context, filter MovieFilter, filters Filters) ([]*Movie, Metadata, error) {
+	matched := m.matching(ctx, filter, filters)
+	if filters.CountOnly {
+		return []*Movie{}, calculateMetadata(len(matched), filters.Page, filters.PageSize), nil
+	}
+	if filters.Cursor != nil {
+		matched = slices.DeleteFunc(matched, func(movie *Movie) bool {
+			return !filters.after(movie)
+		})
+	}
+
+	totalRecords := len(matched)
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+	movies := matched[start:end]
+	if len(movies) == 0 {
+		// Как и MovieModel, за пределами последней страницы метаданные не
+		// возвращаются.
+		return movies, Metadata{}, nil
+	}
+	if filters.Cursor != nil {
+		return movies, cursorMetadata(movies, filters), nil
+	}
+
+	return movies, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Метод Iterate() передает в fn копии подходящих фильмов. Мьютекс на время вызовов
+// fn не удерживается, поэтому fn может обращаться к модели.
+func (m *MemoryMovieModel) Iterate(ctx context.Context, filter MovieFilter, filters Filters, fn func(*Movie) error) error {
+	movies := m.matching(ctx, filter, filters)
+	for _, movie := range movies {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := fn(movie)
+		if err != nil {
+			if errors.Is(err, ErrStopIteration) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Метод matching() возвращает отсортированные копии фильмов арендатора, которые
+// удовлетворяют условиям отбора.
+func (m *MemoryMovieModel) matching(ctx context.Context, filter MovieFilter, filters Filters) []*Movie {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+	words := searchWords(filter.Title)
+
+	m.mu.Lock()
+	matched := []*Movie{}
+	for _, movie := range m.movies {
+		if movie.TenantID != TenantFromContext(ctx) || !filter.matches(movie, words) {
+			continue
+		}
+		matched = append(matched, copyMovie(movie))
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(matched, func(a, b *Movie) int {
+		c := compareMovies(a, b, column)
+		if direction == "DESC" {
+			c = -c
+		}
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		return c
+	})
+
+	return matched
+}
+
+func (m *MemoryMovieModel) GetSimilar(ctx context.Context, movie *Movie, status string, limit int) ([]*SimilarMovie, error) {
+	candidates := m.candidates(ctx, movie, func(candidate *Movie) bool {
+		return status == "" || candidate.Status == status
+	})
+	return rankSimilar(movie, candidates, limit), nil
+}
+
+func (m *MemoryMovieModel) FindDuplicates(ctx context.Context, movie *Movie) ([]*Movie, error) {
+	candidates := m.candidates(ctx, movie, func(candidate *Movie) bool {
+		return candidate.Status != MovieArchived && candidate.Year >= movie.Year-1 && candidate.Year <= movie.Year+1
+	})
+	return rankDuplicates(movie, candidates), nil
+}
+
+// Метод candidates() возвращает копии фильмов арендатора, кроме самого movie,
+// для которых keep возвращает true.
+func (m *MemoryMovieModel) candidates(ctx context.Context, movie *Movie, keep func(*Movie) bool) []*Movie {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	candidates := []*Movie{}
+	for _, candidate := range m.movies {
+		if candidate.ID == movie.ID || candidate.TenantID != TenantFromContext(ctx) || !keep(candidate) {
+			continue
+		}
+		candidates = append(candidates, copyMovie(candidate))
+	}
+	return candidates
+}
+
+// Метод matches() проверяет, удовлетворяет ли фильм условиям отбора. Параметр
+// words содержит слова поискового запроса (см. searchWords()).
+func (f MovieFilter) matches(movie *Movie, words []string) bool {
+	if len(words) > 0 {
+		text := searchWords(movie.Title + " " + movie.Overview)
+		for _, word := range words {
+			if !slices.Contains(text, word) {
+				return false
+			}
+		}
+	}
+	for _, genre := range f.Genres {
+		if !slices.Contains(movie.Genres, genre) {
+			return false
+		}
+	}
+	switch {
+	case f.IMDbID != "" && movie.IMDbID != f.IMDbID:
+		return false
+	case f.TMDBID != 0 && movie.TMDBID != f.TMDBID:
+		return false
+	case f.Status != "" && movie.Status != f.Status:
+		return false
+	case f.Scheduled && movie.PublishAt == nil:
+		return false
+	case f.YearMax > 0 && movie.Year > f.YearMax:
+		return false
+	}
+	return true
+}
+
+// Функция compareMovies() сравнивает фильмы по столбцу сортировки по возрастанию.
+// Как и в PostgreSQL, пустое значение publish_at считается больше любого другого.
+func compareMovies(a, b *Movie, column string) int {
+	switch column {
+	case "title":
+		return cmp.Compare(a.Title, b.Title)
+	case "year":
+		return cmp.Compare(a.Year, b.Year)
+	case "runtime":
+		return cmp.Compare(a.Runtime, b.Runtime)
+	case "publish_at":
+		switch {
+		case a.PublishAt == nil && b.PublishAt == nil:
+			return 0
+		case a.PublishAt == nil:
+			return 1
+		case b.PublishAt == nil:
+			return -1
+		}
+		return a.PublishAt.Compare(*b.PublishAt)
+	default:
+		return cmp.Compare(a.ID, b.ID)
+	}
+}
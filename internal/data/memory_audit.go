@@ -0,0 +1,111 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryAuditEventModel — реализация AuditEventStore, которая хранит журнал аудита
+// в памяти процесса (в тестах и в режиме -demo).
+type MemoryAuditEventModel struct {
+	mu     sync.Mutex
+	events []*AuditEvent
+	nextID int64
+}
+
+// Функция NewMemoryAuditEventModel() возвращает пустой MemoryAuditEventModel.
+func NewMemoryAuditEventModel() *MemoryAuditEventModel {
+	return &MemoryAuditEventModel{nextID: 1}
+}
+
+func (m *MemoryAuditEventModel) Insert(ctx context.Context, event *AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	event.ID = m.nextID
+	event.CreatedAt = time.Now()
+	m.nextID++
+
+	m.events = append(m.events, copyAuditEvent(event))
+	return nil
+}
+
+// Метод GetAll() отбирает записи по тем же условиям, что и AuditEventModel.GetAll().
+func (m *MemoryAuditEventModel) GetAll(ctx context.Context, filter AuditEventFilter, filters Filters) ([]*AuditEvent, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	m.mu.Lock()
+	matched := []*AuditEvent{}
+	for _, event := range m.events {
+		if filter.matches(event) {
+			matched = append(matched, copyAuditEvent(event))
+		}
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(matched, func(a, b *AuditEvent) int {
+		c := cmp.Compare(a.ID, b.ID)
+		if column == "created_at" {
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if direction == "DESC" {
+			c = -c
+		}
+		if c == 0 {
+			c = cmp.Compare(b.ID, a.ID)
+		}
+		return c
+	})
+
+	totalRecords := len(matched)
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+	events := matched[start:end]
+	if len(events) == 0 {
+		// Как и AuditEventModel, за пределами последней страницы метаданные не
+		// возвращаются.
+		return events, Metadata{}, nil
+	}
+	return events, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Метод DeleteBefore() удаляет записи, созданные раньше cutoff. В памяти удаление
+// выполняется сразу, поэтому batchSize не используется.
+func (m *MemoryAuditEventModel) DeleteBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.events)
+	m.events = slices.DeleteFunc(m.events, func(event *AuditEvent) bool {
+		return event.CreatedAt.Before(cutoff)
+	})
+	return int64(before - len(m.events)), nil
+}
+
+// Метод matches() проверяет, удовлетворяет ли запись условиям отбора.
+func (f AuditEventFilter) matches(event *AuditEvent) bool {
+	switch {
+	case f.Action != "" && event.Action != f.Action:
+		return false
+	case f.Actor != "" && event.Actor != f.Actor:
+		return false
+	case f.IP != "" && event.IP != f.IP:
+		return false
+	case !f.Since.IsZero() && event.CreatedAt.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !event.CreatedAt.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Функция copyAuditEvent() возвращает копию записи, не разделяющую с ней Details.
+func copyAuditEvent(event *AuditEvent) *AuditEvent {
+	c := *event
+	c.Details = maps.Clone(event.Details)
+	return &c
+}
@@ -0,0 +1,126 @@
+package data
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryTranslationModel — реализация TranslationStore, которая хранит переводы
+// в памяти процесса вместе с MemoryMovieModel (в тестах и в режиме -demo). Как и
+// TranslationModel, она проверяет, что фильм принадлежит арендатору, и обновляет
+// время изменения фильма при изменении переводов. Переводы удаленного фильма не
+// удаляются, но и не видны: идентификаторы фильмов повторно не используются.
+type MemoryTranslationModel struct {
+	movies *MemoryMovieModel
+
+	// Мьютекс movies.mu, если он нужен, захватывается раньше mu.
+	mu           sync.Mutex
+	translations map[int64]map[string]MovieTranslation
+}
+
+// Функция NewMemoryTranslationModel() возвращает пустой MemoryTranslationModel для
+// фильмов из movies.
+func NewMemoryTranslationModel(movies *MemoryMovieModel) *MemoryTranslationModel {
+	return &MemoryTranslationModel{
+		movies:       movies,
+		translations: make(map[int64]map[string]MovieTranslation),
+	}
+}
+
+func (m *MemoryTranslationModel) Upsert(ctx context.Context, translation *MovieTranslation) error {
+	m.movies.mu.Lock()
+	defer m.movies.mu.Unlock()
+
+	movie, err := m.movies.lookup(ctx, translation.MovieID)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	translation.UpdatedAt = now
+	if m.translations[movie.ID] == nil {
+		m.translations[movie.ID] = make(map[string]MovieTranslation)
+	}
+	m.translations[movie.ID][translation.Language] = *translation
+	movie.UpdatedAt = now
+	return nil
+}
+
+func (m *MemoryTranslationModel) Delete(ctx context.Context, movieID int64, language string) error {
+	m.movies.mu.Lock()
+	defer m.movies.mu.Unlock()
+
+	movie, err := m.movies.lookup(ctx, movieID)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.translations[movieID][language]; !ok {
+		return ErrRecordNotFound
+	}
+	delete(m.translations[movieID], language)
+	movie.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *MemoryTranslationModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieTranslation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	translations := []*MovieTranslation{}
+	for _, translation := range m.translations[movieID] {
+		translations = append(translations, &translation)
+	}
+	slices.SortFunc(translations, func(a, b *MovieTranslation) int {
+		return strings.Compare(a.Language, b.Language)
+	})
+	return translations, nil
+}
+
+// Метод Localize() выбирает для каждого фильма перевод так же, как
+// TranslationModel.Localize().
+func (m *MemoryTranslationModel) Localize(ctx context.Context, movies []*Movie, languages []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, movie := range movies {
+		for _, language := range languages {
+			if translation, ok := m.translations[movie.ID][language]; ok {
+				localizeMovie(movie, translation)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Метод Reassign() переносит переводы так же, как TranslationModel.Reassign().
+func (m *MemoryTranslationModel) Reassign(ctx context.Context, fromMovieID, toMovieID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var moved int64
+	for language, translation := range m.translations[fromMovieID] {
+		if _, ok := m.translations[toMovieID][language]; ok {
+			continue
+		}
+		if m.translations[toMovieID] == nil {
+			m.translations[toMovieID] = make(map[string]MovieTranslation)
+		}
+		translation.MovieID = toMovieID
+		translation.UpdatedAt = time.Now()
+		m.translations[toMovieID][language] = translation
+		delete(m.translations[fromMovieID], language)
+		moved++
+	}
+	return moved, nil
+}
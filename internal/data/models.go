@@ -1,38 +1,147 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 )
 
 var (
 	ErrRecordNotFound = errors.New("record not found")
 	ErrEditConflict = errors.New("edit conflict")
+	ErrInvalidStatusTransition = errors.New("invalid status transition")
+	// ErrStopIteration, возвращенная из функции обратного вызова Iterate(), прекращает
+	// обход без ошибки.
+	ErrStopIteration = errors.New("stop iteration")
 )
 
+// Устанавливаем MovieStore как интерфейс, содержащий методы, которые должны поддерживать
+// как 'реальная' модель, так и мок-модель.
+type MovieStore interface {
+	Insert(ctx context.Context, movie *Movie) error
+	Get(ctx context.Context, id int64) (*Movie, error)
+	Update(ctx context.Context, movie *Movie) error
+	SetPoster(ctx context.Context, movie *Movie) error
+	SetStatus(ctx context.Context, movie *Movie, status string) error
+	PublishDue(ctx context.Context, now time.Time) ([]*Movie, error)
+	GetSimilar(ctx context.Context, movie *Movie, status string, limit int) ([]*SimilarMovie, error)
+	FindDuplicates(ctx context.Context, movie *Movie) ([]*Movie, error)
+	Delete(ctx context.Context, id int64) error
+	GetAll (ctx context.Context, filter MovieFilter, filters Filters) ([]*Movie, Metadata, error)
+	Iterate(ctx context.Context, filter MovieFilter, filters Filters, fn func(*Movie) error) error
+}
+
 type Models struct {
-	// Устанавливаем поле Movies как интерфейс, содержащий методы, которые должны поддерживать
-	// как 'реальная' модель, так и мок-модель.
-	Movies interface {
-		Insert(movie *Movie) error
-		Get(id int64) (*Movie, error)
-		Update(movie *Movie) error
-		Delete(id int64) error
-		GetAll (title string, genres []string, filters Filters) ([]*Movie, Metadata, error)
-	}
+	Movies       MovieStore
+	AuditEvents  AuditEventStore
+	Translations TranslationStore
+	// Пул соединений, используемый методом WithTx(). Для моделей, уже привязанных
+	// к транзакции, и для мок-моделей он равен nil.
+	db           *DB
+	querier      Querier
+	queryTimeout time.Duration
+	movieCache   *movieCache
+	breaker      *circuitBreaker
+	// Модели для SQLite вместо PostgreSQL (см. NewSQLiteModels()).
+	sqlite       bool
 }
 
-// Создаем вспомогательную функцию, которая возвращает экземпляр Models, содержащий только мок-модели.
+// Создаем вспомогательную функцию, которая возвращает экземпляр Models с моделями,
+// хранящими данные в памяти процесса (для тестов и демонстрационного режима).
 func NewMockModels() Models {
+	movies := NewMemoryMovieModel()
 	return Models{
-		Movies: MockMovieModel{},
+		Movies:       movies,
+		AuditEvents:  NewMemoryAuditEventModel(),
+		Translations: NewMemoryTranslationModel(movies),
 	}
 }
 
 // Для удобства мы также добавляем метод New(), который возвращает структуру Models
 // с инициализированным MovieModel.
-func NewModels(db *sql.DB) Models {
-	return Models{
-		Movies: MovieModel{DB: db},
+// Параметр queryTimeout задает тайм-аут для каждого запроса, выполняемого моделями.
+func NewModels(db *DB, queryTimeout time.Duration) Models {
+	models := Models{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+	return models.bind(db)
+}
+
+// Метод WithMovieCache() возвращает копию Models, в которой метод Movies.Get()
+// использует LRU-кеш на size записей со временем жизни ttl.
+func (m Models) WithMovieCache(size int, ttl time.Duration) Models {
+	m.movieCache = newMovieCache(size, ttl)
+	return m.bind(m.querier)
+}
+
+// Метод WithCircuitBreaker() возвращает копию Models, в которой запросы к фильмам
+// проходят через автоматический выключатель: после threshold ошибок базы данных
+// подряд модели в течение cooldown возвращают ErrCircuitOpen, не обращаясь к базе.
+func (m Models) WithCircuitBreaker(threshold int, cooldown time.Duration) Models {
+	m.breaker = newCircuitBreaker(threshold, cooldown)
+	return m.bind(m.querier)
+}
+
+// Метод CircuitBreakerStatus() возвращает состояние автоматического выключателя
+// и время до следующей попытки обращения к базе, если он разомкнут. Если
+// выключатель не используется, возвращается состояние CircuitClosed.
+func (m Models) CircuitBreakerStatus() (string, time.Duration) {
+	if m.breaker == nil {
+		return CircuitClosed, 0
+	}
+	return m.breaker.status()
+}
+
+// Метод PoolStats() возвращает статистику пулов соединений с базой данных (см.
+// DB.PoolStats()) или nil, если модели работают без базы данных.
+func (m Models) PoolStats() map[string]sql.DBStats {
+	if m.db == nil {
+		return nil
+	}
+	return m.db.PoolStats()
+}
+
+// Метод bind() создает модели, выполняющие запросы через указанный Querier —
+// пул соединений или транзакцию, — сохраняя остальные настройки Models.
+func (m Models) bind(q Querier) Models {
+	m.querier = q
+
+	var movies MovieStore = MovieModel{DB: q, QueryTimeout: m.queryTimeout}
+	var translations TranslationStore = TranslationModel{DB: q, QueryTimeout: m.queryTimeout}
+	if m.sqlite {
+		movies = sqliteMovieModel{MovieModel{DB: q, QueryTimeout: m.queryTimeout}}
+		translations = sqliteTranslationModel{TranslationModel{DB: q, QueryTimeout: m.queryTimeout}}
+	}
+	if m.breaker != nil {
+		movies = breakerMovieModel{MovieStore: movies, breaker: m.breaker}
+	}
+	// Кеш оборачивает выключатель, чтобы закешированные фильмы отдавались и тогда,
+	// когда база данных недоступна. Внутри транзакции кеш не используется: ее
+	// изменения могут быть откачены.
+	if m.movieCache != nil {
+		if tx, ok := q.(*Tx); ok {
+			movies = txMovieModel{MovieStore: movies, tx: tx}
+		} else {
+			movies = cachedMovieModel{MovieStore: movies, cache: m.movieCache}
+		}
+	}
+	m.Movies = movies
+	m.AuditEvents = AuditEventModel{DB: q, QueryTimeout: m.queryTimeout}
+	m.Translations = translations
+
+	return m
+}
+
+// Функция queryContext() возвращает контекст для выполнения одного запроса
+// с указанным тайм-аутом. Он наследуется от контекста вызывающего (обычно
+// контекста HTTP-запроса), поэтому запрос к базе отменяется и тогда, когда истек
+// срок обработки запроса или клиент отключился. Нулевое значение отключает
+// собственный тайм-аут запроса.
+func queryContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, timeout)
 }
@@ -3,56 +3,97 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/lib/pq"
 	"greenlight.andreyklimov.net/internal/validator"
 	"time"
 )
 
 // Определяем структуру MovieModel, которая содержит пул соединений с базой данных.
+// Поле DB имеет тип Querier, поэтому модель может работать как с пулом соединений,
+// так и с транзакцией (см. Models.WithTx()).
 type MovieModel struct {
-	DB *sql.DB
+	DB           Querier
+	QueryTimeout time.Duration
 }
 
-func (m MovieModel) Insert(movie *Movie) error {
+func (m MovieModel) Insert(ctx context.Context, movie *Movie) error {
 	query := `
-    INSERT INTO movies (title, year, runtime, genres)
-    VALUES ($1, $2, $3, $4)
-    RETURNING id, created_at, version`
-	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+    INSERT INTO movies (title, year, runtime, genres, imdb_id, tmdb_id, overview, tagline, original_language, country, status, publish_at, tenant_id)
+    VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, 0), $7, $8, $9, $10, $11, $12, $13)
+    RETURNING id, created_at, updated_at, version`
+	args := []any{
+		movie.Title,
+		movie.Year,
+		movie.Runtime,
+		stringArray(&movie.Genres),
+		movie.IMDbID,
+		movie.TMDBID,
+		movie.Overview,
+		movie.Tagline,
+		movie.OriginalLanguage,
+		movie.Country,
+		movie.Status,
+		movie.PublishAt,
+		TenantFromContext(ctx),
+	}
+	movie.TenantID = TenantFromContext(ctx)
 
-	// Создаём контекст с тайм-аутом 3 секунды.
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	// Создаём контекст с настраиваемым тайм-аутом запроса.
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
 	defer cancel()
 
 	// Используем QueryRowContext() и передаём контекст в качестве первого аргумента.
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.UpdatedAt, &movie.Version)
+	if err != nil {
+		return movieWriteError(err)
+	}
+	return nil
 }
 
-func (m MovieModel) Get(id int64) (*Movie, error) {
+func (m MovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	// Удаляем конструкцию pg_sleep(10).
 	query := `
-    SELECT id, created_at, title, year, runtime, genres, version
+    SELECT id, created_at, updated_at, title, year, runtime, genres, COALESCE(imdb_id, ''), COALESCE(tmdb_id, 0),
+        overview, tagline, original_language, country, status, publish_at, poster_key, version
     FROM movies
-    WHERE id = $1`
+    WHERE id = $1 AND tenant_id = $2`
 
 	var movie Movie
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	movie.TenantID = TenantFromContext(ctx)
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
 	defer cancel()
 
 	// Убираем &[]byte{} из первого аргумента Scan().
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+	// Фильм читается с основной базы, а не с реплики, хотя изначально чтение
+	// отдельного фильма тоже направлялось на реплику. За Get() обычно следует
+	// изменение с проверкой версии (PATCH, смена статуса, переводы), и отставшая
+	// реплика приводила бы к ложным конфликтам правок, а кеш фильмов заполнялся бы
+	// устаревшими записями. Транзакции и кеш не помогают: обработчики читают фильм
+	// до изменения вне транзакции, а кеш заполняется именно из Get(). На реплику
+	// направляются списки, поиск, похожие фильмы и переводы.
+	err := m.DB.QueryRowContext(ctx, query, id, movie.TenantID).Scan(
 		&movie.ID,
 		&movie.CreatedAt,
+		&movie.UpdatedAt,
 		&movie.Title,
 		&movie.Year,
 		&movie.Runtime,
-		pq.Array(&movie.Genres),
+		stringArray(&movie.Genres),
+		&movie.IMDbID,
+		&movie.TMDBID,
+		&movie.Overview,
+		&movie.Tagline,
+		&movie.OriginalLanguage,
+		&movie.Country,
+		&movie.Status,
+		&movie.PublishAt,
+		&movie.PosterKey,
 		&movie.Version,
 	)
 	if err != nil {
@@ -66,27 +107,62 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	return &movie, nil
 }
 
-func (m MovieModel) Update(movie *Movie) error {
+func (m MovieModel) Update(ctx context.Context, movie *Movie) error {
 	query := `
     UPDATE movies
-    SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-    WHERE id = $5 AND version = $6
-    RETURNING version`
+    SET title = $1, year = $2, runtime = $3, genres = $4, imdb_id = NULLIF($5, ''), tmdb_id = NULLIF($6, 0),
+        overview = $7, tagline = $8, original_language = $9, country = $10, publish_at = $11,
+        version = version + 1, updated_at = NOW()
+    WHERE id = $12 AND version = $13 AND tenant_id = $14
+    RETURNING version, updated_at`
 	args := []any{
 		movie.Title,
 		movie.Year,
 		movie.Runtime,
-		pq.Array(movie.Genres),
+		stringArray(&movie.Genres),
+		movie.IMDbID,
+		movie.TMDBID,
+		movie.Overview,
+		movie.Tagline,
+		movie.OriginalLanguage,
+		movie.Country,
+		movie.PublishAt,
 		movie.ID,
 		movie.Version,
+		TenantFromContext(ctx),
 	}
 
-	// Создаём контекст с тайм-аутом 3 секунды.
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	// Создаём контекст с настраиваемым тайм-аутом запроса.
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
 	defer cancel()
 
 	// Используем QueryRowContext() и передаём контекст в качестве первого аргумента.
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version, &movie.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return movieWriteError(err)
+		}
+	}
+	return nil
+}
+
+// Метод SetPoster() сохраняет новый ключ постера фильма. Как и Update(), он
+// увеличивает версию записи и возвращает ErrEditConflict, если запись изменилась
+// с момента чтения.
+func (m MovieModel) SetPoster(ctx context.Context, movie *Movie) error {
+	query := `
+    UPDATE movies
+    SET poster_key = $1, version = version + 1, updated_at = NOW()
+    WHERE id = $2 AND version = $3 AND tenant_id = $4
+    RETURNING version, updated_at`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movie.PosterKey, movie.ID, movie.Version, TenantFromContext(ctx)).Scan(&movie.Version, &movie.UpdatedAt)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -98,20 +174,88 @@ func (m MovieModel) Update(movie *Movie) error {
 	return nil
 }
 
-func (m MovieModel) Delete(id int64) error {
+// Метод SetStatus() переводит фильм в статус status, если такой переход разрешен
+// (см. StatusTransitionAllowed()), и возвращает ErrInvalidStatusTransition в
+// противном случае. Как и Update(), он возвращает ErrEditConflict, если запись
+// изменилась с момента чтения, в том числе если ее статус уже другой.
+func (m MovieModel) SetStatus(ctx context.Context, movie *Movie, status string) error {
+	if !StatusTransitionAllowed(movie.Status, status) {
+		return ErrInvalidStatusTransition
+	}
+
+	query := `
+    UPDATE movies
+    SET status = $1, publish_at = NULL, version = version + 1, updated_at = NOW()
+    WHERE id = $2 AND version = $3 AND status = $4 AND tenant_id = $5
+    RETURNING version, updated_at`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, status, movie.ID, movie.Version, movie.Status, TenantFromContext(ctx)).Scan(&movie.Version, &movie.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	movie.Status = status
+	movie.PublishAt = nil
+	return nil
+}
+
+// Метод PublishDue() публикует все черновики, время публикации которых (publish_at)
+// наступило к моменту now, и возвращает опубликованные фильмы (только ID, название
+// и новую версию). Метод работает с фильмами всех арендаторов.
+func (m MovieModel) PublishDue(ctx context.Context, now time.Time) ([]*Movie, error) {
+	query := `
+    UPDATE movies
+    SET status = 'published', publish_at = NULL, version = version + 1, updated_at = NOW()
+    WHERE status = 'draft' AND publish_at <= $1
+    RETURNING id, title, version`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+	for rows.Next() {
+		movie := Movie{Status: MoviePublished}
+		err := rows.Scan(&movie.ID, &movie.Title, &movie.Version)
+		if err != nil {
+			return nil, err
+		}
+		movies = append(movies, &movie)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+func (m MovieModel) Delete(ctx context.Context, id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 	query := `
     DELETE FROM movies
-    WHERE id = $1`
+    WHERE id = $1 AND tenant_id = $2`
 
-	// Создаём контекст с тайм-аутом 3 секунды.
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	// Создаём контекст с настраиваемым тайм-аутом запроса.
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
 	defer cancel()
 
 	// Используем ExecContext() и передаём контекст в качестве первого аргумента.
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := m.DB.ExecContext(ctx, query, id, TenantFromContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -126,22 +270,38 @@ func (m MovieModel) Delete(id int64) error {
 }
 
 // Обновите сигнатуру функции, чтобы она возвращала структуру Metadata.
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+func (m MovieModel) GetAll(ctx context.Context, filter MovieFilter, filters Filters) ([]*Movie, Metadata, error) {
+	if filters.CountOnly {
+		return m.countMovies(ctx, filter, filters)
+	}
+
 	// Обновите SQL-запрос, добавив оконную функцию, которая считает общее количество
 	// (отфильтрированных) записей.
+	// Если достаточно оценки общего количества, оконная функция не нужна: вместо
+	// нее выбирается 0, а оценка вычисляется отдельно (см. estimateMovies()).
+	// При обходе по курсору общее количество не нужно вовсе.
+	countColumn := "count(*) OVER()"
+	if filters.EstimateCount || filters.Cursor != nil {
+		countColumn = "0"
+	}
+	keyset, keysetArgs := filters.keyset(11)
 	query := fmt.Sprintf(`
-        SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version
+        SELECT %s, id, created_at, updated_at, title, year, runtime, genres,
+            COALESCE(imdb_id, ''), COALESCE(tmdb_id, 0), overview, tagline, original_language, country,
+            status, publish_at, poster_key, version
         FROM movies
-        WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-        AND (genres @> $2 OR $2 = '{}')
+        WHERE `+movieFilterConditions+`
+        AND tenant_id = $10
+        AND %s
         ORDER BY %s %s, id ASC
-        LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+        LIMIT $8 OFFSET $9`, countColumn, keyset, filters.sortColumn(), filters.sortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
 	defer cancel()
 
-	args := []any{title, pq.Array(genres), filters.limit(), filters.offset()}
-	rows, err := m.DB.QueryContext(ctx, query, args...)
+	args := []any{filter.Title, stringArray(&filter.Genres), filter.IMDbID, filter.TMDBID, filter.Status, filter.Scheduled, filter.YearMax, filters.limit(), filters.offset(), TenantFromContext(ctx)}
+	args = append(args, keysetArgs...)
+	rows, err := m.DB.ReadQueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err // Вернуть пустую структуру Metadata в случае ошибки.
 	}
@@ -157,10 +317,20 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 			&totalRecords, // Считаем количество записей из оконной функции.
 			&movie.ID,
 			&movie.CreatedAt,
+			&movie.UpdatedAt,
 			&movie.Title,
 			&movie.Year,
 			&movie.Runtime,
-			pq.Array(&movie.Genres),
+			stringArray(&movie.Genres),
+			&movie.IMDbID,
+			&movie.TMDBID,
+			&movie.Overview,
+			&movie.Tagline,
+			&movie.OriginalLanguage,
+			&movie.Country,
+			&movie.Status,
+			&movie.PublishAt,
+			&movie.PosterKey,
 			&movie.Version,
 		)
 		if err != nil {
@@ -173,6 +343,13 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 		return nil, Metadata{}, err // Вернуть пустую структуру Metadata в случае ошибки.
 	}
 
+	if filters.Cursor != nil {
+		return movies, cursorMetadata(movies, filters), nil
+	}
+	if filters.EstimateCount {
+		return m.withEstimatedTotal(ctx, movies, filter, filters)
+	}
+
 	// Генерируем структуру Metadata, передавая общее количество записей и параметры пагинации.
 	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
 
@@ -180,49 +357,378 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	return movies, metadata, nil
 }
 
-type MockMovieModel struct{}
+// Условия отбора фильмов по MovieFilter для GetAll() и Iterate(). Параметры $1–$7 —
+// название, жанры, IMDb ID, TMDB ID, статус, признак запланированной публикации
+// и наибольший год выхода.
+const movieFilterConditions = `(to_tsvector('simple', title || ' ' || overview) @@ plainto_tsquery('simple', $1) OR $1 = '')
+        AND (genres @> $2 OR $2 = '{}')
+        AND (imdb_id = $3 OR $3 = '')
+        AND (tmdb_id = $4 OR $4 = 0)
+        AND (status = $5 OR $5 = '')
+        AND (publish_at IS NOT NULL OR NOT $6)
+        AND (year <= $7 OR $7 = 0)`
 
-func (m MockMovieModel) Insert(movie *Movie) error {
-	return nil
+// Метод withEstimatedTotal() возвращает метаданные пагинации с оценкой общего
+// количества фильмов вместо точного подсчета. Оценка берется из плана запроса
+// (EXPLAIN), поэтому не требует чтения всех подходящих строк, но может заметно
+// отличаться от точного значения. Если страница неполная, она последняя, и общее
+// количество известно точно.
+func (m MovieModel) withEstimatedTotal(ctx context.Context, movies []*Movie, filter MovieFilter, filters Filters) ([]*Movie, Metadata, error) {
+	if len(movies) == 0 {
+		// За пределами последней страницы метаданные, как и при точном подсчете,
+		// не возвращаются.
+		return movies, Metadata{}, nil
+	}
+	seen := filters.offset() + len(movies)
+	if len(movies) < filters.limit() {
+		return movies, calculateMetadata(seen, filters.Page, filters.PageSize), nil
+	}
+
+	estimate, err := m.estimateMovies(ctx, filter)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	// Оценка не может быть меньше числа фильмов, которые уже найдены.
+	estimate = max(estimate, seen)
+	metadata := calculateMetadata(estimate, filters.Page, filters.PageSize)
+	metadata.TotalRecordsEstimated = true
+	return movies, metadata, nil
 }
 
-func (m MockMovieModel) Get(id int64) (*Movie, error) {
-	return nil, nil
+// Метод estimateMovies() возвращает оценку количества фильмов, подходящих под
+// условия filter, из плана запроса (EXPLAIN).
+func (m MovieModel) estimateMovies(ctx context.Context, filter MovieFilter) (int, error) {
+	query := `
+        EXPLAIN (FORMAT JSON)
+        SELECT id FROM movies
+        WHERE ` + movieFilterConditions + `
+        AND tenant_id = $8`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var plan []byte
+	args := []any{filter.Title, stringArray(&filter.Genres), filter.IMDbID, filter.TMDBID, filter.Status, filter.Scheduled, filter.YearMax, TenantFromContext(ctx)}
+	err := m.DB.ReadQueryRowContext(ctx, query, args...).Scan(&plan)
+	if err != nil {
+		return 0, err
+	}
+
+	var explained []struct {
+		Plan struct {
+			PlanRows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	err = json.Unmarshal(plan, &explained)
+	if err != nil || len(explained) == 0 {
+		return 0, fmt.Errorf("cannot parse query plan: %w", err)
+	}
+	return int(explained[0].Plan.PlanRows), nil
 }
 
-func (m MockMovieModel) Update(movie *Movie) error {
-	return nil
+// Метод countMovies() реализует GetAll() с filters.CountOnly: фильмы не
+// выбираются, а считается только их общее количество (точно или, с
+// filters.EstimateCount, по плану запроса).
+func (m MovieModel) countMovies(ctx context.Context, filter MovieFilter, filters Filters) ([]*Movie, Metadata, error) {
+	if filters.EstimateCount {
+		estimate, err := m.estimateMovies(ctx, filter)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		metadata := calculateMetadata(estimate, filters.Page, filters.PageSize)
+		metadata.TotalRecordsEstimated = estimate > 0
+		return []*Movie{}, metadata, nil
+	}
+
+	query := `
+        SELECT count(*) FROM movies
+        WHERE ` + movieFilterConditions + `
+        AND tenant_id = $8`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var total int
+	args := []any{filter.Title, stringArray(&filter.Genres), filter.IMDbID, filter.TMDBID, filter.Status, filter.Scheduled, filter.YearMax, TenantFromContext(ctx)}
+	err := m.DB.ReadQueryRowContext(ctx, query, args...).Scan(&total)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return []*Movie{}, calculateMetadata(total, filters.Page, filters.PageSize), nil
 }
 
-func (m MockMovieModel) Delete(id int64) error {
-	return nil
+// Метод Iterate() отбирает фильмы по тем же условиям, что и GetAll(), но не
+// собирает их в срез, а передает в fn по одному по мере чтения строк. Из filters
+// используется только сортировка: Iterate() проходит по всем подходящим фильмам.
+// Если fn возвращает ошибку, обход прекращается и ошибка возвращается вызывающему;
+// ErrStopIteration прекращает обход без ошибки.
+//
+// Обход может длиться дольше одного запроса, поэтому QueryTimeout к нему не
+// применяется: время ограничивается контекстом вызывающего. Пока идет обход, занято
+// соединение с базой данных, поэтому fn не должна сама обращаться к базе.
+func (m MovieModel) Iterate(ctx context.Context, filter MovieFilter, filters Filters, fn func(*Movie) error) error {
+	query := fmt.Sprintf(`
+        SELECT `+movieColumns+`
+        FROM movies
+        WHERE `+movieFilterConditions+`
+        AND tenant_id = $8
+        ORDER BY %s %s, id ASC`, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{filter.Title, stringArray(&filter.Genres), filter.IMDbID, filter.TMDBID, filter.Status, filter.Scheduled, filter.YearMax, TenantFromContext(ctx)}
+	rows, err := m.DB.ReadQueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return iterateMovies(rows, movieScanDest, fn)
 }
 
-func (m MockMovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
-	return nil, Metadata{}, nil
+// Функция iterateMovies() читает фильмы из rows и передает их в fn (см.
+// MovieModel.Iterate()). Параметр scanDest задает, куда читаются столбцы фильма.
+func iterateMovies(rows *sql.Rows, scanDest func(*Movie) []any, fn func(*Movie) error) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(scanDest(&movie)...)
+		if err != nil {
+			return err
+		}
+
+		err = fn(&movie)
+		if err != nil {
+			if errors.Is(err, ErrStopIteration) {
+				return nil
+			}
+			return err
+		}
+	}
+	return rows.Err()
 }
 
 type Movie struct {
 	ID        int64     `json:"id"`
 	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
 	Title     string    `json:"title"`
-	Year      int32     `json:"year,omitempty"`
-	Runtime   Runtime   `json:"runtime,omitempty"`
-	Genres    []string  `json:"genres,omitempty"`
-	Version   int32     `json:"version"`
+	// Если название переведено (см. TranslationModel.Localize()), OriginalTitle
+	// содержит исходное название, а Language — язык перевода.
+	OriginalTitle string   `json:"original_title,omitempty"`
+	Language      string   `json:"language,omitempty"`
+	Year          int32    `json:"year,omitempty"`
+	Runtime       Runtime  `json:"runtime,omitempty"`
+	Genres        []string `json:"genres,omitempty"`
+	IMDbID        string   `json:"imdb_id,omitempty"`
+	TMDBID        int64    `json:"tmdb_id,omitempty"`
+	// Описание и дополнительные сведения о фильме.
+	Overview         string `json:"overview,omitempty"`
+	Tagline          string `json:"tagline,omitempty"`
+	OriginalLanguage string `json:"original_language,omitempty"`
+	Country          string `json:"country,omitempty"`
+	// Статус публикации: draft, published или archived. Клиентам без прав
+	// администратора видны только опубликованные фильмы.
+	Status string `json:"status"`
+	// Время, когда черновик будет опубликован автоматически (см. PublishDue()).
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// Ключ файла постера в хранилище. Адрес постера для клиентов (PosterURL)
+	// вычисляется из ключа слоем HTTP.
+	PosterKey string `json:"-"`
+	// Арендатор, которому принадлежит фильм (см. WithTenant()).
+	TenantID  string `json:"-"`
+	PosterURL string `json:"poster_url,omitempty"`
+	Version   int32  `json:"version"`
 }
 
-// ValidateMovie выполняет валидацию данных фильма.
+// ValidateMovie выполняет валидацию данных фильма. Ограничения полей описаны
+// в schema.go. Для длинных текстовых полей в подробностях ошибки передается
+// длина значения, а не само значение.
 func ValidateMovie(v *validator.Validator, movie *Movie) {
-	v.Check(movie.Title != "", "title", "must be provided")
-	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
-	v.Check(movie.Year != 0, "year", "must be provided")
-	v.Check(movie.Year >= 1888, "year", "must be greater than 1888")
-	v.Check(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future")
-	v.Check(movie.Runtime != 0, "runtime", "must be provided")
-	v.Check(movie.Runtime > 0, "runtime", "must be a positive integer")
-	v.Check(movie.Genres != nil, "genres", "must be provided")
-	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
-	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
-	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+	v.CheckRule(movie.Title != "", "title", "must be provided", validator.RuleRequired(), nil)
+	v.CheckRule(validator.MaxBytes(movie.Title, MovieTitleMaxBytes), "title", fmt.Sprintf("must not be more than %d bytes long", MovieTitleMaxBytes), validator.RuleMaxBytes(MovieTitleMaxBytes), len(movie.Title))
+	v.CheckRule(movie.Year != 0, "year", "must be provided", validator.RuleRequired(), nil)
+	v.CheckRule(movie.Year >= MovieYearMin, "year", fmt.Sprintf("must be greater than %d", MovieYearMin), validator.RuleMin(MovieYearMin), movie.Year)
+	v.CheckRule(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future", validator.RuleMax(time.Now().Year()), movie.Year)
+	v.CheckRule(movie.Runtime != 0, "runtime", "must be provided", validator.RuleRequired(), nil)
+	v.CheckRule(movie.Runtime > 0, "runtime", "must be a positive integer", validator.RuleMin(1), int32(movie.Runtime))
+	v.CheckRule(movie.Genres != nil, "genres", "must be provided", validator.RuleRequired(), nil)
+	v.CheckRule(len(movie.Genres) >= MovieGenresMin, "genres", fmt.Sprintf("must contain at least %d genre", MovieGenresMin), validator.RuleMinItems(MovieGenresMin), movie.Genres)
+	v.CheckRule(len(movie.Genres) <= MovieGenresMax, "genres", fmt.Sprintf("must not contain more than %d genres", MovieGenresMax), validator.RuleMaxItems(MovieGenresMax), movie.Genres)
+	v.CheckRule(validator.Unique(movie.Genres), "genres", "must not contain duplicate values", validator.RuleUnique(), movie.Genres)
+	v.CheckRule(movie.IMDbID == "" || validator.Matches(movie.IMDbID, validator.IMDbIDRX), "imdb_id", "must be a valid IMDb ID", validator.RulePattern(validator.IMDbIDRX), movie.IMDbID)
+	v.CheckRule(movie.TMDBID >= 0, "tmdb_id", "must be a positive integer", validator.RuleMin(0), movie.TMDBID)
+	v.CheckRule(validator.MaxBytes(movie.Overview, MovieOverviewMaxBytes), "overview", fmt.Sprintf("must not be more than %d bytes long", MovieOverviewMaxBytes), validator.RuleMaxBytes(MovieOverviewMaxBytes), len(movie.Overview))
+	v.CheckRule(validator.MaxBytes(movie.Tagline, MovieTaglineMaxBytes), "tagline", fmt.Sprintf("must not be more than %d bytes long", MovieTaglineMaxBytes), validator.RuleMaxBytes(MovieTaglineMaxBytes), len(movie.Tagline))
+	v.CheckRule(movie.OriginalLanguage == "" || validator.Matches(movie.OriginalLanguage, validator.LanguageTagRX), "original_language", "must be a valid lowercase language tag", validator.RulePattern(validator.LanguageTagRX), movie.OriginalLanguage)
+	v.CheckRule(movie.Country == "" || validator.Matches(movie.Country, validator.CountryCodeRX), "country", "must be an ISO 3166-1 alpha-2 country code", validator.RulePattern(validator.CountryCodeRX), movie.Country)
+	v.CheckRule(validator.PermittedValue(movie.Status, MovieStatuses...), "status", "must be one of draft, published or archived", validator.RuleOneOf(MovieStatuses...), movie.Status)
+	v.CheckRule(movie.PublishAt == nil || movie.Status == MovieDraft, "publish_at", "can only be set on draft movies", validator.Rule{Code: "requires_status", Params: map[string]any{"status": MovieDraft}}, movie.PublishAt)
+}
+
+// Статусы публикации фильма.
+const (
+	MovieDraft     = "draft"
+	MoviePublished = "published"
+	MovieArchived  = "archived"
+)
+
+// MovieStatuses содержит все допустимые статусы фильма.
+var MovieStatuses = []string{MovieDraft, MoviePublished, MovieArchived}
+
+// movieStatusTransitions описывает разрешенные переходы между статусами: черновик
+// можно опубликовать или сразу отправить в архив, опубликованный фильм — снять
+// с публикации или отправить в архив, а архивный фильм — только вернуть в черновики.
+var movieStatusTransitions = map[string][]string{
+	MovieDraft:     {MoviePublished, MovieArchived},
+	MoviePublished: {MovieDraft, MovieArchived},
+	MovieArchived:  {MovieDraft},
+}
+
+// Функция StatusTransitionAllowed() сообщает, можно ли перевести фильм из статуса
+// from в статус to.
+func StatusTransitionAllowed(from, to string) bool {
+	return validator.PermittedValue(to, movieStatusTransitions[from]...)
+}
+
+// MovieFilter содержит необязательные условия отбора фильмов для GetAll(). Пустые
+// и нулевые значения означают отсутствие условия.
+type MovieFilter struct {
+	Title  string
+	Genres []string
+	IMDbID string
+	TMDBID int64
+	Status string
+	// Если Scheduled равно true, отбираются только фильмы с назначенным временем
+	// публикации.
+	Scheduled bool
+	// Если YearMax больше нуля, отбираются только фильмы, вышедшие не позже этого года.
+	YearMax int32
+}
+
+// Функция movieWriteError() преобразует ошибку записи фильма в ошибку слоя данных.
+func movieWriteError(err error) error {
+	switch {
+	case pgErrorCode(err) == pgCheckViolation:
+		return ErrCheckViolation
+	case pgErrorCode(err) == pgUniqueViolation && pgConstraintName(err) == "movies_imdb_id_key":
+		return ErrDuplicateIMDbID
+	case pgErrorCode(err) == pgUniqueViolation && pgConstraintName(err) == "movies_tmdb_id_key":
+		return ErrDuplicateTMDBID
+	default:
+		return err
+	}
+}
+
+// SimilarMovie — фильм, похожий на другой фильм, с оценками сходства.
+type SimilarMovie struct {
+	*Movie
+	TitleSimilarity float64 `json:"title_similarity"`
+	SharedGenres    int     `json:"shared_genres"`
+}
+
+// Список столбцов фильма в порядке полей, возвращаемых movieScanDest().
+const movieColumns = `id, created_at, updated_at, title, year, runtime, genres, COALESCE(imdb_id, ''),
+    COALESCE(tmdb_id, 0), overview, tagline, original_language, country, status, publish_at, poster_key, version`
+
+// Функция movieScanDest() возвращает указатели на поля фильма в порядке
+// столбцов movieColumns для передачи в Scan().
+func movieScanDest(movie *Movie) []any {
+	return []any{
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.UpdatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		stringArray(&movie.Genres),
+		&movie.IMDbID,
+		&movie.TMDBID,
+		&movie.Overview,
+		&movie.Tagline,
+		&movie.OriginalLanguage,
+		&movie.Country,
+		&movie.Status,
+		&movie.PublishAt,
+		&movie.PosterKey,
+		&movie.Version,
+	}
+}
+
+// Метод GetSimilar() возвращает до limit фильмов, похожих на movie: с похожим
+// названием (по сходству триграмм, см. расширение pg_trgm) или общими жанрами.
+// Сначала идут фильмы с наибольшим сходством названия и числом общих жанров.
+// Непустой status ограничивает выборку фильмами в этом статусе.
+func (m MovieModel) GetSimilar(ctx context.Context, movie *Movie, status string, limit int) ([]*SimilarMovie, error) {
+	query := `
+        SELECT ` + movieColumns + `, similarity(title, $2) AS title_similarity,
+            cardinality(ARRAY(SELECT unnest(genres) INTERSECT SELECT unnest($3::text[]))) AS shared_genres
+        FROM movies
+        WHERE id <> $1
+        AND (title % $2 OR genres && $3)
+        AND (status = $4 OR $4 = '')
+        AND tenant_id = $6
+        ORDER BY similarity(title, $2) + 0.1 * cardinality(ARRAY(SELECT unnest(genres) INTERSECT SELECT unnest($3::text[]))) DESC, id ASC
+        LIMIT $5`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.ReadQueryContext(ctx, query, movie.ID, movie.Title, stringArray(&movie.Genres), status, limit, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	similar := []*SimilarMovie{}
+	for rows.Next() {
+		s := SimilarMovie{Movie: &Movie{}}
+		dest := append(movieScanDest(s.Movie), &s.TitleSimilarity, &s.SharedGenres)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		similar = append(similar, &s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return similar, nil
+}
+
+// Метод FindDuplicates() возвращает фильмы, которые, вероятно, являются дубликатами
+// movie: с очень похожим названием и годом выпуска, отличающимся не более чем на
+// год. Архивные фильмы не учитываются. Метод можно вызывать до вставки фильма.
+func (m MovieModel) FindDuplicates(ctx context.Context, movie *Movie) ([]*Movie, error) {
+	query := `
+        SELECT ` + movieColumns + `
+        FROM movies
+        WHERE id <> $1
+        AND similarity(title, $2) >= 0.6
+        AND year BETWEEN $3 - 1 AND $3 + 1
+        AND status <> 'archived'
+        AND tenant_id = $4
+        ORDER BY similarity(title, $2) DESC, id ASC
+        LIMIT 5`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.ReadQueryContext(ctx, query, movie.ID, movie.Title, movie.Year, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	duplicates := []*Movie{}
+	for rows.Next() {
+		var duplicate Movie
+		if err := rows.Scan(movieScanDest(&duplicate)...); err != nil {
+			return nil, err
+		}
+		duplicates = append(duplicates, &duplicate)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return duplicates, nil
 }
@@ -0,0 +1,80 @@
+package data
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+// Коды ошибок PostgreSQL (SQLSTATE), которые обрабатываются слоем данных.
+// Полный список: https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgCheckViolation      = "23514"
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+var (
+	// Ошибка, возвращаемая моделями, когда запись нарушает ограничение CHECK в базе данных.
+	ErrCheckViolation = errors.New("check constraint violation")
+	// Ошибки, возвращаемые моделями, когда фильм с таким же внешним идентификатором
+	// уже существует.
+	ErrDuplicateIMDbID = errors.New("duplicate imdb id")
+	ErrDuplicateTMDBID = errors.New("duplicate tmdb id")
+)
+
+// Интерфейс для значений, которые можно как передать параметром запроса, так и
+// прочитать из результата с помощью Scan().
+type valueScanner interface {
+	driver.Valuer
+	sql.Scanner
+}
+
+// Функция stringArray() оборачивает срез строк для передачи в качестве параметра
+// типа text[] или чтения столбца text[]. Значение кодируется в текстовое представление
+// массива PostgreSQL, которое одинаково понимают и lib/pq, и pgx, поэтому модели
+// не зависят от того, какой драйвер используется.
+func stringArray(a *[]string) valueScanner {
+	return (*pq.StringArray)(a)
+}
+
+// Функция int64Array() аналогична stringArray() для параметров и столбцов типа bigint[].
+func int64Array(a *[]int64) valueScanner {
+	return (*pq.Int64Array)(a)
+}
+
+// Функция pgErrorCode() возвращает код SQLSTATE из ошибки PostgreSQL независимо от
+// драйвера (lib/pq или pgx). Если ошибка не является ошибкой PostgreSQL, возвращается
+// пустая строка.
+func pgErrorCode(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+
+	return ""
+}
+
+// Функция pgConstraintName() возвращает имя нарушенного ограничения (или индекса)
+// из ошибки PostgreSQL независимо от драйвера.
+func pgConstraintName(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Constraint
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName
+	}
+
+	return ""
+}
@@ -0,0 +1,54 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/assert"
+)
+
+func TestPublishDue(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	for name, store := range newIterateTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			movies := []*Movie{
+				{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: MovieDraft, PublishAt: &past},
+				{Title: "Deadpool", Year: 2016, Runtime: 108, Genres: []string{"action"}, Status: MovieDraft, PublishAt: &future},
+				{Title: "Black Panther", Year: 2018, Runtime: 134, Genres: []string{"action"}, Status: MovieDraft},
+			}
+			for _, movie := range movies {
+				assert.NilError(t, store.Insert(ctx, movie))
+			}
+			// Черновик другого арендатора тоже публикуется.
+			other := &Movie{Title: "Up", Year: 2009, Runtime: 96, Genres: []string{"animation"}, Status: MovieDraft, PublishAt: &past}
+			assert.NilError(t, store.Insert(WithTenant(ctx, "acme"), other))
+
+			published, err := store.PublishDue(ctx, now)
+			assert.NilError(t, err)
+			assert.Equal(t, len(published), 2)
+			assert.Equal(t, published[0].Title, "Moana")
+			assert.Equal(t, published[0].Version, movies[0].Version+1)
+			assert.Equal(t, published[1].Title, "Up")
+
+			movie, err := store.Get(ctx, movies[0].ID)
+			assert.NilError(t, err)
+			assert.Equal(t, movie.Status, MoviePublished)
+			assert.Equal(t, movie.PublishAt == nil, true)
+
+			for _, movie := range movies[1:] {
+				stored, err := store.Get(ctx, movie.ID)
+				assert.NilError(t, err)
+				assert.Equal(t, stored.Status, MovieDraft)
+			}
+
+			// Повторный запуск ничего не публикует.
+			published, err = store.PublishDue(ctx, now)
+			assert.NilError(t, err)
+			assert.Equal(t, len(published), 0)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/validator"
+)
+
+// Ограничения полей фильма. Их проверяет ValidateMovie() и описывает MovieSchema(),
+// поэтому клиенты видят те же ограничения, что применяет API.
+const (
+	MovieTitleMaxBytes    = 500
+	MovieYearMin          = 1888
+	MovieGenresMin        = 1
+	MovieGenresMax        = 5
+	MovieOverviewMaxBytes = 5000
+	MovieTaglineMaxBytes  = 300
+)
+
+// MovieSortSafelist содержит допустимые значения параметра sort для списка фильмов.
+var MovieSortSafelist = []string{"id", "title", "year", "runtime", "publish_at", "-id", "-title", "-year", "-runtime", "-publish_at"}
+
+// FieldSchema описывает поле ресурса (или параметр запроса) в машиночитаемом виде:
+// тип JSON-значения и ограничения, которые проверяет API. Отсутствующие
+// ограничения не выводятся.
+type FieldSchema struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Items    string   `json:"items,omitempty"`
+	Format   string   `json:"format,omitempty"`
+	Required bool     `json:"required"`
+	ReadOnly bool     `json:"read_only,omitempty"`
+	MaxBytes int      `json:"max_bytes,omitempty"`
+	Minimum  *int     `json:"minimum,omitempty"`
+	Maximum  *int     `json:"maximum,omitempty"`
+	MinItems int      `json:"min_items,omitempty"`
+	MaxItems int      `json:"max_items,omitempty"`
+	Unique   bool     `json:"unique_items,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+	Default  string   `json:"default,omitempty"`
+	// Description поясняет назначение поля или параметра, если оно неочевидно.
+	Description string `json:"description,omitempty"`
+}
+
+// Функция MovieListParameters() описывает параметры строки запроса списка фильмов
+// (GET /v1/movies).
+func MovieListParameters() []FieldSchema {
+	return []FieldSchema{
+		{Name: "title", Type: "string", Description: "full-text search in title and overview"},
+		{Name: "genres", Type: "array", Items: "string", Format: "comma-separated", Description: "movies must have all listed genres"},
+		{Name: "imdb_id", Type: "string", Pattern: validator.IMDbIDRX.String()},
+		{Name: "tmdb_id", Type: "integer", Minimum: intPtr(0)},
+		{Name: "year_max", Type: "integer", Minimum: intPtr(0), Description: "latest release year"},
+		{Name: "status", Type: "string", Enum: MovieStatuses, Description: "admin only; other clients see published movies"},
+		{Name: "scheduled", Type: "boolean", Default: "false", Description: "admin only; movies with a scheduled publication"},
+		{Name: "page", Type: "integer", Minimum: intPtr(1), Maximum: intPtr(MaxPage), Default: "1", Description: fmt.Sprintf("at most %d records can be skipped; use cursor for deeper pages", MaxOffset)},
+		{Name: "page_size", Type: "integer", Minimum: intPtr(1), Maximum: intPtr(MaxPageSize), Default: "20"},
+		{Name: "sort", Type: "string", Enum: MovieSortSafelist, Default: "id"},
+		{Name: "exact_count", Type: "boolean", Default: "true", Description: "false returns an estimated total_records"},
+		{Name: "cursor", Type: "string", Description: "next_cursor from the previous page's metadata"},
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// Функция MovieSchema() описывает поля фильма. Наибольший год выпуска зависит от
+// текущей даты, поэтому описание строится при каждом вызове.
+func MovieSchema() []FieldSchema {
+	return []FieldSchema{
+		{Name: "id", Type: "integer", ReadOnly: true},
+		{Name: "title", Type: "string", Required: true, MaxBytes: MovieTitleMaxBytes},
+		{Name: "year", Type: "integer", Required: true, Minimum: intPtr(MovieYearMin), Maximum: intPtr(time.Now().Year())},
+		{Name: "runtime", Type: "string", Format: "{minutes} mins", Required: true, Minimum: intPtr(1)},
+		{Name: "genres", Type: "array", Items: "string", Required: true, MinItems: MovieGenresMin, MaxItems: MovieGenresMax, Unique: true},
+		{Name: "imdb_id", Type: "string", Pattern: validator.IMDbIDRX.String()},
+		{Name: "tmdb_id", Type: "integer", Minimum: intPtr(0)},
+		{Name: "overview", Type: "string", MaxBytes: MovieOverviewMaxBytes},
+		{Name: "tagline", Type: "string", MaxBytes: MovieTaglineMaxBytes},
+		{Name: "original_language", Type: "string", Pattern: validator.LanguageTagRX.String()},
+		{Name: "country", Type: "string", Pattern: validator.CountryCodeRX.String()},
+		{Name: "status", Type: "string", Enum: MovieStatuses, Default: MoviePublished},
+		{Name: "publish_at", Type: "string", Format: "date-time"},
+		{Name: "poster_url", Type: "string", Format: "uri", ReadOnly: true},
+		{Name: "version", Type: "integer", ReadOnly: true},
+	}
+}
@@ -0,0 +1,119 @@
+package data
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Порог сходства названий, начиная с которого фильмы считаются похожими. Совпадает
+// с порогом оператора % в pg_trgm по умолчанию.
+const similarityThreshold = 0.3
+
+// Функции этого файла повторяют в приложении логику MovieModel.GetSimilar() и
+// MovieModel.FindDuplicates() для моделей, у которых нет расширения pg_trgm
+// (SQLite и модели в памяти).
+
+// Функция rankSimilar() отбирает из candidates фильмы, похожие на movie, и
+// возвращает до limit из них в порядке убывания сходства.
+func rankSimilar(movie *Movie, candidates []*Movie, limit int) []*SimilarMovie {
+	similar := []*SimilarMovie{}
+	for _, candidate := range candidates {
+		s := &SimilarMovie{
+			Movie:           candidate,
+			TitleSimilarity: trigramSimilarity(candidate.Title, movie.Title),
+		}
+		for _, genre := range candidate.Genres {
+			if slices.Contains(movie.Genres, genre) {
+				s.SharedGenres++
+			}
+		}
+		if s.TitleSimilarity >= similarityThreshold || s.SharedGenres > 0 {
+			similar = append(similar, s)
+		}
+	}
+
+	score := func(s *SimilarMovie) float64 {
+		return s.TitleSimilarity + 0.1*float64(s.SharedGenres)
+	}
+	slices.SortStableFunc(similar, func(a, b *SimilarMovie) int {
+		switch {
+		case score(a) > score(b):
+			return -1
+		case score(a) < score(b):
+			return 1
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	if len(similar) > limit {
+		similar = similar[:limit]
+	}
+	return similar
+}
+
+// Функция rankDuplicates() отбирает из candidates (уже отфильтрованных по году и
+// статусу) фильмы с очень похожим на movie названием, не более пяти.
+func rankDuplicates(movie *Movie, candidates []*Movie) []*Movie {
+	similarity := make(map[int64]float64)
+	duplicates := []*Movie{}
+	for _, candidate := range candidates {
+		if s := trigramSimilarity(candidate.Title, movie.Title); s >= 0.6 {
+			similarity[candidate.ID] = s
+			duplicates = append(duplicates, candidate)
+		}
+	}
+
+	slices.SortStableFunc(duplicates, func(a, b *Movie) int {
+		switch {
+		case similarity[a.ID] > similarity[b.ID]:
+			return -1
+		case similarity[a.ID] < similarity[b.ID]:
+			return 1
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	if len(duplicates) > 5 {
+		duplicates = duplicates[:5]
+	}
+	return duplicates
+}
+
+// Функция trigramSimilarity() вычисляет сходство строк так же, как функция
+// similarity() расширения pg_trgm: отношение числа общих триграмм к числу всех
+// различных триграмм обеих строк. Каждое слово дополняется двумя пробелами в начале
+// и одним в конце, а регистр не учитывается.
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for trigram := range ta {
+		if tb[trigram] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range searchWords(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// Функция searchWords() разбивает строку на слова в нижнем регистре.
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,563 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Схема базы данных SQLite (см. ApplySQLiteSchema()).
+//
+//go:embed sqlite_schema.sql
+var sqliteSchema string
+
+// Функция ApplySQLiteSchema() создает таблицы и индексы в базе данных SQLite, если
+// их еще нет. Для SQLite миграции не используются: схема применяется целиком при
+// каждом запуске.
+func ApplySQLiteSchema(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, sqliteSchema)
+	return err
+}
+
+// Функция NewSQLiteModels() аналогична NewModels(), но возвращает модели, которые
+// работают с базой данных SQLite (драйвер modernc.org/sqlite). SQLite используется
+// для локальной разработки и тестов, поэтому часть возможностей PostgreSQL
+// реализована упрощенно: полнотекстовый поиск заменен поиском подстроки, а сходство
+// названий (pg_trgm) вычисляется в приложении.
+func NewSQLiteModels(db *DB, queryTimeout time.Duration) Models {
+	models := Models{
+		db:           db,
+		queryTimeout: queryTimeout,
+		sqlite:       true,
+	}
+	return models.bind(db)
+}
+
+// Тип jsonArrayValue хранит срез в столбце TEXT в виде JSON-массива. Это аналог
+// stringArray() и int64Array() для SQLite, в которой нет типов-массивов.
+type jsonArrayValue[T any] struct {
+	a *[]T
+}
+
+// Функция jsonArray() оборачивает срез для передачи в качестве параметра или
+// чтения столбца с JSON-массивом.
+func jsonArray[T any](a *[]T) valueScanner {
+	return jsonArrayValue[T]{a: a}
+}
+
+func (j jsonArrayValue[T]) Value() (driver.Value, error) {
+	if *j.a == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(*j.a)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (j jsonArrayValue[T]) Scan(src any) error {
+	switch src := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(src), j.a)
+	case []byte:
+		return json.Unmarshal(src, j.a)
+	case nil:
+		*j.a = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into a JSON array", src)
+	}
+}
+
+// Функция sqliteTime() приводит время к UTC, чтобы значения в SQLite, которые
+// хранятся в виде строк, сравнивались правильно.
+func sqliteTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// Функция sqliteWriteError() преобразует ошибку записи в SQLite в ошибку слоя данных.
+// Драйвер не экспортирует имена нарушенных ограничений, поэтому они определяются
+// по тексту ошибки.
+func sqliteWriteError(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "UNIQUE constraint failed: movies.tenant_id, movies.imdb_id"):
+		return ErrDuplicateIMDbID
+	case strings.Contains(message, "UNIQUE constraint failed: movies.tenant_id, movies.tmdb_id"):
+		return ErrDuplicateTMDBID
+	case strings.Contains(message, "CHECK constraint failed"):
+		return ErrCheckViolation
+	case strings.Contains(message, "FOREIGN KEY constraint failed"):
+		return ErrRecordNotFound
+	default:
+		return err
+	}
+}
+
+// Функция sqliteMovieScanDest() аналогична movieScanDest(), но читает жанры
+// из JSON-массива.
+func sqliteMovieScanDest(movie *Movie) []any {
+	dest := movieScanDest(movie)
+	dest[6] = jsonArray(&movie.Genres)
+	return dest
+}
+
+// sqliteMovieModel — реализация MovieStore для SQLite. Метод Delete() переносим
+// и наследуется от MovieModel.
+type sqliteMovieModel struct {
+	MovieModel
+}
+
+func (m sqliteMovieModel) Insert(ctx context.Context, movie *Movie) error {
+	query := `
+    INSERT INTO movies (title, year, runtime, genres, imdb_id, tmdb_id, overview, tagline, original_language, country, status, publish_at, tenant_id)
+    VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, 0), $7, $8, $9, $10, $11, $12, $13)
+    RETURNING id, created_at, updated_at, version`
+	args := []any{
+		movie.Title,
+		movie.Year,
+		movie.Runtime,
+		jsonArray(&movie.Genres),
+		movie.IMDbID,
+		movie.TMDBID,
+		movie.Overview,
+		movie.Tagline,
+		movie.OriginalLanguage,
+		movie.Country,
+		movie.Status,
+		sqliteTime(movie.PublishAt),
+		TenantFromContext(ctx),
+	}
+	movie.TenantID = TenantFromContext(ctx)
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.UpdatedAt, &movie.Version)
+	return sqliteWriteError(err)
+}
+
+func (m sqliteMovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+    SELECT ` + movieColumns + `
+    FROM movies
+    WHERE id = $1 AND tenant_id = $2`
+
+	var movie Movie
+	movie.TenantID = TenantFromContext(ctx)
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	// Как и в MovieModel.Get(), фильм читается с основной базы.
+	err := m.DB.QueryRowContext(ctx, query, id, movie.TenantID).Scan(sqliteMovieScanDest(&movie)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &movie, nil
+}
+
+func (m sqliteMovieModel) Update(ctx context.Context, movie *Movie) error {
+	query := `
+    UPDATE movies
+    SET title = $1, year = $2, runtime = $3, genres = $4, imdb_id = NULLIF($5, ''), tmdb_id = NULLIF($6, 0),
+        overview = $7, tagline = $8, original_language = $9, country = $10, publish_at = $11,
+        version = version + 1, updated_at = CURRENT_TIMESTAMP
+    WHERE id = $12 AND version = $13 AND tenant_id = $14
+    RETURNING version, updated_at`
+	args := []any{
+		movie.Title,
+		movie.Year,
+		movie.Runtime,
+		jsonArray(&movie.Genres),
+		movie.IMDbID,
+		movie.TMDBID,
+		movie.Overview,
+		movie.Tagline,
+		movie.OriginalLanguage,
+		movie.Country,
+		sqliteTime(movie.PublishAt),
+		movie.ID,
+		movie.Version,
+		TenantFromContext(ctx),
+	}
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version, &movie.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return sqliteWriteError(err)
+		}
+	}
+	return nil
+}
+
+func (m sqliteMovieModel) SetPoster(ctx context.Context, movie *Movie) error {
+	query := `
+    UPDATE movies
+    SET poster_key = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+    WHERE id = $2 AND version = $3 AND tenant_id = $4
+    RETURNING version, updated_at`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movie.PosterKey, movie.ID, movie.Version, TenantFromContext(ctx)).Scan(&movie.Version, &movie.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m sqliteMovieModel) SetStatus(ctx context.Context, movie *Movie, status string) error {
+	if !StatusTransitionAllowed(movie.Status, status) {
+		return ErrInvalidStatusTransition
+	}
+
+	query := `
+    UPDATE movies
+    SET status = $1, publish_at = NULL, version = version + 1, updated_at = CURRENT_TIMESTAMP
+    WHERE id = $2 AND version = $3 AND status = $4 AND tenant_id = $5
+    RETURNING version, updated_at`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, status, movie.ID, movie.Version, movie.Status, TenantFromContext(ctx)).Scan(&movie.Version, &movie.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	movie.Status = status
+	movie.PublishAt = nil
+	return nil
+}
+
+func (m sqliteMovieModel) PublishDue(ctx context.Context, now time.Time) ([]*Movie, error) {
+	query := `
+    UPDATE movies
+    SET status = 'published', publish_at = NULL, version = version + 1, updated_at = CURRENT_TIMESTAMP
+    WHERE status = 'draft' AND publish_at <= $1
+    RETURNING id, title, version`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, sqliteTime(&now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+	for rows.Next() {
+		movie := Movie{Status: MoviePublished}
+		err := rows.Scan(&movie.ID, &movie.Title, &movie.Version)
+		if err != nil {
+			return nil, err
+		}
+		movies = append(movies, &movie)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// Метод GetAll() поддерживает те же условия отбора, что и MovieModel.GetAll(), но
+// ищет название и описание как подстроку (без учета регистра для латиницы).
+// Условия отбора фильмов по MovieFilter в SQLite (аналог movieFilterConditions).
+// Полнотекстовый поиск заменен поиском подстроки.
+const sqliteMovieFilterConditions = `((title || ' ' || overview) LIKE '%%' || $1 || '%%' OR $1 = '')
+        AND (NOT EXISTS (
+            SELECT 1 FROM json_each($2) AS wanted
+            WHERE wanted.value NOT IN (SELECT value FROM json_each(movies.genres))
+        ))
+        AND (imdb_id = $3 OR $3 = '')
+        AND (tmdb_id = $4 OR $4 = 0)
+        AND (status = $5 OR $5 = '')
+        AND (publish_at IS NOT NULL OR NOT $6)
+        AND (year <= $7 OR $7 = 0)`
+
+func (m sqliteMovieModel) GetAll(ctx context.Context, filter MovieFilter, filters Filters) ([]*Movie, Metadata, error) {
+	// Количество считается точно: оценки планировщика в SQLite нет.
+	if filters.CountOnly {
+		query := fmt.Sprintf(`SELECT count(*) FROM movies WHERE ` + sqliteMovieFilterConditions + ` AND tenant_id = $8`)
+
+		ctx, cancel := queryContext(ctx, m.QueryTimeout)
+		defer cancel()
+
+		var total int
+		args := []any{filter.Title, jsonArray(&filter.Genres), filter.IMDbID, filter.TMDBID, filter.Status, filter.Scheduled, filter.YearMax, TenantFromContext(ctx)}
+		err := m.DB.ReadQueryRowContext(ctx, query, args...).Scan(&total)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		return []*Movie{}, calculateMetadata(total, filters.Page, filters.PageSize), nil
+	}
+
+	keyset, keysetArgs := filters.keyset(11)
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), `+movieColumns+`
+        FROM movies
+        WHERE `+sqliteMovieFilterConditions+`
+        AND tenant_id = $10
+        AND %s
+        ORDER BY %s %s, id ASC
+        LIMIT $8 OFFSET $9`, keyset, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	args := []any{filter.Title, jsonArray(&filter.Genres), filter.IMDbID, filter.TMDBID, filter.Status, filter.Scheduled, filter.YearMax, filters.limit(), filters.offset(), TenantFromContext(ctx)}
+	args = append(args, keysetArgs...)
+	rows, err := m.DB.ReadQueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+		dest := append([]any{&totalRecords}, sqliteMovieScanDest(&movie)...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, Metadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if filters.Cursor != nil {
+		return movies, cursorMetadata(movies, filters), nil
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return movies, metadata, nil
+}
+
+// Метод Iterate() аналогичен MovieModel.Iterate() с условиями отбора как
+// в sqliteMovieModel.GetAll().
+func (m sqliteMovieModel) Iterate(ctx context.Context, filter MovieFilter, filters Filters, fn func(*Movie) error) error {
+	query := fmt.Sprintf(`
+        SELECT `+movieColumns+`
+        FROM movies
+        WHERE `+sqliteMovieFilterConditions+`
+        AND tenant_id = $8
+        ORDER BY %s %s, id ASC`, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{filter.Title, jsonArray(&filter.Genres), filter.IMDbID, filter.TMDBID, filter.Status, filter.Scheduled, filter.YearMax, TenantFromContext(ctx)}
+	rows, err := m.DB.ReadQueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return iterateMovies(rows, sqliteMovieScanDest, fn)
+}
+
+// Метод GetSimilar() отбирает фильмы так же, как MovieModel.GetSimilar(), но
+// сходство названий вычисляется в приложении, поэтому читаются все фильмы
+// арендатора. Для локальной базы данных это приемлемо.
+func (m sqliteMovieModel) GetSimilar(ctx context.Context, movie *Movie, status string, limit int) ([]*SimilarMovie, error) {
+	query := `
+        SELECT ` + movieColumns + `
+        FROM movies
+        WHERE id <> $1
+        AND (status = $2 OR $2 = '')
+        AND tenant_id = $3`
+
+	candidates, err := m.query(ctx, query, movie.ID, status, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return rankSimilar(movie, candidates, limit), nil
+}
+
+func (m sqliteMovieModel) FindDuplicates(ctx context.Context, movie *Movie) ([]*Movie, error) {
+	query := `
+        SELECT ` + movieColumns + `
+        FROM movies
+        WHERE id <> $1
+        AND year BETWEEN $2 - 1 AND $2 + 1
+        AND status <> 'archived'
+        AND tenant_id = $3`
+
+	candidates, err := m.query(ctx, query, movie.ID, movie.Year, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return rankDuplicates(movie, candidates), nil
+}
+
+// Метод query() выполняет запрос на чтение, возвращающий столбцы movieColumns.
+func (m sqliteMovieModel) query(ctx context.Context, query string, args ...any) ([]*Movie, error) {
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.ReadQueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+	for rows.Next() {
+		var movie Movie
+		if err := rows.Scan(sqliteMovieScanDest(&movie)...); err != nil {
+			return nil, err
+		}
+		movies = append(movies, &movie)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
+
+// sqliteTranslationModel — реализация TranslationStore для SQLite. Метод
+// GetAllForMovie() переносим и наследуется от TranslationModel.
+type sqliteTranslationModel struct {
+	TranslationModel
+}
+
+func (m sqliteTranslationModel) Upsert(ctx context.Context, translation *MovieTranslation) error {
+	// В SQLite нет изменяющих данные CTE, поэтому время изменения фильма обновляется
+	// отдельным запросом.
+	query := `
+    INSERT INTO movie_translations (movie_id, language, title, overview)
+    SELECT id, $2, $3, $4 FROM movies WHERE id = $1 AND tenant_id = $5
+    ON CONFLICT (movie_id, language) DO UPDATE
+    SET title = excluded.title, overview = excluded.overview, updated_at = CURRENT_TIMESTAMP
+    RETURNING updated_at`
+	args := []any{translation.MovieID, translation.Language, translation.Title, translation.Overview, TenantFromContext(ctx)}
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&translation.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return sqliteWriteError(err)
+		}
+	}
+
+	_, err = m.DB.ExecContext(ctx, `UPDATE movies SET updated_at = CURRENT_TIMESTAMP WHERE id = $1`, translation.MovieID)
+	return err
+}
+
+func (m sqliteTranslationModel) Delete(ctx context.Context, movieID int64, language string) error {
+	query := `
+    DELETE FROM movie_translations
+    WHERE movie_id = $1 AND language = $2
+    AND movie_id IN (SELECT id FROM movies WHERE tenant_id = $3)`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, language, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrRecordNotFound
+	}
+
+	_, err = m.DB.ExecContext(ctx, `UPDATE movies SET updated_at = CURRENT_TIMESTAMP WHERE id = $1`, movieID)
+	return err
+}
+
+func (m sqliteTranslationModel) Localize(ctx context.Context, movies []*Movie, languages []string) error {
+	if len(movies) == 0 || len(languages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	query := `
+    SELECT movie_id, language, title, overview
+    FROM movie_translations
+    WHERE movie_id IN (SELECT value FROM json_each($1))
+    AND language IN (SELECT value FROM json_each($2))`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.ReadQueryContext(ctx, query, jsonArray(&ids), jsonArray(&languages))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	return localizeRows(rows, movies, languages)
+}
+
+func (m sqliteTranslationModel) Reassign(ctx context.Context, fromMovieID, toMovieID int64) (int64, error) {
+	query := `
+    UPDATE movie_translations
+    SET movie_id = $2, updated_at = CURRENT_TIMESTAMP
+    WHERE movie_id = $1
+    AND language NOT IN (SELECT language FROM movie_translations WHERE movie_id = $2)`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, fromMovieID, toMovieID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
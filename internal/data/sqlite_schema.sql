@@ -0,0 +1,51 @@
+-- Схема базы данных SQLite для локальной разработки и тестов. Она соответствует
+-- миграциям PostgreSQL из каталога migrations и применяется при каждом запуске
+-- (все операторы идемпотентны).
+CREATE TABLE IF NOT EXISTS movies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    title TEXT NOT NULL,
+    year INTEGER NOT NULL CHECK (year >= 1888),
+    runtime INTEGER NOT NULL CHECK (runtime >= 0),
+    genres TEXT NOT NULL CHECK (json_array_length(genres) BETWEEN 1 AND 5),
+    imdb_id TEXT,
+    tmdb_id INTEGER,
+    overview TEXT NOT NULL DEFAULT '',
+    tagline TEXT NOT NULL DEFAULT '',
+    original_language TEXT NOT NULL DEFAULT '',
+    country TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'published', 'archived')),
+    publish_at TIMESTAMP,
+    poster_key TEXT NOT NULL DEFAULT '',
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS movies_tenant_id_idx ON movies (tenant_id);
+CREATE INDEX IF NOT EXISTS movies_status_idx ON movies (status);
+CREATE UNIQUE INDEX IF NOT EXISTS movies_imdb_id_key ON movies (tenant_id, imdb_id);
+CREATE UNIQUE INDEX IF NOT EXISTS movies_tmdb_id_key ON movies (tenant_id, tmdb_id);
+
+CREATE TABLE IF NOT EXISTS movie_translations (
+    movie_id INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    language TEXT NOT NULL,
+    title TEXT NOT NULL,
+    overview TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (movie_id, language)
+);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_created_at_idx ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS audit_events_action_idx ON audit_events (action);
@@ -0,0 +1,92 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// stmtCache — это лениво заполняемый кеш подготовленных выражений для одного пула
+// соединений. Выражение подготавливается при первом выполнении запроса и затем
+// переиспользуется, поэтому PostgreSQL не разбирает один и тот же запрос заново
+// при каждом вызове. *sql.Stmt безопасен для конкурентного использования и сам
+// подготавливает выражение на новых соединениях пула.
+type stmtCache struct {
+	pool    *sql.DB
+	maxSize int
+
+	mu    sync.RWMutex
+	stmts map[string]*sql.Stmt
+}
+
+func newStmtCache(pool *sql.DB, maxSize int) *stmtCache {
+	return &stmtCache{
+		pool:    pool,
+		maxSize: maxSize,
+		stmts:   make(map[string]*sql.Stmt),
+	}
+}
+
+// Метод get() возвращает подготовленное выражение для запроса. Если кеш отключен,
+// заполнен или подготовить выражение не удалось, возвращается nil, и запрос
+// следует выполнить без подготовки.
+func (c *stmtCache) get(ctx context.Context, query string) *sql.Stmt {
+	if c == nil || c.maxSize <= 0 {
+		return nil
+	}
+
+	c.mu.RLock()
+	stmt, ok := c.stmts[query]
+	size := len(c.stmts)
+	c.mu.RUnlock()
+
+	if ok {
+		return stmt
+	}
+	// Ограничиваем размер кеша на случай, если запросы формируются динамически.
+	if size >= c.maxSize {
+		return nil
+	}
+
+	// Подготавливаем выражение без удержания блокировки, так как это сетевой вызов.
+	// Если ошибка связана с самим запросом, она повторится при его обычном
+	// выполнении и будет обработана вызывающим кодом.
+	stmt, err := c.pool.PrepareContext(ctx, query)
+	if err != nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Другая горутина могла успеть подготовить то же выражение.
+	if existing, ok := c.stmts[query]; ok {
+		stmt.Close()
+		return existing
+	}
+	c.stmts[query] = stmt
+	return stmt
+}
+
+// Вспомогательные функции, которые выполняют запрос через подготовленное
+// выражение из кеша, если оно доступно, или напрямую через пул соединений.
+func (c *stmtCache) queryRow(ctx context.Context, pool *sql.DB, query string, args ...any) *sql.Row {
+	if stmt := c.get(ctx, query); stmt != nil {
+		return stmt.QueryRowContext(ctx, args...)
+	}
+	return pool.QueryRowContext(ctx, query, args...)
+}
+
+func (c *stmtCache) query(ctx context.Context, pool *sql.DB, query string, args ...any) (*sql.Rows, error) {
+	if stmt := c.get(ctx, query); stmt != nil {
+		return stmt.QueryContext(ctx, args...)
+	}
+	return pool.QueryContext(ctx, query, args...)
+}
+
+func (c *stmtCache) exec(ctx context.Context, pool *sql.DB, query string, args ...any) (sql.Result, error) {
+	if stmt := c.get(ctx, query); stmt != nil {
+		return stmt.ExecContext(ctx, args...)
+	}
+	return pool.ExecContext(ctx, query, args...)
+}
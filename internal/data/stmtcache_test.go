@@ -0,0 +1,62 @@
+package data
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/jsonlog"
+)
+
+// Функция benchmarkStatementCache() сравнивает Get() и Insert() с кешем
+// подготовленных выражений и без него. Функция newModels возвращает модели,
+// у которых кеш выражений ограничен size записями (0 — без подготовки).
+func benchmarkStatementCache(b *testing.B, newModels func(b *testing.B, size int) Models) {
+	for _, bc := range []struct {
+		name string
+		size int
+	}{
+		{"Unprepared", 0},
+		{"Prepared", 256},
+	} {
+		b.Run("Get/"+bc.name, func(b *testing.B) {
+			models := newModels(b, bc.size)
+			ctx := context.Background()
+			movie := &Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: MoviePublished}
+			if err := models.Movies.Insert(ctx, movie); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for range b.N {
+				if _, err := models.Movies.Get(ctx, movie.ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run("Insert/"+bc.name, func(b *testing.B) {
+			models := newModels(b, bc.size)
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for range b.N {
+				movie := &Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: MoviePublished}
+				if err := models.Movies.Insert(ctx, movie); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkStatementCacheSQLite выполняет сравнение на SQLite в памяти процесса.
+// Для PostgreSQL, где выигрыш заметнее (нет повторного разбора и планирования
+// запроса на сервере), см. BenchmarkStatementCachePostgres в интеграционных тестах.
+func BenchmarkStatementCacheSQLite(b *testing.B) {
+	benchmarkStatementCache(b, func(b *testing.B, size int) Models {
+		db := NewDB(openTestSQLite(b), jsonlog.New(io.Discard, jsonlog.LevelOff), 0)
+		db.SetStatementCacheSize(size)
+		return NewSQLiteModels(db, 0)
+	})
+}
@@ -0,0 +1,38 @@
+package data
+
+import (
+	"context"
+	"regexp"
+)
+
+// DefaultTenant — арендатор, к которому относятся запросы без явно указанного
+// арендатора и все данные, созданные до появления разделения по арендаторам.
+const DefaultTenant = "default"
+
+type tenantContextKey struct{}
+
+// Функция WithTenant() возвращает контекст, в котором модели работают только
+// с данными арендатора tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// Функция TenantFromContext() возвращает арендатора из контекста или
+// DefaultTenant, если он не указан.
+func TenantFromContext(ctx context.Context) string {
+	tenant, ok := ctx.Value(tenantContextKey{}).(string)
+	if !ok || tenant == "" {
+		return DefaultTenant
+	}
+	return tenant
+}
+
+// Идентификатор арендатора: строчные латинские буквы, цифры, дефис и
+// подчеркивание, не длиннее 63 символов.
+var tenantIDRX = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Функция ValidTenantID() сообщает, является ли tenant допустимым идентификатором
+// арендатора.
+func ValidTenantID(tenant string) bool {
+	return tenantIDRX.MatchString(tenant)
+}
@@ -0,0 +1,247 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/validator"
+)
+
+// MovieTranslation — перевод сведений о фильме на другой язык.
+type MovieTranslation struct {
+	MovieID   int64     `json:"movie_id"`
+	Language  string    `json:"language"`
+	Title     string    `json:"title"`
+	Overview  string    `json:"overview,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TranslationStore описывает методы, которые должна поддерживать модель переводов.
+type TranslationStore interface {
+	Upsert(ctx context.Context, translation *MovieTranslation) error
+	Delete(ctx context.Context, movieID int64, language string) error
+	GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieTranslation, error)
+	Localize(ctx context.Context, movies []*Movie, languages []string) error
+	Reassign(ctx context.Context, fromMovieID, toMovieID int64) (int64, error)
+}
+
+func ValidateTranslation(v *validator.Validator, translation *MovieTranslation) {
+	v.Check(validator.Matches(translation.Language, validator.LanguageTagRX), "language", "must be a valid lowercase language tag")
+	v.Check(translation.Title != "", "title", "must be provided")
+	v.Check(validator.MaxBytes(translation.Title, MovieTitleMaxBytes), "title", fmt.Sprintf("must not be more than %d bytes long", MovieTitleMaxBytes))
+	v.Check(validator.MaxBytes(translation.Overview, MovieOverviewMaxBytes), "overview", fmt.Sprintf("must not be more than %d bytes long", MovieOverviewMaxBytes))
+}
+
+type TranslationModel struct {
+	DB           Querier
+	QueryTimeout time.Duration
+}
+
+// Метод Upsert() создает перевод или заменяет существующий перевод на тот же язык.
+// Время изменения фильма также обновляется, чтобы клиенты, закешировавшие фильм
+// (по Last-Modified), получили новый перевод.
+func (m TranslationModel) Upsert(ctx context.Context, translation *MovieTranslation) error {
+	query := `
+    WITH movie AS (
+        SELECT id FROM movies WHERE id = $1 AND tenant_id = $5
+    ), upserted AS (
+        INSERT INTO movie_translations (movie_id, language, title, overview)
+        SELECT id, $2, $3, $4 FROM movie
+        ON CONFLICT (movie_id, language) DO UPDATE
+        SET title = EXCLUDED.title, overview = EXCLUDED.overview, updated_at = NOW()
+        RETURNING updated_at
+    ), touched AS (
+        UPDATE movies SET updated_at = NOW() WHERE id IN (SELECT id FROM movie)
+    )
+    SELECT updated_at FROM upserted`
+	args := []any{translation.MovieID, translation.Language, translation.Title, translation.Overview, TenantFromContext(ctx)}
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&translation.UpdatedAt)
+	if err != nil {
+		switch {
+		// Фильма нет или он принадлежит другому арендатору.
+		case errors.Is(err, sql.ErrNoRows), pgErrorCode(err) == pgForeignKeyViolation:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m TranslationModel) Delete(ctx context.Context, movieID int64, language string) error {
+	query := `
+    WITH deleted AS (
+        DELETE FROM movie_translations
+        WHERE movie_id = $1 AND language = $2
+        AND movie_id IN (SELECT id FROM movies WHERE tenant_id = $3)
+        RETURNING movie_id
+    ), touched AS (
+        UPDATE movies SET updated_at = NOW() WHERE id IN (SELECT movie_id FROM deleted)
+    )
+    SELECT count(*) FROM deleted`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var deleted int
+	err := m.DB.QueryRowContext(ctx, query, movieID, language, TenantFromContext(ctx)).Scan(&deleted)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m TranslationModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieTranslation, error) {
+	query := `
+    SELECT movie_id, language, title, overview, updated_at
+    FROM movie_translations
+    WHERE movie_id = $1
+    ORDER BY language`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.ReadQueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translations := []*MovieTranslation{}
+	for rows.Next() {
+		var translation MovieTranslation
+		err := rows.Scan(&translation.MovieID, &translation.Language, &translation.Title, &translation.Overview, &translation.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		translations = append(translations, &translation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return translations, nil
+}
+
+// Метод Localize() заменяет название и описание каждого фильма переводом на первый
+// язык из списка languages (в порядке предпочтения), для которого перевод есть.
+// Если в переводе нет описания, остается исходное. Если перевода ни на один из
+// языков нет, фильм остается без изменений.
+func (m TranslationModel) Localize(ctx context.Context, movies []*Movie, languages []string) error {
+	if len(movies) == 0 || len(languages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	query := `
+    SELECT movie_id, language, title, overview
+    FROM movie_translations
+    WHERE movie_id = ANY($1) AND language = ANY($2)`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.ReadQueryContext(ctx, query, int64Array(&ids), stringArray(&languages))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	return localizeRows(rows, movies, languages)
+}
+
+// Функция localizeRows() применяет к фильмам переводы, прочитанные из rows (столбцы
+// movie_id, language, title, overview): для каждого фильма выбирается перевод на
+// язык с наименьшим индексом в languages.
+func localizeRows(rows *sql.Rows, movies []*Movie, languages []string) error {
+	best := make(map[int64]MovieTranslation)
+	for rows.Next() {
+		var translation MovieTranslation
+		err := rows.Scan(&translation.MovieID, &translation.Language, &translation.Title, &translation.Overview)
+		if err != nil {
+			return err
+		}
+
+		current, ok := best[translation.MovieID]
+		if !ok || slices.Index(languages, translation.Language) < slices.Index(languages, current.Language) {
+			best[translation.MovieID] = translation
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		if translation, ok := best[movie.ID]; ok {
+			localizeMovie(movie, translation)
+		}
+	}
+	return nil
+}
+
+// Функция localizeMovie() заменяет название и описание фильма переводом. Если
+// в переводе нет описания, остается исходное.
+func localizeMovie(movie *Movie, translation MovieTranslation) {
+	movie.OriginalTitle = movie.Title
+	movie.Title = translation.Title
+	if translation.Overview != "" {
+		movie.Overview = translation.Overview
+	}
+	movie.Language = translation.Language
+}
+
+// Метод Reassign() переносит переводы фильма fromMovieID на фильм toMovieID и
+// возвращает количество перенесенных переводов. Переводы на языки, для которых
+// у toMovieID уже есть перевод, остаются у исходного фильма.
+func (m TranslationModel) Reassign(ctx context.Context, fromMovieID, toMovieID int64) (int64, error) {
+	query := `
+    UPDATE movie_translations
+    SET movie_id = $2, updated_at = NOW()
+    WHERE movie_id = $1
+    AND language NOT IN (SELECT language FROM movie_translations WHERE movie_id = $2)`
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, fromMovieID, toMovieID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+type MockTranslationModel struct{}
+
+func (m MockTranslationModel) Upsert(ctx context.Context, translation *MovieTranslation) error {
+	return nil
+}
+
+func (m MockTranslationModel) Delete(ctx context.Context, movieID int64, language string) error {
+	return nil
+}
+
+func (m MockTranslationModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieTranslation, error) {
+	return nil, nil
+}
+
+func (m MockTranslationModel) Localize(ctx context.Context, movies []*Movie, languages []string) error {
+	return nil
+}
+
+func (m MockTranslationModel) Reassign(ctx context.Context, fromMovieID, toMovieID int64) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Querier описывает методы выполнения запросов, которые используют модели. Его
+// реализуют как *DB (пул соединений), так и *Tx (транзакция), поэтому одна и та же
+// модель может работать и вне транзакции, и внутри нее.
+type Querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	ReadQueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ReadQueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Tx оборачивает транзакцию *sql.Tx так же, как DB оборачивает пул соединений:
+// время выполнения запросов измеряется и учитывается в метриках.
+type Tx struct {
+	*sql.Tx
+	db *DB
+	// Фильмы, измененные в транзакции. После фиксации они удаляются из кеша
+	// фильмов (см. txMovieModel).
+	evicted []int64
+}
+
+func (tx *Tx) evict(id int64) {
+	tx.evicted = append(tx.evicted, id)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := tx.Tx.QueryRowContext(ctx, query, args...)
+	tx.db.observe(query, len(args), time.Since(start))
+	return row
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	tx.db.observe(query, len(args), time.Since(start))
+	return rows, err
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := tx.Tx.ExecContext(ctx, query, args...)
+	tx.db.observe(query, len(args), time.Since(start))
+	return result, err
+}
+
+// Внутри транзакции запросы на чтение выполняются в той же транзакции (а не на
+// реплике), чтобы видеть изменения, сделанные ранее в этой же транзакции.
+func (tx *Tx) ReadQueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return tx.QueryRowContext(ctx, query, args...)
+}
+
+func (tx *Tx) ReadQueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return tx.QueryContext(ctx, query, args...)
+}
+
+// Метод WithTx() открывает транзакцию, создает экземпляр Models, все модели
+// которого выполняют запросы в этой транзакции, и вызывает с ним функцию fn.
+// Если fn возвращает ошибку (или паникует), транзакция откатывается, иначе
+// фиксируется. Вложенные вызовы WithTx() выполняются в уже открытой транзакции.
+func (m Models) WithTx(ctx context.Context, fn func(Models) error) error {
+	// Модели, созданные без пула соединений (мок-модели или модели, уже
+	// привязанные к транзакции), просто вызывают fn.
+	if m.db == nil {
+		return fn(m)
+	}
+
+	sqlTx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Если fn запаникует, откатываем транзакцию и передаем панику дальше.
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	tx := &Tx{Tx: sqlTx, db: m.db}
+	txModels := m.bind(tx)
+	txModels.db = nil
+
+	err = fn(txModels)
+	if err != nil {
+		rollbackErr := sqlTx.Rollback()
+		if rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+
+	err = sqlTx.Commit()
+	if err != nil {
+		return err
+	}
+	if m.movieCache != nil {
+		for _, id := range tx.evicted {
+			m.movieCache.delete(id)
+		}
+	}
+	return nil
+}
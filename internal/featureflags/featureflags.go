@@ -0,0 +1,141 @@
+// Пакет featureflags позволяет включать рискованные функции постепенно: только
+// в отдельных окружениях и (или) для части клиентов. Флаги загружаются из JSON-файла
+// и могут перечитываться без перезапуска приложения.
+package featureflags
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Flag описывает условия, при которых функция включена.
+type Flag struct {
+	// Если Enabled равно false, функция выключена независимо от остальных условий.
+	Enabled bool `json:"enabled"`
+	// Окружения, в которых функция доступна. Пустой список означает все окружения.
+	Environments []string `json:"environments,omitempty"`
+	// Доля клиентов (от 0 до 100), для которых функция включена. Отсутствие поля
+	// означает всех клиентов.
+	Percentage *int `json:"percentage,omitempty"`
+}
+
+// Set — потокобезопасный набор флагов, загруженный из файла. Нулевое значение
+// (и набор без файла) содержит пустой набор: все функции выключены.
+type Set struct {
+	path string
+	env  string
+
+	mu      sync.RWMutex
+	flags   map[string]Flag
+	modTime time.Time
+}
+
+// Функция Load() загружает флаги из файла path для окружения env. Пустой path
+// дает пустой набор флагов.
+func Load(path, env string) (*Set, error) {
+	s := &Set{path: path, env: env, flags: map[string]Flag{}}
+	if path == "" {
+		return s, nil
+	}
+
+	_, err := s.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Метод Reload() перечитывает файл флагов, если он изменился с момента последней
+// загрузки, и сообщает, были ли флаги обновлены. При ошибке остаются прежние флаги.
+func (s *Set) Reload() (bool, error) {
+	if s.path == "" {
+		return false, nil
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, err
+	}
+
+	var flags map[string]Flag
+	err = json.Unmarshal(content, &flags)
+	if err != nil {
+		return false, fmt.Errorf("featureflags: parse %s: %w", s.path, err)
+	}
+	for name, flag := range flags {
+		if flag.Percentage != nil && (*flag.Percentage < 0 || *flag.Percentage > 100) {
+			return false, fmt.Errorf("featureflags: flag %q: percentage must be between 0 and 100", name)
+		}
+	}
+
+	s.mu.Lock()
+	s.flags = flags
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+
+	return true, nil
+}
+
+// Метод Enabled() сообщает, включена ли функция name для клиента subject
+// (например, IP-адреса). Один и тот же клиент всегда попадает в одну и ту же
+// долю, поэтому при постепенном включении функция не "мигает" между запросами.
+// Неизвестные флаги считаются выключенными.
+func (s *Set) Enabled(name, subject string) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.RLock()
+	flag, ok := s.flags[name]
+	s.mu.RUnlock()
+
+	if !ok || !flag.Enabled {
+		return false
+	}
+	if len(flag.Environments) > 0 && !slices.Contains(flag.Environments, s.env) {
+		return false
+	}
+	if flag.Percentage == nil {
+		return true
+	}
+	return bucket(name, subject) < *flag.Percentage
+}
+
+// Метод All() возвращает копию текущих флагов (например, для /debug/vars).
+func (s *Set) All() map[string]Flag {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	flags := make(map[string]Flag, len(s.flags))
+	for name, flag := range s.flags {
+		flags[name] = flag
+	}
+	return flags
+}
+
+// Функция bucket() отображает пару (флаг, клиент) в число от 0 до 99. Имя флага
+// участвует в хеше, чтобы разные флаги включались для разных групп клиентов.
+func bucket(name, subject string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(subject))
+	return int(h.Sum32() % 100)
+}
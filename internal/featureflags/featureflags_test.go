@@ -0,0 +1,72 @@
+package featureflags
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/assert"
+)
+
+// Функция writeFlags() записывает content в path и устанавливает время изменения
+// файла modTime.
+func writeFlags(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	err := os.WriteFile(path, []byte(content), 0644)
+	assert.NilError(t, err)
+	err = os.Chtimes(path, modTime, modTime)
+	assert.NilError(t, err)
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "features.json")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeFlags(t, path, `{"new_search": {"enabled": true}}`, modTime)
+
+	s, err := Load(path, "production")
+	assert.NilError(t, err)
+	assert.Equal(t, s.Enabled("new_search", "203.0.113.7"), true)
+
+	// Файл с прежним временем изменения не перечитывается.
+	writeFlags(t, path, `{"new_search": {"enabled": false}}`, modTime)
+	reloaded, err := s.Reload()
+	assert.NilError(t, err)
+	assert.Equal(t, reloaded, false)
+	assert.Equal(t, s.Enabled("new_search", "203.0.113.7"), true)
+
+	// Ошибка в новом файле оставляет прежние флаги.
+	modTime = modTime.Add(time.Minute)
+	writeFlags(t, path, `{"new_search": {"enabled": false}, "new_limiter": {"enabled": true, "percentage": 150}}`, modTime)
+	reloaded, err = s.Reload()
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, reloaded, false)
+	assert.Equal(t, s.Enabled("new_search", "203.0.113.7"), true)
+	assert.Equal(t, len(s.All()), 1)
+
+	modTime = modTime.Add(time.Minute)
+	writeFlags(t, path, `{"new_search": {"enabled": true, "environments": ["staging"]}}`, modTime)
+	reloaded, err = s.Reload()
+	assert.NilError(t, err)
+	assert.Equal(t, reloaded, true)
+	assert.Equal(t, s.Enabled("new_search", "203.0.113.7"), false)
+}
+
+func TestBucket(t *testing.T) {
+	// Значение зависит только от имени флага и клиента, поэтому совпадает между
+	// вызовами и между экземплярами приложения.
+	assert.Equal(t, bucket("new_search", "203.0.113.7"), 85)
+	assert.Equal(t, bucket("new_search", "203.0.113.7"), bucket("new_search", "203.0.113.7"))
+	assert.Equal(t, bucket("new_limiter", "203.0.113.7"), 1)
+
+	percentage := func(p int) *int { return &p }
+	s := &Set{flags: map[string]Flag{
+		"new_search": {Enabled: true, Percentage: percentage(85)},
+	}}
+	assert.Equal(t, s.Enabled("new_search", "203.0.113.7"), false)
+
+	// Увеличение доли не выключает функцию для клиентов, у которых она уже была включена.
+	s.flags["new_search"] = Flag{Enabled: true, Percentage: percentage(86)}
+	assert.Equal(t, s.Enabled("new_search", "203.0.113.7"), true)
+}
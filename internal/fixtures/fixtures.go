@@ -0,0 +1,254 @@
+// Пакет fixtures загружает наборы фильмов из JSON- и CSV-файлов. Он используется
+// командой seed, флагом -seed (например, вместе с -demo) и тестами, чтобы
+// в демонстрационных окружениях и бенчмарках были правдоподобные данные.
+//
+// Загрузка идемпотентна: фильм из файла сопоставляется с уже существующим по
+// IMDb ID, TMDB ID или, если их нет, по точному совпадению названия и года.
+// Существующие фильмы обновляются, только если их данные отличаются от файла.
+package fixtures
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/validator"
+)
+
+// Result содержит итоги загрузки.
+type Result struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+}
+
+// Функция LoadFile() загружает фильмы из файла. Формат определяется по расширению:
+// .json (массив фильмов в том же виде, что и в API) или .csv (см. ReadCSV()).
+func LoadFile(ctx context.Context, models data.Models, path string) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+
+	var movies []*data.Movie
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		movies, err = ReadJSON(f)
+	case ".csv":
+		movies, err = ReadCSV(f)
+	default:
+		return Result{}, fmt.Errorf("%s: unsupported fixture format", path)
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	result, err := Load(ctx, models, movies)
+	if err != nil {
+		return result, fmt.Errorf("%s: %w", path, err)
+	}
+	return result, nil
+}
+
+// Функция ReadJSON() читает JSON-массив фильмов. Поля id и version игнорируются,
+// а статус по умолчанию — published.
+func ReadJSON(r io.Reader) ([]*data.Movie, error) {
+	var movies []*data.Movie
+	err := json.NewDecoder(r).Decode(&movies)
+	if err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
+
+// Функция ReadCSV() читает фильмы из CSV-файла с заголовком. Обязательны столбцы
+// title, year, runtime (в минутах) и genres (через "|"); необязательны imdb_id,
+// tmdb_id, overview, tagline, original_language, country и status.
+func ReadCSV(r io.Reader) ([]*data.Movie, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("missing CSV header")
+	}
+
+	header := records[0]
+	for _, required := range []string{"title", "year", "runtime", "genres"} {
+		if !slices.Contains(header, required) {
+			return nil, fmt.Errorf("missing %q column", required)
+		}
+	}
+
+	movies := []*data.Movie{}
+	for i, record := range records[1:] {
+		// Номер строки в файле с учетом заголовка.
+		line := i + 2
+
+		movie := &data.Movie{}
+		for j, column := range header {
+			value := strings.TrimSpace(record[j])
+			switch column {
+			case "title":
+				movie.Title = value
+			case "year":
+				year, err := strconv.ParseInt(value, 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid year %q", line, value)
+				}
+				movie.Year = int32(year)
+			case "runtime":
+				runtime, err := strconv.ParseInt(value, 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid runtime %q", line, value)
+				}
+				movie.Runtime = data.Runtime(runtime)
+			case "genres":
+				movie.Genres = strings.Split(value, "|")
+			case "imdb_id":
+				movie.IMDbID = value
+			case "tmdb_id":
+				if value == "" {
+					continue
+				}
+				tmdbID, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid tmdb_id %q", line, value)
+				}
+				movie.TMDBID = tmdbID
+			case "overview":
+				movie.Overview = value
+			case "tagline":
+				movie.Tagline = value
+			case "original_language":
+				movie.OriginalLanguage = value
+			case "country":
+				movie.Country = value
+			case "status":
+				movie.Status = value
+			}
+		}
+		movies = append(movies, movie)
+	}
+
+	return movies, nil
+}
+
+// Функция Load() сохраняет фильмы в models. Все фильмы проверяются до начала
+// записи, а сама запись выполняется в одной транзакции, поэтому файл с ошибкой
+// не загружается частично.
+func Load(ctx context.Context, models data.Models, movies []*data.Movie) (Result, error) {
+	for i, movie := range movies {
+		movie.ID = 0
+		movie.Version = 0
+		if movie.Status == "" {
+			movie.Status = data.MoviePublished
+		}
+
+		v := validator.New()
+		if data.ValidateMovie(v, movie); !v.Valid() {
+			return Result{}, fmt.Errorf("movie %d (%q): invalid fixture: %v", i+1, movie.Title, v.Errors)
+		}
+	}
+
+	var result Result
+	err := models.WithTx(ctx, func(models data.Models) error {
+		result = Result{}
+		for i, movie := range movies {
+			err := save(ctx, models, movie, &result)
+			if err != nil {
+				return fmt.Errorf("movie %d (%q): %w", i+1, movie.Title, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
+
+// Функция save() добавляет фильм или обновляет существующий.
+func save(ctx context.Context, models data.Models, movie *data.Movie, result *Result) error {
+	existing, err := find(ctx, models, movie)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		result.Inserted++
+		return models.Movies.Insert(ctx, movie)
+	}
+
+	if sameContent(existing, movie) {
+		result.Unchanged++
+		return nil
+	}
+
+	existing.Title = movie.Title
+	existing.Year = movie.Year
+	existing.Runtime = movie.Runtime
+	existing.Genres = movie.Genres
+	existing.IMDbID = movie.IMDbID
+	existing.TMDBID = movie.TMDBID
+	existing.Overview = movie.Overview
+	existing.Tagline = movie.Tagline
+	existing.OriginalLanguage = movie.OriginalLanguage
+	existing.Country = movie.Country
+
+	result.Updated++
+	return models.Movies.Update(ctx, existing)
+}
+
+// Функция find() ищет уже загруженный фильм. Если его нет, возвращается nil.
+func find(ctx context.Context, models data.Models, movie *data.Movie) (*data.Movie, error) {
+	var filter data.MovieFilter
+	switch {
+	case movie.IMDbID != "":
+		filter.IMDbID = movie.IMDbID
+	case movie.TMDBID != 0:
+		filter.TMDBID = movie.TMDBID
+	default:
+		filter.Title = movie.Title
+	}
+
+	filters := data.Filters{Page: 1, PageSize: 100, Sort: "id", SortSafelist: []string{"id"}}
+	for {
+		candidates, metadata, err := models.Movies.GetAll(ctx, filter, filters)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range candidates {
+			if filter.Title == "" || (candidate.Title == movie.Title && candidate.Year == movie.Year) {
+				return candidate, nil
+			}
+		}
+		if filters.Page >= metadata.LastPage {
+			return nil, nil
+		}
+		filters.Page++
+	}
+}
+
+func sameContent(a, b *data.Movie) bool {
+	return a.Title == b.Title &&
+		a.Year == b.Year &&
+		a.Runtime == b.Runtime &&
+		slices.Equal(a.Genres, b.Genres) &&
+		a.IMDbID == b.IMDbID &&
+		a.TMDBID == b.TMDBID &&
+		a.Overview == b.Overview &&
+		a.Tagline == b.Tagline &&
+		a.OriginalLanguage == b.OriginalLanguage &&
+		a.Country == b.Country
+}
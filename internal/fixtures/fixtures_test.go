@@ -0,0 +1,77 @@
+package fixtures
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/data"
+)
+
+func TestLoadFileIsIdempotent(t *testing.T) {
+	models := data.NewMockModels()
+	ctx := context.Background()
+
+	result, err := LoadFile(ctx, models, "../../fixtures/movies.json")
+	assert.NilError(t, err)
+	assert.Equal(t, result.Inserted, 13)
+
+	result, err = LoadFile(ctx, models, "../../fixtures/movies.json")
+	assert.NilError(t, err)
+	assert.Equal(t, result, Result{Unchanged: 13})
+}
+
+func TestReadCSV(t *testing.T) {
+	input := "title,year,runtime,genres,imdb_id\n" +
+		"Moana,2016,107,animation|adventure,tt3521164\n" +
+		"\"Crouching Tiger, Hidden Dragon\",2000,120,action,\n"
+
+	movies, err := ReadCSV(strings.NewReader(input))
+	assert.NilError(t, err)
+	assert.Equal(t, len(movies), 2)
+	assert.Equal(t, movies[0].Runtime, data.Runtime(107))
+	assert.DeepEqual(t, movies[0].Genres, []string{"animation", "adventure"})
+	assert.Equal(t, movies[1].Title, "Crouching Tiger, Hidden Dragon")
+
+	_, err = ReadCSV(strings.NewReader("title,year\nMoana,2016\n"))
+	assert.Equal(t, err.Error(), `missing "runtime" column`)
+}
+
+func TestLoadUpdatesChangedMovies(t *testing.T) {
+	models := data.NewMockModels()
+	ctx := context.Background()
+
+	load := func(runtime int32) Result {
+		t.Helper()
+		movies := []*data.Movie{{Title: "Moana", Year: 2016, Runtime: data.Runtime(runtime), Genres: []string{"animation"}}}
+		result, err := Load(ctx, models, movies)
+		assert.NilError(t, err)
+		return result
+	}
+
+	assert.Equal(t, load(107), Result{Inserted: 1})
+	assert.Equal(t, load(108), Result{Updated: 1})
+	assert.Equal(t, load(108), Result{Unchanged: 1})
+
+	movie, err := models.Movies.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, movie.Runtime, data.Runtime(108))
+	assert.Equal(t, movie.Version, int32(2))
+}
+
+func TestLoadRejectsInvalidFixtures(t *testing.T) {
+	models := data.NewMockModels()
+
+	movies := []*data.Movie{
+		{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}},
+		{Title: "", Year: 2016, Runtime: 107, Genres: []string{"animation"}},
+	}
+	_, err := Load(context.Background(), models, movies)
+	assert.StringContains(t, err.Error(), "movie 2")
+
+	// Ни один фильм не должен быть загружен.
+	_, metadata, err := models.Movies.GetAll(context.Background(), data.MovieFilter{}, data.Filters{Page: 1, PageSize: 10, Sort: "id", SortSafelist: []string{"id"}})
+	assert.NilError(t, err)
+	assert.Equal(t, metadata.TotalRecords, 0)
+}
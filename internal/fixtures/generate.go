@@ -0,0 +1,139 @@
+package fixtures
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/data"
+)
+
+// Жанры с весами, примерно соответствующими их доле в реальных каталогах.
+var genreWeights = []struct {
+	genre  string
+	weight int
+}{
+	{"drama", 30},
+	{"comedy", 18},
+	{"thriller", 10},
+	{"action", 9},
+	{"romance", 8},
+	{"horror", 6},
+	{"crime", 6},
+	{"documentary", 5},
+	{"adventure", 5},
+	{"sci-fi", 4},
+	{"animation", 4},
+	{"family", 3},
+	{"fantasy", 3},
+	{"mystery", 3},
+	{"war", 2},
+	{"western", 1},
+	{"musical", 1},
+}
+
+var (
+	titleAdjectives = []string{"Silent", "Last", "Broken", "Golden", "Hidden", "Midnight", "Crimson", "Lost", "Frozen", "Wild", "Distant", "Burning", "Secret", "Endless", "Quiet", "Dark", "Electric", "Forgotten"}
+	titleNouns      = []string{"River", "Kingdom", "Summer", "Letter", "Garden", "Highway", "Promise", "Harbor", "Mountain", "Detective", "Orchestra", "Island", "Empire", "Station", "Witness", "Horizon", "Dream", "Frontier"}
+	titlePatterns   = []string{"The %s %s", "%s %s", "A %s %s", "The %[2]s", "%[2]s of the %[1]s"}
+	overviews       = []string{
+		"A %s journey through a %s that nobody has seen in years.",
+		"When a %s secret surfaces, a small town and its %s are changed forever.",
+		"Two strangers meet on a %s night and follow the road to a %s.",
+		"An unlikely crew races against time to save a %s %s.",
+	}
+	languages = []struct{ language, country string }{{"en", "US"}, {"en", "GB"}, {"fr", "FR"}, {"de", "DE"}, {"ja", "JP"}, {"ko", "KR"}, {"es", "ES"}, {"it", "IT"}, {"hi", "IN"}}
+)
+
+// Generator создает случайные, но правдоподобные фильмы. Одинаковое начальное
+// значение дает одинаковую последовательность фильмов.
+type Generator struct {
+	rnd         *rand.Rand
+	totalWeight int
+}
+
+// Функция NewGenerator() возвращает генератор с начальным значением seed.
+func NewGenerator(seed uint64) *Generator {
+	g := &Generator{rnd: rand.New(rand.NewPCG(seed, seed))}
+	for _, gw := range genreWeights {
+		g.totalWeight += gw.weight
+	}
+	return g
+}
+
+// Метод Movie() возвращает новый фильм. Годы выпуска смещены к современности,
+// а продолжительность распределена нормально вокруг 105 минут.
+func (g *Generator) Movie() *data.Movie {
+	adjective := titleAdjectives[g.rnd.IntN(len(titleAdjectives))]
+	noun := titleNouns[g.rnd.IntN(len(titleNouns))]
+	pattern := titlePatterns[g.rnd.IntN(len(titlePatterns))]
+	origin := languages[g.rnd.IntN(len(languages))]
+
+	// Квадратный корень равномерной величины дает больше поздних лет.
+	span := float64(time.Now().Year() - 1920)
+	year := 1920 + int32(span*math.Sqrt(g.rnd.Float64()))
+
+	runtime := int32(105 + g.rnd.NormFloat64()*20)
+	runtime = max(60, min(runtime, 240))
+
+	return &data.Movie{
+		Title:            fmt.Sprintf(pattern, adjective, noun),
+		Overview:         fmt.Sprintf(overviews[g.rnd.IntN(len(overviews))], strings.ToLower(adjective), strings.ToLower(noun)),
+		Year:             year,
+		Runtime:          data.Runtime(runtime),
+		Genres:           g.genres(),
+		OriginalLanguage: origin.language,
+		Country:          origin.country,
+		Status:           data.MoviePublished,
+	}
+}
+
+// Метод genres() выбирает от одного до трех разных жанров с учетом весов.
+func (g *Generator) genres() []string {
+	n := 1 + g.rnd.IntN(3)
+	genres := make([]string, 0, n)
+	for len(genres) < n {
+		pick := g.rnd.IntN(g.totalWeight)
+		for _, gw := range genreWeights {
+			if pick < gw.weight {
+				if !slices.Contains(genres, gw.genre) {
+					genres = append(genres, gw.genre)
+				}
+				break
+			}
+			pick -= gw.weight
+		}
+	}
+	return genres
+}
+
+// Функция Generate() добавляет n фильмов пакетами по batchSize, каждый пакет —
+// в отдельной транзакции. После каждого пакета вызывается progress с числом уже
+// добавленных фильмов (может быть nil).
+func Generate(ctx context.Context, models data.Models, g *Generator, n, batchSize int, progress func(inserted int)) error {
+	for inserted := 0; inserted < n; {
+		size := min(batchSize, n-inserted)
+		err := models.WithTx(ctx, func(models data.Models) error {
+			for range size {
+				err := models.Movies.Insert(ctx, g.Movie())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		inserted += size
+		if progress != nil {
+			progress(inserted)
+		}
+	}
+	return nil
+}
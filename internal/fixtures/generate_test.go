@@ -0,0 +1,40 @@
+package fixtures
+
+import (
+	"context"
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+	"greenlight.andreyklimov.net/internal/data"
+	"greenlight.andreyklimov.net/internal/validator"
+)
+
+func TestGeneratorProducesValidMovies(t *testing.T) {
+	a, b := NewGenerator(42), NewGenerator(42)
+
+	for range 1000 {
+		movie := a.Movie()
+
+		v := validator.New()
+		data.ValidateMovie(v, movie)
+		assert.DeepEqual(t, v.Errors, map[string]string{})
+
+		// Одинаковое начальное значение дает те же фильмы.
+		assert.Equal(t, b.Movie().Title, movie.Title)
+	}
+}
+
+func TestGenerateInBatches(t *testing.T) {
+	models := data.NewMockModels()
+
+	var batches []int
+	err := Generate(context.Background(), models, NewGenerator(1), 25, 10, func(inserted int) {
+		batches = append(batches, inserted)
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, batches, []int{10, 20, 25})
+
+	_, metadata, err := models.Movies.GetAll(context.Background(), data.MovieFilter{}, data.Filters{Page: 1, PageSize: 10, Sort: "id", SortSafelist: []string{"id"}})
+	assert.NilError(t, err)
+	assert.Equal(t, metadata.TotalRecords, 25)
+}
@@ -0,0 +1,132 @@
+// Пакет i18n переводит сообщения об ошибках API на язык клиента. Исходные сообщения
+// написаны на английском, а переводы хранятся во встроенных каталогах catalogs/*.json
+// (по одному файлу на язык). Если перевода нет, сообщение возвращается без изменений.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Язык исходных сообщений.
+const DefaultLanguage = "en"
+
+//go:embed catalogs/*.json
+var catalogFiles embed.FS
+
+// Каталоги переводов по языкам. Загружаются при инициализации пакета: ошибка
+// во встроенном каталоге — ошибка сборки, поэтому она приводит к панике.
+var catalogs = mustLoadCatalogs()
+
+// template — сообщение с подстановками вида {1}, {2}. Значения подстановок
+// извлекаются из исходного сообщения регулярным выражением rx и вставляются
+// в перевод по номерам.
+type template struct {
+	rx          *regexp.Regexp
+	translation string
+}
+
+type catalog struct {
+	exact     map[string]string
+	templates []template
+}
+
+var placeholderRX = regexp.MustCompile(`\{(\d+)\}`)
+
+func mustLoadCatalogs() map[string]*catalog {
+	files, err := catalogFiles.ReadDir("catalogs")
+	if err != nil {
+		panic(err)
+	}
+
+	catalogs := make(map[string]*catalog, len(files))
+	for _, file := range files {
+		js, err := catalogFiles.ReadFile(path.Join("catalogs", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		var messages map[string]string
+		if err := json.Unmarshal(js, &messages); err != nil {
+			panic("i18n: " + file.Name() + ": " + err.Error())
+		}
+
+		c := &catalog{exact: make(map[string]string)}
+		for source, translation := range messages {
+			if !placeholderRX.MatchString(source) {
+				c.exact[source] = translation
+				continue
+			}
+			c.templates = append(c.templates, template{rx: compileTemplate(source), translation: translation})
+		}
+		// Порядок проверки шаблонов не должен зависеть от порядка обхода карты.
+		slices.SortFunc(c.templates, func(a, b template) int {
+			return strings.Compare(a.rx.String(), b.rx.String())
+		})
+		catalogs[strings.TrimSuffix(file.Name(), ".json")] = c
+	}
+	return catalogs
+}
+
+// Функция compileTemplate() превращает сообщение с подстановками в регулярное
+// выражение, в котором n-я группа соответствует подстановке {n}.
+func compileTemplate(source string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, m := range placeholderRX.FindAllStringSubmatchIndex(source, -1) {
+		b.WriteString(regexp.QuoteMeta(source[last:m[0]]))
+		b.WriteString("(?P<p" + source[m[2]:m[3]] + ">.+?)")
+		last = m[1]
+	}
+	b.WriteString(regexp.QuoteMeta(source[last:]))
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Функция Match() возвращает первый язык из списка предпочтений клиента, на
+// который есть перевод, или DefaultLanguage.
+func Match(languages []string) string {
+	for _, language := range languages {
+		if language == DefaultLanguage {
+			return language
+		}
+		if _, ok := catalogs[language]; ok {
+			return language
+		}
+	}
+	return DefaultLanguage
+}
+
+// Функция Translate() переводит сообщение на язык language. Если каталога для
+// языка или перевода сообщения нет, возвращается исходное сообщение.
+func Translate(language, message string) string {
+	c, ok := catalogs[language]
+	if !ok {
+		return message
+	}
+	if translation, ok := c.exact[message]; ok {
+		return translation
+	}
+
+	for _, t := range c.templates {
+		m := t.rx.FindStringSubmatch(message)
+		if m == nil {
+			continue
+		}
+		return placeholderRX.ReplaceAllStringFunc(t.translation, func(placeholder string) string {
+			n, _ := strconv.Atoi(placeholder[1 : len(placeholder)-1])
+			i := t.rx.SubexpIndex("p" + strconv.Itoa(n))
+			if i < 0 {
+				return placeholder
+			}
+			return m[i]
+		})
+	}
+	return message
+}
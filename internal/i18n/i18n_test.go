@@ -0,0 +1,25 @@
+package i18n
+
+import (
+	"testing"
+
+	"greenlight.andreyklimov.net/internal/assert"
+)
+
+func TestTranslate(t *testing.T) {
+	assert.Equal(t, Translate("ru", "must be provided"), "обязательное поле")
+	assert.Equal(t, Translate("ru", "cannot change movie status from archived to published"), "нельзя изменить статус фильма с archived на published")
+	assert.Equal(t, Translate("ru", "must be at least 200x300 pixels"), "должен быть не меньше 200x300 пикселей")
+
+	// Без перевода сообщение возвращается как есть.
+	assert.Equal(t, Translate("ru", "some new message"), "some new message")
+	assert.Equal(t, Translate("de", "must be provided"), "must be provided")
+	assert.Equal(t, Translate("en", "must be provided"), "must be provided")
+}
+
+func TestMatch(t *testing.T) {
+	assert.Equal(t, Match([]string{"ru-ru", "ru", "en"}), "ru")
+	assert.Equal(t, Match([]string{"de", "en", "ru"}), "en")
+	assert.Equal(t, Match([]string{"de"}), DefaultLanguage)
+	assert.Equal(t, Match(nil), DefaultLanguage)
+}
@@ -0,0 +1,159 @@
+package jsonlog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Handler — это реализация slog.Handler, которая записывает каждую запись в виде
+// одной строки JSON в формате jsonlog: уровень, время, сообщение, карта свойств
+// и (для уровней ERROR и выше) стек вызовов.
+type Handler struct {
+	out      io.Writer
+	minLevel slog.Leveler
+	// Мьютекс хранится по указателю, чтобы обработчики, созданные через WithAttrs()
+	// и WithGroup(), синхронизировали запись в один и тот же io.Writer.
+	mu     *sync.Mutex
+	attrs  []slog.Attr
+	groups []string
+}
+
+// NewHandler возвращает новый Handler, который пропускает записи с уровнем
+// не ниже minLevel.
+func NewHandler(out io.Writer, minLevel slog.Leveler) *Handler {
+	return &Handler{
+		out:      out,
+		minLevel: minLevel,
+		mu:       &sync.Mutex{},
+	}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.minLevel.Level()
+}
+
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	properties := make(map[string]string, len(h.attrs)+r.NumAttrs())
+	for _, attr := range h.attrs {
+		addProperty(properties, "", attr)
+	}
+
+	prefix := groupPrefix(h.groups)
+	r.Attrs(func(attr slog.Attr) bool {
+		addProperty(properties, prefix, attr)
+		return true
+	})
+
+	// Анонимная структура для хранения данных записи лога.
+	aux := struct {
+		Level      string            `json:"level"`
+		Time       string            `json:"time"`
+		Message    string            `json:"message"`
+		Properties map[string]string `json:"properties,omitempty"`
+		Trace      string            `json:"trace,omitempty"`
+	}{
+		Level:      levelName(r.Level),
+		Time:       r.Time.UTC().Format(time.RFC3339),
+		Message:    r.Message,
+		Properties: properties,
+	}
+
+	if r.Time.IsZero() {
+		aux.Time = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	// Включаем стек вызовов для уровней ERROR и FATAL.
+	if r.Level >= slog.LevelError {
+		aux.Trace = string(debug.Stack())
+	}
+
+	// Кодируем структуру в JSON. Если произошла ошибка, записываем текст ошибки.
+	line, err := json.Marshal(aux)
+	if err != nil {
+		line = []byte(LevelError.String() + ": unable to marshal log message: " + err.Error())
+	}
+
+	// Блокируем мьютекс, чтобы избежать одновременной записи нескольких потоков.
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Записываем лог и добавляем перевод строки.
+	_, err = h.out.Write(append(line, '\n'))
+	return err
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	h2.attrs = append(h2.attrs, h.attrs...)
+
+	// Атрибуты, добавленные после WithGroup(), должны попасть в текущую группу,
+	// поэтому сразу сохраняем их с префиксом группы.
+	prefix := groupPrefix(h.groups)
+	for _, attr := range attrs {
+		attr.Key = prefix + attr.Key
+		h2.attrs = append(h2.attrs, attr)
+	}
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(append([]string{}, h.groups...), name)
+	return &h2
+}
+
+// Функция addProperty() добавляет атрибут slog в плоскую карту свойств. Вложенные
+// группы раскладываются в ключи вида "group.key".
+func addProperty(properties map[string]string, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix = prefix + attr.Key + "."
+		}
+		for _, child := range attr.Value.Group() {
+			addProperty(properties, groupPrefix, child)
+		}
+		return
+	}
+
+	properties[prefix+attr.Key] = attr.Value.String()
+}
+
+func groupPrefix(groups []string) string {
+	prefix := ""
+	for _, group := range groups {
+		prefix += group + "."
+	}
+	return prefix
+}
+
+// Функция levelName() возвращает имя уровня в формате jsonlog. Уровни slog, которые
+// не совпадают в точности с известными, округляются вниз до ближайшего из них.
+func levelName(level slog.Level) string {
+	switch {
+	case level >= slogLevelFatal:
+		return LevelFatal.String()
+	case level >= slog.LevelError:
+		return LevelError.String()
+	case level >= slog.LevelWarn:
+		return LevelWarn.String()
+	case level >= slog.LevelInfo:
+		return LevelInfo.String()
+	default:
+		return LevelDebug.String()
+	}
+}
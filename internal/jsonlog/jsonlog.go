@@ -1,12 +1,13 @@
 package jsonlog
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	"io"
+	"log/slog"
 	"os"
-	"runtime/debug"
-	"sync"
-	"time"
+	"sort"
+	"strings"
 )
 
 // Определяем тип Level для представления уровня серьезности записи в журнале.
@@ -15,17 +16,30 @@ type Level int8
 // Инициализируем константы, представляющие уровни серьезности. Используем iota
 // как сокращение для присвоения последовательных целочисленных значений.
 const (
-	LevelInfo  Level = iota // Значение 0.
-	LevelError              // Значение 1.
-	LevelFatal              // Значение 2.
-	LevelOff                // Значение 3.
+	LevelDebug Level = iota // Значение 0.
+	LevelInfo               // Значение 1.
+	LevelWarn               // Значение 2.
+	LevelError              // Значение 3.
+	LevelFatal              // Значение 4.
+	LevelOff                // Значение 5.
+)
+
+// Уровни slog, соответствующие FATAL и OFF. В log/slog таких уровней нет, поэтому
+// размещаем их выше slog.LevelError с тем же шагом, что и у стандартных уровней.
+const (
+	slogLevelFatal = slog.LevelError + 4
+	slogLevelOff   = slog.LevelError + 8
 )
 
 // Возвращаем удобочитаемое строковое представление уровня серьезности.
 func (l Level) String() string {
 	switch l {
+	case LevelDebug:
+		return "DEBUG"
 	case LevelInfo:
 		return "INFO"
+	case LevelWarn:
+		return "WARN"
 	case LevelError:
 		return "ERROR"
 	case LevelFatal:
@@ -35,30 +49,92 @@ func (l Level) String() string {
 	}
 }
 
-// Определяем собственный тип Logger. Он хранит выходное место назначения для записей,
-// минимальный уровень серьезности, а также мьютекс для синхронизации записей.
+// Метод Level() возвращает соответствующий уровень log/slog. Благодаря этому Level
+// удовлетворяет интерфейсу slog.Leveler.
+func (l Level) Level() slog.Level {
+	switch l {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	case LevelFatal:
+		return slogLevelFatal
+	default:
+		return slogLevelOff
+	}
+}
+
+// ParseLevel преобразует имя уровня (debug, info, warn, error, fatal или off, без учета
+// регистра) в соответствующее значение Level. Используется для разбора флага -log-level.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	case "fatal":
+		return LevelFatal, nil
+	case "off":
+		return LevelOff, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+}
+
+// Определяем собственный тип Logger. Теперь это тонкая обертка над *slog.Logger,
+// который использует наш Handler, поэтому записи, сделанные как через методы
+// PrintInfo()/PrintError()/PrintFatal(), так и напрямую через slog (в том числе
+// сторонними библиотеками), имеют одинаковый JSON-формат.
 type Logger struct {
-	out      io.Writer
-	minLevel Level
-	mu       sync.Mutex
+	logger *slog.Logger
 }
 
 // Возвращаем новый экземпляр Logger, который записывает записи в журнал при уровне
 // серьезности не ниже указанного.
 func New(out io.Writer, minLevel Level) *Logger {
 	return &Logger{
-		out:      out,
-		minLevel: minLevel,
+		logger: slog.New(NewHandler(out, minLevel)),
+	}
+}
+
+// Метод Slog() возвращает нижележащий *slog.Logger. Его можно передать сторонним
+// библиотекам или установить как логгер по умолчанию с помощью slog.SetDefault().
+func (l *Logger) Slog() *slog.Logger {
+	return l.logger
+}
+
+// Метод With() возвращает новый Logger, который добавляет указанные свойства
+// в каждую запись. Это позволяет, например, создать логгер для отдельного запроса,
+// который автоматически включает идентификатор запроса во все записи.
+func (l *Logger) With(properties map[string]string) *Logger {
+	return &Logger{
+		logger: slog.New(l.logger.Handler().WithAttrs(propertiesToAttrs(properties))),
 	}
 }
 
 // Вспомогательные методы для записи логов с разными уровнями серьезности.
 // В качестве второго параметра принимают карту с произвольными "свойствами",
 // которые будут добавлены в запись лога.
+func (l *Logger) PrintDebug(message string, properties map[string]string) {
+	l.print(LevelDebug, message, properties)
+}
+
 func (l *Logger) PrintInfo(message string, properties map[string]string) {
 	l.print(LevelInfo, message, properties)
 }
 
+func (l *Logger) PrintWarn(message string, properties map[string]string) {
+	l.print(LevelWarn, message, properties)
+}
+
 func (l *Logger) PrintError(err error, properties map[string]string) {
 	l.print(LevelError, err.Error(), properties)
 }
@@ -68,47 +144,34 @@ func (l *Logger) PrintFatal(err error, properties map[string]string) {
 	os.Exit(1) // При уровне FATAL также завершаем выполнение приложения.
 }
 
-// Внутренний метод print для записи логов.
+// Внутренний метод print преобразует карту свойств в атрибуты slog и передает
+// запись в slog.Logger.
 func (l *Logger) print(level Level, message string, properties map[string]string) (int, error) {
+	ctx := context.Background()
+
 	// Если уровень серьезности ниже минимального уровня логирования, просто выходим.
-	if level < l.minLevel {
+	if !l.logger.Enabled(ctx, level.Level()) {
 		return 0, nil
 	}
 
-	// Анонимная структура для хранения данных записи лога.
-	aux := struct {
-		Level      string            `json:"level"`
-		Time       string            `json:"time"`
-		Message    string            `json:"message"`
-		Properties map[string]string `json:"properties,omitempty"`
-		Trace      string            `json:"trace,omitempty"`
-	}{
-		Level:      level.String(),
-		Time:       time.Now().UTC().Format(time.RFC3339),
-		Message:    message,
-		Properties: properties,
-	}
+	l.logger.LogAttrs(ctx, level.Level(), message, propertiesToAttrs(properties)...)
+	return len(message), nil
+}
 
-	// Включаем стек вызовов для уровней ERROR и FATAL.
-	if level >= LevelError {
-		aux.Trace = string(debug.Stack())
+// Функция propertiesToAttrs() преобразует карту свойств в срез атрибутов slog,
+// отсортированный по ключу, чтобы порядок атрибутов был стабильным.
+func propertiesToAttrs(properties map[string]string) []slog.Attr {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	// Переменная для хранения JSON-записи.
-	var line []byte
-
-	// Кодируем структуру в JSON. Если произошла ошибка, записываем текст ошибки.
-	line, err := json.Marshal(aux)
-	if err != nil {
-		line = []byte(LevelError.String() + ": unable to marshal log message: " + err.Error())
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, key := range keys {
+		attrs = append(attrs, slog.String(key, properties[key]))
 	}
-
-	// Блокируем мьютекс, чтобы избежать одновременной записи нескольких потоков.
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	// Записываем лог и добавляем перевод строки.
-	return l.out.Write(append(line, '\n'))
+	return attrs
 }
 
 // Реализуем метод Write() для соответствия интерфейсу io.Writer.
@@ -0,0 +1,200 @@
+package jsonlog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RotatingFile — это io.Writer, который записывает данные в файл и выполняет его
+// ротацию при превышении заданного размера или по истечении интервала времени.
+// Старые файлы переименовываются с добавлением временной метки к имени и удаляются
+// в соответствии с настройками хранения (количество и возраст архивных файлов).
+type RotatingFile struct {
+	filename   string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	maxAge     time.Duration
+
+	// Ошибки ротации и удаления архивов не должны приводить к потере записей лога,
+	// поэтому они не возвращаются из Write, а выводятся в errOutput.
+	errOutput io.Writer
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// Функция rename() переименовывает файл; в тестах она подменяется, чтобы
+// проверить обработку ошибок ротации.
+var rename = os.Rename
+
+// NewRotatingFile открывает (или создает) файл лога и возвращает новый экземпляр
+// RotatingFile. Нулевое значение maxSize или interval отключает соответствующий
+// вид ротации, а нулевые maxBackups и maxAge — соответствующее ограничение хранения.
+func NewRotatingFile(filename string, maxSize int64, interval time.Duration, maxBackups int, maxAge time.Duration) (*RotatingFile, error) {
+	f := &RotatingFile{
+		filename:   filename,
+		maxSize:    maxSize,
+		interval:   interval,
+		maxBackups: maxBackups,
+		maxAge:     maxAge,
+		errOutput:  os.Stderr,
+	}
+
+	err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write записывает данные в текущий файл, предварительно выполняя ротацию,
+// если запись превысит допустимый размер или истек интервал ротации.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.shouldRotate(len(p)) {
+		err := f.rotate()
+		if err != nil {
+			fmt.Fprintf(f.errOutput, "jsonlog: cannot rotate %s: %v\n", f.filename, err)
+		}
+	}
+
+	// Если после неудачной ротации файл не удалось открыть заново, пробуем еще раз
+	// при каждой записи.
+	if f.file == nil {
+		err := f.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close закрывает текущий файл лога.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
+
+func (f *RotatingFile) shouldRotate(writeLen int) bool {
+	// Не выполняем ротацию пустого файла по размеру, иначе одна слишком большая
+	// запись приводила бы к бесконечному созданию пустых архивов.
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(writeLen) > f.maxSize {
+		return true
+	}
+	if f.interval > 0 && time.Since(f.openedAt) >= f.interval {
+		return true
+	}
+	return false
+}
+
+func (f *RotatingFile) open() error {
+	err := os.MkdirAll(filepath.Dir(f.filename), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = time.Now()
+	return nil
+}
+
+// Метод rotate() закрывает текущий файл, переименовывает его в архивный
+// (например, api.log.2024-01-02T15-04-05.000), открывает новый файл и удаляет
+// архивы, которые больше не нужно хранить. Если переименовать файл не удалось,
+// запись продолжается в исходный файл. Ошибки удаления архивов выводятся в
+// errOutput и не считаются ошибкой ротации.
+func (f *RotatingFile) rotate() error {
+	// Даже если Close() вернул ошибку, дескриптор уже освобожден, и файл нужно
+	// открыть заново.
+	closeErr := f.file.Close()
+	f.file = nil
+
+	backupName := fmt.Sprintf("%s.%s", f.filename, time.Now().UTC().Format("2006-01-02T15-04-05.000"))
+	err := rename(f.filename, backupName)
+	if err != nil && !os.IsNotExist(err) {
+		openErr := f.open()
+		if openErr != nil {
+			return fmt.Errorf("%w (reopen: %v)", err, openErr)
+		}
+		return err
+	}
+
+	err = f.open()
+	if err != nil {
+		return err
+	}
+
+	err = f.prune()
+	if err != nil {
+		fmt.Fprintf(f.errOutput, "jsonlog: cannot remove old log files: %v\n", err)
+	}
+	return closeErr
+}
+
+func (f *RotatingFile) prune() error {
+	if f.maxBackups <= 0 && f.maxAge <= 0 {
+		return nil
+	}
+
+	backups, err := filepath.Glob(f.filename + ".*")
+	if err != nil {
+		return err
+	}
+
+	// Временная метка в имени файла сортируется лексикографически, поэтому после
+	// сортировки в обратном порядке самые новые архивы идут первыми.
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	// Архив, который не удалось удалить, не мешает удалить остальные.
+	var errs []error
+	for i, backup := range backups {
+		remove := f.maxBackups > 0 && i >= f.maxBackups
+
+		if !remove && f.maxAge > 0 {
+			info, err := os.Stat(backup)
+			if err == nil && time.Since(info.ModTime()) > f.maxAge {
+				remove = true
+			}
+		}
+
+		if remove {
+			err := os.Remove(backup)
+			if err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
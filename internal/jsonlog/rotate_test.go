@@ -0,0 +1,128 @@
+package jsonlog
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/assert"
+)
+
+// Функция newTestRotatingFile() создает RotatingFile во временном каталоге.
+// Ошибки ротации записываются в errOutput.
+func newTestRotatingFile(t *testing.T, maxSize int64, interval time.Duration, maxBackups int, maxAge time.Duration) (*RotatingFile, *bytes.Buffer) {
+	t.Helper()
+
+	f, err := NewRotatingFile(filepath.Join(t.TempDir(), "api.log"), maxSize, interval, maxBackups, maxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	var errOutput bytes.Buffer
+	f.errOutput = &errOutput
+	return f, &errOutput
+}
+
+func backups(t *testing.T, f *RotatingFile) []string {
+	t.Helper()
+	names, err := filepath.Glob(f.filename + ".*")
+	assert.NilError(t, err)
+	return names
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(name)
+	assert.NilError(t, err)
+	return string(content)
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	f, _ := newTestRotatingFile(t, 10, 0, 0, 0)
+
+	_, err := f.Write([]byte("first\n"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(backups(t, f)), 0)
+
+	_, err = f.Write([]byte("second\n"))
+	assert.NilError(t, err)
+
+	names := backups(t, f)
+	assert.Equal(t, len(names), 1)
+	assert.Equal(t, readFile(t, names[0]), "first\n")
+	assert.Equal(t, readFile(t, f.filename), "second\n")
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	f, _ := newTestRotatingFile(t, 0, time.Hour, 0, 0)
+
+	_, err := f.Write([]byte("first\n"))
+	assert.NilError(t, err)
+	f.openedAt = time.Now().Add(-2 * time.Hour)
+
+	_, err = f.Write([]byte("second\n"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(backups(t, f)), 1)
+	assert.Equal(t, readFile(t, f.filename), "second\n")
+}
+
+func TestRotatingFilePrune(t *testing.T) {
+	f, errOutput := newTestRotatingFile(t, 0, time.Hour, 2, 24*time.Hour)
+
+	// Три старых архива; самый новый из них к тому же старше maxAge.
+	old := time.Now().Add(-48 * time.Hour)
+	for _, suffix := range []string{"2020-01-01T00-00-00.000", "2020-01-02T00-00-00.000", "2020-01-03T00-00-00.000"} {
+		assert.NilError(t, os.WriteFile(f.filename+"."+suffix, []byte("old\n"), 0644))
+	}
+	assert.NilError(t, os.Chtimes(f.filename+".2020-01-03T00-00-00.000", old, old))
+
+	f.openedAt = time.Now().Add(-2 * time.Hour)
+	_, err := f.Write([]byte("line\n"))
+	assert.NilError(t, err)
+
+	// По количеству остались бы новый архив и архив за 2020-01-03, но последний
+	// удаляется по возрасту.
+	names := backups(t, f)
+	assert.Equal(t, len(names), 1)
+	assert.Equal(t, strings.Contains(names[0], ".2020-"), false)
+	assert.Equal(t, errOutput.String(), "")
+}
+
+func TestRotatingFilePruneError(t *testing.T) {
+	f, errOutput := newTestRotatingFile(t, 0, time.Hour, 1, 0)
+
+	// Непустой каталог нельзя удалить, поэтому удаление архива завершится ошибкой.
+	dir := f.filename + ".2020-01-01T00-00-00.000"
+	assert.NilError(t, os.MkdirAll(filepath.Join(dir, "x"), 0755))
+
+	f.openedAt = time.Now().Add(-2 * time.Hour)
+	n, err := f.Write([]byte("line\n"))
+	assert.NilError(t, err)
+	assert.Equal(t, n, 5)
+	assert.Equal(t, readFile(t, f.filename), "line\n")
+	assert.StringContains(t, errOutput.String(), "cannot remove old log files")
+}
+
+func TestRotatingFileRenameError(t *testing.T) {
+	f, errOutput := newTestRotatingFile(t, 10, 0, 0, 0)
+
+	rename = func(oldpath, newpath string) error { return errors.New("rename failed") }
+	t.Cleanup(func() { rename = os.Rename })
+
+	_, err := f.Write([]byte("first\n"))
+	assert.NilError(t, err)
+	_, err = f.Write([]byte("second\n"))
+	assert.NilError(t, err)
+
+	// Ротация не удалась, но записи продолжают попадать в исходный файл.
+	assert.StringContains(t, errOutput.String(), "rename failed")
+	assert.Equal(t, len(backups(t, f)), 0)
+	_, err = f.Write([]byte("third\n"))
+	assert.NilError(t, err)
+	assert.Equal(t, readFile(t, f.filename), "first\nsecond\nthird\n")
+}
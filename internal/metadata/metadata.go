@@ -0,0 +1,85 @@
+// Пакет metadata получает сведения о фильмах из внешних каталогов (OMDb, TMDB)
+// по идентификатору IMDb.
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Ошибка, возвращаемая, когда провайдер не знает фильма с указанным идентификатором.
+var ErrNotFound = errors.New("metadata: movie not found")
+
+// Movie — сведения о фильме, полученные от провайдера.
+type Movie struct {
+	Title   string
+	Year    int32
+	Runtime int32 // в минутах
+	Genres  []string
+}
+
+// Provider — источник сведений о фильмах.
+type Provider interface {
+	Lookup(ctx context.Context, imdbID string) (*Movie, error)
+}
+
+// Client выполняет запросы к провайдеру, ограничивая их частоту, чтобы не превысить
+// квоты внешнего API.
+type Client struct {
+	provider Provider
+	limiter  *rate.Limiter
+}
+
+// Функция New() возвращает клиент, выполняющий не больше rps запросов в секунду
+// (с пиками до burst запросов).
+func New(provider Provider, rps float64, burst int) *Client {
+	return &Client{
+		provider: provider,
+		limiter:  rate.NewLimiter(rate.Limit(rps), burst),
+	}
+}
+
+// Метод Lookup() ждет, пока ограничитель разрешит запрос (или пока не будет отменен
+// контекст), и запрашивает сведения о фильме у провайдера.
+func (c *Client) Lookup(ctx context.Context, imdbID string) (*Movie, error) {
+	err := c.limiter.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.provider.Lookup(ctx, imdbID)
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Функция getJSON() выполняет GET-запрос и декодирует JSON-ответ в dst. Ответ
+// 404 Not Found превращается в ErrNotFound.
+func getJSON(ctx context.Context, url string, headers http.Header, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("metadata: unexpected response status %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
@@ -0,0 +1,72 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// OMDb получает сведения о фильмах из OMDb API (https://www.omdbapi.com).
+type OMDb struct {
+	apiKey  string
+	baseURL string
+}
+
+func NewOMDb(apiKey string) *OMDb {
+	return &OMDb{apiKey: apiKey, baseURL: "https://www.omdbapi.com/"}
+}
+
+func (o *OMDb) Lookup(ctx context.Context, imdbID string) (*Movie, error) {
+	query := url.Values{
+		"apikey": {o.apiKey},
+		"i":      {imdbID},
+		"type":   {"movie"},
+	}
+
+	// OMDb возвращает код 200 и в случае ошибки, сообщая о ней полями Response и Error.
+	var result struct {
+		Response string `json:"Response"`
+		Error    string `json:"Error"`
+		Title    string `json:"Title"`
+		Year     string `json:"Year"`
+		Runtime  string `json:"Runtime"`
+		Genre    string `json:"Genre"`
+	}
+
+	err := getJSON(ctx, o.baseURL+"?"+query.Encode(), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Response != "True" {
+		if strings.Contains(strings.ToLower(result.Error), "not found") {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("metadata: omdb: %s", result.Error)
+	}
+
+	movie := &Movie{Title: result.Title}
+
+	// Год может быть задан диапазоном ("2008–2010"), берем первые четыре цифры.
+	if len(result.Year) >= 4 {
+		if year, err := strconv.Atoi(result.Year[:4]); err == nil {
+			movie.Year = int32(year)
+		}
+	}
+
+	// Продолжительность передается строкой вида "152 min" или "N/A".
+	if minutes, ok := strings.CutSuffix(result.Runtime, " min"); ok {
+		if runtime, err := strconv.Atoi(minutes); err == nil {
+			movie.Runtime = int32(runtime)
+		}
+	}
+
+	if result.Genre != "" && result.Genre != "N/A" {
+		for _, genre := range strings.Split(result.Genre, ",") {
+			movie.Genres = append(movie.Genres, strings.ToLower(strings.TrimSpace(genre)))
+		}
+	}
+
+	return movie, nil
+}
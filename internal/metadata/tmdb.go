@@ -0,0 +1,76 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// TMDB получает сведения о фильмах из API The Movie Database
+// (https://developer.themoviedb.org). Для аутентификации используется токен
+// доступа API Read Access Token.
+type TMDB struct {
+	token   string
+	baseURL string
+}
+
+func NewTMDB(token string) *TMDB {
+	return &TMDB{token: token, baseURL: "https://api.themoviedb.org/3"}
+}
+
+func (t *TMDB) Lookup(ctx context.Context, imdbID string) (*Movie, error) {
+	headers := http.Header{
+		"Authorization": {"Bearer " + t.token},
+		"Accept":        {"application/json"},
+	}
+
+	// Сначала находим идентификатор TMDB по идентификатору IMDb.
+	var found struct {
+		MovieResults []struct {
+			ID int64 `json:"id"`
+		} `json:"movie_results"`
+	}
+	findURL := fmt.Sprintf("%s/find/%s?external_source=imdb_id", t.baseURL, url.PathEscape(imdbID))
+	err := getJSON(ctx, findURL, headers, &found)
+	if err != nil {
+		return nil, err
+	}
+	if len(found.MovieResults) == 0 {
+		return nil, ErrNotFound
+	}
+
+	var result struct {
+		Title       string `json:"title"`
+		ReleaseDate string `json:"release_date"`
+		Runtime     int32  `json:"runtime"`
+		Genres      []struct {
+			Name string `json:"name"`
+		} `json:"genres"`
+	}
+	movieURL := t.baseURL + "/movie/" + strconv.FormatInt(found.MovieResults[0].ID, 10)
+	err = getJSON(ctx, movieURL, headers, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	movie := &Movie{
+		Title:   result.Title,
+		Runtime: result.Runtime,
+	}
+
+	// Дата выхода передается в формате "2008-07-16".
+	if len(result.ReleaseDate) >= 4 {
+		if year, err := strconv.Atoi(result.ReleaseDate[:4]); err == nil {
+			movie.Year = int32(year)
+		}
+	}
+
+	for _, genre := range result.Genres {
+		movie.Genres = append(movie.Genres, strings.ToLower(genre.Name))
+	}
+
+	return movie, nil
+}
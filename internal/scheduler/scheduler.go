@@ -0,0 +1,132 @@
+// Пакет scheduler выполняет периодические фоновые задачи (задачи обслуживания,
+// очистки и т. п.) с логированием и метриками для каждой задачи.
+package scheduler
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/jsonlog"
+)
+
+// Метрики задач, публикуемые через expvar. Ключом во всех картах является имя задачи.
+var (
+	jobRunsTotal          = expvar.NewMap("scheduler_job_runs_total")
+	jobFailuresTotal      = expvar.NewMap("scheduler_job_failures_total")
+	jobDurationTotal      = expvar.NewMap("scheduler_job_duration_μs_total")
+	jobLastSuccessSeconds = expvar.NewMap("scheduler_job_last_success_unix")
+)
+
+// Job — периодическая задача. Функция Run получает контекст, который отменяется
+// при остановке планировщика.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler запускает каждую добавленную задачу в отдельной горутине с заданным
+// интервалом. Запуски одной задачи никогда не перекрываются.
+type Scheduler struct {
+	logger *jsonlog.Logger
+	jobs   []Job
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(logger *jsonlog.Logger) *Scheduler {
+	return &Scheduler{logger: logger}
+}
+
+// Метод Add() регистрирует задачу. Задачи с неположительным интервалом
+// не запускаются, что позволяет отключать их через конфигурацию; об отключенной
+// задаче выводится предупреждение.
+func (s *Scheduler) Add(job Job) {
+	if job.Interval <= 0 {
+		s.logger.PrintWarn("scheduled job disabled", map[string]string{"job": job.Name})
+		return
+	}
+	s.jobs = append(s.jobs, job)
+}
+
+// Метод Start() запускает все зарегистрированные задачи.
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, job := range s.jobs {
+		s.logger.PrintInfo("scheduled job", map[string]string{
+			"job":      job.Name,
+			"interval": job.Interval.String(),
+		})
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+
+			ticker := time.NewTicker(job.Interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					s.run(ctx, job)
+				}
+			}
+		}()
+	}
+}
+
+// Метод Stop() отменяет контекст выполняющихся задач и дожидается их завершения.
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// Метод run() выполняет задачу один раз, перехватывая панику, и учитывает
+// результат в логе и метриках.
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	start := time.Now()
+
+	err := func() (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("panic: %v", p)
+			}
+		}()
+		return job.Run(ctx)
+	}()
+
+	duration := time.Since(start)
+	jobRunsTotal.Add(job.Name, 1)
+	jobDurationTotal.Add(job.Name, duration.Microseconds())
+
+	if err != nil {
+		jobFailuresTotal.Add(job.Name, 1)
+		s.logger.PrintError(err, map[string]string{
+			"job":      job.Name,
+			"duration": duration.String(),
+		})
+		return
+	}
+
+	jobLastSuccessSeconds.Set(job.Name, intVar(time.Now().Unix()))
+	s.logger.PrintDebug("job completed", map[string]string{
+		"job":      job.Name,
+		"duration": duration.String(),
+	})
+}
+
+func intVar(n int64) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(n)
+	return v
+}
@@ -0,0 +1,37 @@
+// Пакет storage сохраняет загруженные файлы (например, постеры фильмов) в локальной
+// файловой системе или в S3-совместимом хранилище.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Storage — хранилище файлов. Ключ — это относительный путь файла вида
+// "posters/1-abcdef.jpg".
+type Storage interface {
+	// Put сохраняет файл размером size байт под ключом key.
+	Put(ctx context.Context, key, contentType string, r io.Reader, size int64) error
+	// Delete удаляет файл. Отсутствие файла не считается ошибкой.
+	Delete(ctx context.Context, key string) error
+	// URL возвращает адрес, по которому клиенты могут получить файл.
+	URL(key string) string
+}
+
+var errInvalidKey = errors.New("storage: invalid key")
+
+// Функция validKey() проверяет, что ключ — относительный путь без переходов
+// в родительский каталог.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package validator
+
+import (
+	"encoding/json"
+	"regexp"
+)
+
+// Rule описывает правило валидации: машиночитаемый код и параметры ограничения,
+// например {Code: "max", Params: {"max": 2025}}.
+type Rule struct {
+	Code   string
+	Params map[string]any
+}
+
+// Правила, которые используются при проверке полей. Коды стабильны: клиенты
+// сопоставляют по ним локализованные сообщения.
+func RuleRequired() Rule      { return Rule{Code: "required"} }
+func RuleMin(n any) Rule      { return Rule{Code: "min", Params: map[string]any{"min": n}} }
+func RuleMax(n any) Rule      { return Rule{Code: "max", Params: map[string]any{"max": n}} }
+func RuleMaxBytes(n int) Rule { return Rule{Code: "max_bytes", Params: map[string]any{"max": n}} }
+func RuleMinItems(n int) Rule { return Rule{Code: "min_items", Params: map[string]any{"min": n}} }
+func RuleMaxItems(n int) Rule { return Rule{Code: "max_items", Params: map[string]any{"max": n}} }
+func RuleUnique() Rule        { return Rule{Code: "unique"} }
+func RulePattern(rx *regexp.Regexp) Rule {
+	return Rule{Code: "pattern", Params: map[string]any{"pattern": rx.String()}}
+}
+func RuleOneOf[T any](values ...T) Rule {
+	return Rule{Code: "one_of", Params: map[string]any{"values": values}}
+}
+
+// FieldError — подробное описание ошибки валидации поля. В JSON параметры правила
+// выводятся на одном уровне с остальными полями:
+//
+//	{"field": "year", "code": "max", "max": 2025, "value": 2031, "message": "..."}
+type FieldError struct {
+	Field   string
+	Rule    Rule
+	Message string
+	// Value — проверенное значение; nil, если оно не сохраняется.
+	Value any
+}
+
+func (e FieldError) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(e.Rule.Params)+4)
+	for k, v := range e.Rule.Params {
+		m[k] = v
+	}
+	m["field"] = e.Field
+	m["code"] = e.Rule.Code
+	m["message"] = e.Message
+	if e.Value != nil {
+		m["value"] = e.Value
+	}
+	return json.Marshal(m)
+}
@@ -0,0 +1,59 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Key собирает ключ ошибки для вложенного поля из частей пути: строки — имена
+// полей, целые числа — индексы элементов массива. Например, Key("movies", 3,
+// "title") возвращает "movies[3].title".
+func Key(parts ...any) string {
+	var b strings.Builder
+	for _, part := range parts {
+		switch p := part.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", p)
+		case string:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(p)
+		default:
+			panic(fmt.Sprintf("validator: unsupported key part %T", part))
+		}
+	}
+	return b.String()
+}
+
+// joinKey добавляет к префиксу ключ ошибки дочернего валидатора.
+func joinKey(prefix, key string) string {
+	switch {
+	case prefix == "":
+		return key
+	case key == "" || strings.HasPrefix(key, "["):
+		return prefix + key
+	default:
+		return prefix + "." + key
+	}
+}
+
+// Merge добавляет ошибки дочернего валидатора child с ключами, к которым спереди
+// добавлен prefix: ошибка "title" с префиксом "movies[3]" становится ошибкой
+// "movies[3].title". Порядок ошибок сохраняется.
+func (v *Validator) Merge(prefix string, child *Validator) {
+	for _, e := range child.Details {
+		e.Field = joinKey(prefix, e.Field)
+		v.AddFieldError(e)
+	}
+}
+
+// Each проверяет каждый элемент items функцией check с отдельным валидатором
+// и добавляет ошибки элементов в v с ключами вида "key[i].field".
+func Each[T any](v *Validator, key string, items []T, check func(v *Validator, item T)) {
+	for i, item := range items {
+		child := New()
+		check(child, item)
+		v.Merge(Key(key, i), child)
+	}
+}
@@ -1,7 +1,12 @@
 package validator
 
 import (
+	"cmp"
+	"net/mail"
+	"net/url"
 	"regexp"
+	"time"
+	"unicode/utf8"
 )
 
 // Объявляем регулярное выражение для проверки формата email-адресов (мы будем
@@ -10,17 +15,28 @@ import (
 // если вы читаете это в формате PDF или EPUB и не видите полный шаблон, см. примечание
 // далее на странице.
 var (
+	// Идентификатор фильма в IMDb, например tt0468569.
+	IMDbIDRX = regexp.MustCompile(`^tt\d{7,10}$`)
+	// Тег языка в нижнем регистре, например "ru" или "pt-br".
+	LanguageTagRX = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+	// Код страны ISO 3166-1 alpha-2, например "US".
+	CountryCodeRX = regexp.MustCompile(`^[A-Z]{2}$`)
+	// UUID в канонической записи (8-4-4-4-12 шестнадцатеричных цифр).
+	UUIDRX  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
 	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 )
 
 // Определяем новый тип Validator, который содержит карту ошибок валидации.
+// Карта Errors (поле — сообщение) сохранена для обратной совместимости, а Details
+// содержит те же ошибки в подробной форме (см. FieldError) в порядке добавления.
 type Validator struct {
-	Errors map[string]string
+	Errors  map[string]string
+	Details []FieldError
 }
 
 // New — вспомогательная функция, которая создаёт новый экземпляр Validator с пустой картой ошибок.
 func New() *Validator {
-	return &Validator{Errors: make(map[string]string)}
+	return &Validator{Errors: make(map[string]string), Details: []FieldError{}}
 }
 
 // Valid возвращает true, если в карте ошибок нет записей.
@@ -29,9 +45,17 @@ func (v *Validator) Valid() bool {
 }
 
 // AddError добавляет сообщение об ошибке в карту (только если для данного ключа еще нет записи).
+// Подробная форма ошибки получает код "invalid" без параметров.
 func (v *Validator) AddError(key, message string) {
-	if _, exists := v.Errors[key]; !exists {
-		v.Errors[key] = message
+	v.AddFieldError(FieldError{Field: key, Rule: Rule{Code: "invalid"}, Message: message})
+}
+
+// AddFieldError добавляет подробное описание ошибки и ее сообщение в карту Errors
+// (только если для поля еще нет ошибки).
+func (v *Validator) AddFieldError(e FieldError) {
+	if _, exists := v.Errors[e.Field]; !exists {
+		v.Errors[e.Field] = e.Message
+		v.Details = append(v.Details, e)
 	}
 }
 
@@ -42,6 +66,15 @@ func (v *Validator) Check(ok bool, key, message string) {
 	}
 }
 
+// CheckRule работает как Check, но дополнительно сохраняет нарушенное правило и
+// проверенное значение, чтобы клиенты могли сформировать собственное сообщение
+// (например, на языке пользователя).
+func (v *Validator) CheckRule(ok bool, key, message string, rule Rule, value any) {
+	if !ok {
+		v.AddFieldError(FieldError{Field: key, Rule: rule, Message: message, Value: value})
+	}
+}
+
 // Универсальная функция, которая возвращает true, если указанное значение присутствует в списке.
 func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	for i := range permittedValues {
@@ -65,3 +98,57 @@ func Unique[T comparable](values []T) bool {
 	}
 	return len(values) == len(uniqueValues)
 }
+
+// Email возвращает true, если значение — адрес электронной почты без имени и угловых
+// скобок (например, "alice@example.com").
+func Email(value string) bool {
+	if len(value) > 254 || !EmailRX.MatchString(value) {
+		return false
+	}
+	_, err := mail.ParseAddress(value)
+	return err == nil
+}
+
+// URL возвращает true, если значение — абсолютный URL-адрес с одной из схем schemes
+// (по умолчанию http или https) и непустым хостом.
+func URL(value string, schemes ...string) bool {
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return u.Host != "" && PermittedValue(u.Scheme, schemes...)
+}
+
+// UUID возвращает true, если значение — UUID в канонической записи.
+func UUID(value string) bool {
+	return UUIDRX.MatchString(value)
+}
+
+// DateBefore возвращает true, если момент t раньше limit.
+func DateBefore(t, limit time.Time) bool {
+	return t.Before(limit)
+}
+
+// DateAfter возвращает true, если момент t позже limit.
+func DateAfter(t, limit time.Time) bool {
+	return t.After(limit)
+}
+
+// Between возвращает true, если значение лежит в диапазоне [min, max] (включительно).
+func Between[T cmp.Ordered](value, min, max T) bool {
+	return value >= min && value <= max
+}
+
+// MaxBytes возвращает true, если длина строки не больше n байт.
+func MaxBytes(value string, n int) bool {
+	return len(value) <= n
+}
+
+// MaxRunes возвращает true, если строка содержит не больше n символов (рун).
+// В отличие от MaxBytes, символы кириллицы и эмодзи считаются за один.
+func MaxRunes(value string, n int) bool {
+	return utf8.RuneCountInString(value) <= n
+}
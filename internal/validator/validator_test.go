@@ -0,0 +1,94 @@
+package validator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"greenlight.andreyklimov.net/internal/assert"
+)
+
+func TestValidatorDetails(t *testing.T) {
+	v := New()
+	v.CheckRule(2031 <= 2025, "year", "must not be in the future", RuleMax(2025), 2031)
+	v.CheckRule(false, "year", "must be provided", RuleRequired(), nil)
+	v.Check(false, "title", "must be provided")
+	v.CheckRule(true, "runtime", "must be a positive integer", RuleMin(1), 107)
+
+	// Как и в карте Errors, для поля сохраняется только первая ошибка.
+	assert.DeepEqual(t, v.Errors, map[string]string{"year": "must not be in the future", "title": "must be provided"})
+	assert.Equal(t, len(v.Details), 2)
+
+	js, err := json.Marshal(v.Details)
+	assert.NilError(t, err)
+	assert.Equal(t, string(js), `[{"code":"max","field":"year","max":2025,"message":"must not be in the future","value":2031},{"code":"invalid","field":"title","message":"must be provided"}]`)
+}
+
+func TestHelpers(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{"Email", Email("alice@example.com"), true},
+		{"Email with name", Email("Alice <alice@example.com>"), false},
+		{"Email without domain", Email("alice@"), false},
+		{"URL", URL("https://example.com/posters"), true},
+		{"URL relative", URL("/v1/posters"), false},
+		{"URL scheme", URL("ftp://example.com"), false},
+		{"URL custom scheme", URL("s3://bucket", "s3"), true},
+		{"UUID", UUID("0b8f2c5e-8a4e-4f3b-9c1d-2f6e7a8b9c0d"), true},
+		{"UUID without dashes", UUID("0b8f2c5e8a4e4f3b9c1d2f6e7a8b9c0d"), false},
+		{"DateBefore", DateBefore(now, now.Add(time.Second)), true},
+		{"DateBefore equal", DateBefore(now, now), false},
+		{"DateAfter", DateAfter(now, now.Add(-time.Second)), true},
+		{"Between", Between(1888, 1888, 2025), true},
+		{"Between outside", Between(2031, 1888, 2025), false},
+		{"Between strings", Between("m", "a", "z"), true},
+		{"MaxBytes", MaxBytes("Жизнь", 5), false},
+		{"MaxRunes", MaxRunes("Жизнь", 5), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.got, tt.want)
+		})
+	}
+}
+
+func TestNestedKeys(t *testing.T) {
+	assert.Equal(t, Key("movies", 3, "title"), "movies[3].title")
+	assert.Equal(t, Key("movie", "credits", 0), "movie.credits[0]")
+
+	type credit struct{ Name string }
+	type movie struct {
+		Title   string
+		Credits []credit
+	}
+
+	v := New()
+	Each(v, "movies", []movie{
+		{Title: "Moana", Credits: []credit{{Name: "Ron Clements"}}},
+		{Title: "", Credits: []credit{{Name: "John Musker"}, {Name: ""}}},
+	}, func(v *Validator, m movie) {
+		v.CheckRule(m.Title != "", "title", "must be provided", RuleRequired(), nil)
+		Each(v, "credits", m.Credits, func(v *Validator, c credit) {
+			v.Check(c.Name != "", "name", "must be provided")
+		})
+	})
+
+	assert.DeepEqual(t, v.Errors, map[string]string{
+		"movies[1].title":           "must be provided",
+		"movies[1].credits[1].name": "must be provided",
+	})
+	assert.Equal(t, v.Details[0].Field, "movies[1].title")
+	assert.Equal(t, v.Details[0].Rule.Code, "required")
+
+	// Ошибки дочернего валидатора без префикса и с ключом-индексом.
+	child := New()
+	child.AddError("[2]", "must not be empty")
+	v.Merge("genres", child)
+	assert.Equal(t, v.Errors["genres[2]"], "must not be empty")
+}
@@ -0,0 +1,4 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS imdb_id text;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS tmdb_id bigint;
+CREATE UNIQUE INDEX IF NOT EXISTS movies_imdb_id_key ON movies (imdb_id);
+CREATE UNIQUE INDEX IF NOT EXISTS movies_tmdb_id_key ON movies (tmdb_id);
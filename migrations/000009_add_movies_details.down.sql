@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS movies_search_idx;
+ALTER TABLE movie_translations DROP COLUMN IF EXISTS overview;
+ALTER TABLE movies DROP COLUMN IF EXISTS country;
+ALTER TABLE movies DROP COLUMN IF EXISTS original_language;
+ALTER TABLE movies DROP COLUMN IF EXISTS tagline;
+ALTER TABLE movies DROP COLUMN IF EXISTS overview;
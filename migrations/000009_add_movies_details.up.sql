@@ -0,0 +1,6 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS overview text NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS tagline text NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS original_language text NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS country text NOT NULL DEFAULT '';
+ALTER TABLE movie_translations ADD COLUMN IF NOT EXISTS overview text NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS movies_search_idx ON movies USING GIN (to_tsvector('simple', title || ' ' || overview));
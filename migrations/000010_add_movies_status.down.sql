@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS movies_status_idx;
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_status_check;
+ALTER TABLE movies DROP COLUMN IF EXISTS status;
@@ -0,0 +1,3 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'published';
+ALTER TABLE movies ADD CONSTRAINT movies_status_check CHECK (status IN ('draft', 'published', 'archived'));
+CREATE INDEX IF NOT EXISTS movies_status_idx ON movies (status);
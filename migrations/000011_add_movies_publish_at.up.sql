@@ -0,0 +1,2 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+CREATE INDEX IF NOT EXISTS movies_publish_at_idx ON movies (publish_at) WHERE publish_at IS NOT NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS movies_tmdb_id_key;
+DROP INDEX IF EXISTS movies_imdb_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS movies_imdb_id_key ON movies (imdb_id);
+CREATE UNIQUE INDEX IF NOT EXISTS movies_tmdb_id_key ON movies (tmdb_id);
+DROP INDEX IF EXISTS movies_tenant_id_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,6 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS movies_tenant_id_idx ON movies (tenant_id);
+DROP INDEX IF EXISTS movies_imdb_id_key;
+DROP INDEX IF EXISTS movies_tmdb_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS movies_imdb_id_key ON movies (tenant_id, imdb_id);
+CREATE UNIQUE INDEX IF NOT EXISTS movies_tmdb_id_key ON movies (tenant_id, tmdb_id);